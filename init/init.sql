@@ -7,9 +7,12 @@ CREATE TABLE products (
     name VARCHAR(255) NOT NULL,
     description TEXT,
     picture VARCHAR(255),
+    pictures JSONB,
     price_currency_code VARCHAR(10) NOT NULL,
     price_units INT NOT NULL,
-    price_nanos INT NOT NULL
+    price_nanos INT NOT NULL,
+    tags JSONB,
+    featured BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE TABLE categories (
@@ -60,4 +63,26 @@ INSERT INTO product_categories (product_id, category_id) VALUES
 ('9SIQT8TOJO', (SELECT id FROM categories WHERE name = 'assembly')),
 ('6E92ZMYYFZ', (SELECT id FROM categories WHERE name = 'accessories')),
 ('6E92ZMYYFZ', (SELECT id FROM categories WHERE name = 'telescopes')),
-('HQTGWGPNH4', (SELECT id FROM categories WHERE name = 'books'));
\ No newline at end of file
+('HQTGWGPNH4', (SELECT id FROM categories WHERE name = 'books'));
+
+-- tags, as in src/productcatalogservice/products/products.json
+UPDATE products SET tags = '["refractor", "beginner"]' WHERE id = 'OLJCESPC7Z';
+UPDATE products SET tags = '["refractor", "beginner", "smartphone"]' WHERE id = '66VCHSJNUP';
+UPDATE products SET tags = '["refractor", "solar"]' WHERE id = '1YMWWN1N4O';
+UPDATE products SET tags = '["solar"]' WHERE id = '6E92ZMYYFZ';
+UPDATE products SET tags = '["vintage"]' WHERE id = 'HQTGWGPNH4';
+
+-- image variants, as in src/productcatalogservice/products/products.json
+UPDATE products SET pictures = '{"full": "NationalParkFoundationExplorascope.jpg"}' WHERE id = 'OLJCESPC7Z';
+UPDATE products SET pictures = '{"full": "StarsenseExplorer.jpg"}' WHERE id = '66VCHSJNUP';
+UPDATE products SET pictures = '{"full": "EclipsmartTravelRefractorTelescope.jpg"}' WHERE id = '1YMWWN1N4O';
+UPDATE products SET pictures = '{"full": "LensCleaningKit.jpg"}' WHERE id = 'L9ECAV7KIM';
+UPDATE products SET pictures = '{"full": "RoofBinoculars.jpg"}' WHERE id = '2ZYFJ3GM2N';
+UPDATE products SET pictures = '{"full": "SolarSystemColorImager.jpg"}' WHERE id = '0PUK6V6EV0';
+UPDATE products SET pictures = '{"full": "RedFlashlight.jpg"}' WHERE id = 'LS4PSXUNUM';
+UPDATE products SET pictures = '{"full": "OpticalTubeAssembly.jpg"}' WHERE id = '9SIQT8TOJO';
+UPDATE products SET pictures = '{"full": "SolarFilter.jpg"}' WHERE id = '6E92ZMYYFZ';
+UPDATE products SET pictures = '{"full": "TheCometBook.jpg"}' WHERE id = 'HQTGWGPNH4';
+
+-- featured products, as in src/productcatalogservice/products/products.json
+UPDATE products SET featured = TRUE WHERE id IN ('66VCHSJNUP', '9SIQT8TOJO', 'HQTGWGPNH4');
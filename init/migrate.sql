@@ -0,0 +1,30 @@
+-- Brings a products database created by an earlier init.sql up to date.
+-- init.sql only runs when the database is first created; apply this file to
+-- an existing database with:
+--   psql -U user -d postgres -f init/migrate.sql
+-- Every statement is safe to run again.
+
+-- tags, as in src/productcatalogservice/products/products.json
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tags JSONB;
+UPDATE products SET tags = '["refractor", "beginner"]' WHERE id = 'OLJCESPC7Z';
+UPDATE products SET tags = '["refractor", "beginner", "smartphone"]' WHERE id = '66VCHSJNUP';
+UPDATE products SET tags = '["refractor", "solar"]' WHERE id = '1YMWWN1N4O';
+UPDATE products SET tags = '["solar"]' WHERE id = '6E92ZMYYFZ';
+UPDATE products SET tags = '["vintage"]' WHERE id = 'HQTGWGPNH4';
+
+-- image variants, as in src/productcatalogservice/products/products.json
+ALTER TABLE products ADD COLUMN IF NOT EXISTS pictures JSONB;
+UPDATE products SET pictures = '{"full": "NationalParkFoundationExplorascope.jpg"}' WHERE id = 'OLJCESPC7Z';
+UPDATE products SET pictures = '{"full": "StarsenseExplorer.jpg"}' WHERE id = '66VCHSJNUP';
+UPDATE products SET pictures = '{"full": "EclipsmartTravelRefractorTelescope.jpg"}' WHERE id = '1YMWWN1N4O';
+UPDATE products SET pictures = '{"full": "LensCleaningKit.jpg"}' WHERE id = 'L9ECAV7KIM';
+UPDATE products SET pictures = '{"full": "RoofBinoculars.jpg"}' WHERE id = '2ZYFJ3GM2N';
+UPDATE products SET pictures = '{"full": "SolarSystemColorImager.jpg"}' WHERE id = '0PUK6V6EV0';
+UPDATE products SET pictures = '{"full": "RedFlashlight.jpg"}' WHERE id = 'LS4PSXUNUM';
+UPDATE products SET pictures = '{"full": "OpticalTubeAssembly.jpg"}' WHERE id = '9SIQT8TOJO';
+UPDATE products SET pictures = '{"full": "SolarFilter.jpg"}' WHERE id = '6E92ZMYYFZ';
+UPDATE products SET pictures = '{"full": "TheCometBook.jpg"}' WHERE id = 'HQTGWGPNH4';
+
+-- featured products, as in src/productcatalogservice/products/products.json
+ALTER TABLE products ADD COLUMN IF NOT EXISTS featured BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE products SET featured = TRUE WHERE id IN ('66VCHSJNUP', '9SIQT8TOJO', 'HQTGWGPNH4');
@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// isoCountryCodes are the ISO 3166-1 alpha-2 country codes.
+var isoCountryCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, c := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI
+		BJ BL BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN
+		CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK
+		FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM
+		HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN
+		KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK
+		ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP
+		NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF
+		TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI
+		VN VU WF WS YE YT ZA ZM ZW`) {
+		codes[c] = true
+	}
+	return codes
+}()
+
+// countryAliases maps other spellings of the countries most orders ship to,
+// upper-cased and without periods, to their alpha-2 code.
+var countryAliases = map[string]string{
+	"USA":                      "US",
+	"UNITED STATES":            "US",
+	"UNITED STATES OF AMERICA": "US",
+	"CAN":                      "CA",
+	"CANADA":                   "CA",
+	"MEX":                      "MX",
+	"MEXICO":                   "MX",
+	"BRA":                      "BR",
+	"BRAZIL":                   "BR",
+	"GBR":                      "GB",
+	"UK":                       "GB",
+	"UNITED KINGDOM":           "GB",
+	"GREAT BRITAIN":            "GB",
+	"IRL":                      "IE",
+	"IRELAND":                  "IE",
+	"FRA":                      "FR",
+	"FRANCE":                   "FR",
+	"DEU":                      "DE",
+	"GERMANY":                  "DE",
+	"NLD":                      "NL",
+	"NETHERLANDS":              "NL",
+	"ESP":                      "ES",
+	"SPAIN":                    "ES",
+	"ITA":                      "IT",
+	"ITALY":                    "IT",
+	"CHE":                      "CH",
+	"SWITZERLAND":              "CH",
+	"SWE":                      "SE",
+	"SWEDEN":                   "SE",
+	"POL":                      "PL",
+	"POLAND":                   "PL",
+	"IND":                      "IN",
+	"INDIA":                    "IN",
+	"CHN":                      "CN",
+	"CHINA":                    "CN",
+	"JPN":                      "JP",
+	"JAPAN":                    "JP",
+	"KOR":                      "KR",
+	"SOUTH KOREA":              "KR",
+	"AUS":                      "AU",
+	"AUSTRALIA":                "AU",
+	"NZL":                      "NZ",
+	"NEW ZEALAND":              "NZ",
+	"ZAF":                      "ZA",
+	"SOUTH AFRICA":             "ZA",
+}
+
+// normalizeCountry returns the ISO 3166-1 alpha-2 code of country, accepting
+// alpha-2 codes in any case and the spellings in countryAliases.
+func normalizeCountry(country string) (string, bool) {
+	c := strings.ToUpper(strings.Join(strings.Fields(strings.ReplaceAll(country, ".", "")), " "))
+	if isoCountryCodes[c] {
+		return c, true
+	}
+	code, ok := countryAliases[c]
+	return code, ok
+}
+
+// normalizeAddress returns a copy of address with its fields trimmed and its
+// country canonicalized to an alpha-2 code, so that downstream lookups see a
+// single spelling per country. It returns an InvalidArgument status when the
+// address is missing or its country is not recognized.
+func normalizeAddress(address *pb.Address) (*pb.Address, error) {
+	if address == nil {
+		return nil, status.Error(codes.InvalidArgument, "a shipping address is required")
+	}
+	a := proto.Clone(address).(*pb.Address)
+	a.StreetAddress = strings.TrimSpace(a.StreetAddress)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.ZipCode = strings.TrimSpace(a.ZipCode)
+
+	country, ok := normalizeCountry(a.Country)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown country %q", a.Country)
+	}
+	a.Country = country
+	return a, nil
+}
+
+// anyCountry keys the address rule of the countries without a rule of their
+// own.
+const anyCountry = "*"
+
+// addressFields are the fields an address rule can require, by their name in
+// the Address message. The country is always required.
+var addressFields = map[string]func(*pb.Address) string{
+	"street_address": (*pb.Address).GetStreetAddress,
+	"city":           (*pb.Address).GetCity,
+	"state":          (*pb.Address).GetState,
+	"zip_code":       (*pb.Address).GetZipCode,
+}
+
+// addressRules are the fields a shipping address must have, by alpha-2
+// country code, so that shipping is never asked to quote for an incomplete
+// address. A nil addressRules requires nothing.
+type addressRules map[string][]string
+
+// defaultAddressRules require a street, city and zip code in every country.
+var defaultAddressRules = addressRules{anyCountry: {"street_address", "city", "zip_code"}}
+
+// addressRulesFromEnv returns the default rules overridden by
+// CHECKOUT_ADDRESS_RULES, a semicolon-separated list of rules such as
+// "HK=street_address,city;US=street_address,city,state,zip_code". A rule for
+// "*" replaces the default one, and a rule without fields requires nothing
+// but the country.
+func addressRulesFromEnv() (addressRules, error) {
+	rules := make(addressRules, len(defaultAddressRules))
+	for country, fields := range defaultAddressRules {
+		rules[country] = fields
+	}
+	for _, rule := range strings.Split(os.Getenv("CHECKOUT_ADDRESS_RULES"), ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		country, list, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid address rule %q, want COUNTRY=field,...", rule)
+		}
+		country = strings.TrimSpace(country)
+		if country != anyCountry {
+			code, ok := normalizeCountry(country)
+			if !ok {
+				return nil, fmt.Errorf("unknown country %q in address rule", country)
+			}
+			country = code
+		}
+		var fields []string
+		for _, field := range strings.Split(list, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if _, ok := addressFields[field]; !ok {
+				return nil, fmt.Errorf("unknown address field %q in the rule for %s", field, country)
+			}
+			fields = append(fields, field)
+		}
+		rules[country] = fields
+	}
+	return rules, nil
+}
+
+// check returns an InvalidArgument status naming the fields the rule for the
+// country of the normalized address requires but it lacks.
+func (r addressRules) check(address *pb.Address) error {
+	fields, ok := r[address.GetCountry()]
+	if !ok {
+		fields = r[anyCountry]
+	}
+	var missing []string
+	for _, field := range fields {
+		if addressFields[field](address) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return status.Errorf(codes.InvalidArgument, "shipping address is missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNormalizeCountry(t *testing.T) {
+	for _, spelling := range []string{"US", "us", " US ", "USA", "U.S.A.", "United States", "united  states of america"} {
+		if got, ok := normalizeCountry(spelling); !ok || got != "US" {
+			t.Errorf("normalizeCountry(%q) = %q, %v, want US", spelling, got, ok)
+		}
+	}
+	for spelling, want := range map[string]string{"UK": "GB", "Great Britain": "GB", "deu": "DE", "Germany": "DE", "fr": "FR", "Japan": "JP"} {
+		if got, ok := normalizeCountry(spelling); !ok || got != want {
+			t.Errorf("normalizeCountry(%q) = %q, %v, want %s", spelling, got, ok, want)
+		}
+	}
+	for _, spelling := range []string{"", "XX", "Atlantis", "U"} {
+		if got, ok := normalizeCountry(spelling); ok {
+			t.Errorf("normalizeCountry(%q) = %q, want it rejected", spelling, got)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	in := &pb.Address{StreetAddress: " 1600 Amphitheatre Parkway ", City: "Mountain View\n", State: " CA", Country: "United States", ZipCode: "94043 "}
+	got, err := normalizeAddress(in)
+	if err != nil {
+		t.Fatalf("normalizeAddress() error = %v", err)
+	}
+	if got.StreetAddress != "1600 Amphitheatre Parkway" || got.City != "Mountain View" || got.State != "CA" || got.ZipCode != "94043" || got.Country != "US" {
+		t.Errorf("normalizeAddress() = %v", got)
+	}
+	if in.Country != "United States" {
+		t.Errorf("normalizeAddress() modified its argument: %v", in)
+	}
+
+	for _, address := range []*pb.Address{nil, {Country: "Narnia"}} {
+		if _, err := normalizeAddress(address); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("normalizeAddress(%v) error = %v, want InvalidArgument", address, err)
+		}
+	}
+}
+
+func TestPlaceOrderNormalizesAddress(t *testing.T) {
+	tc := newTestCheckout(t)
+	req := testPlaceOrderRequest()
+	req.Address.Country = "USA"
+
+	resp, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if got := resp.GetOrder().GetShippingAddress().GetCountry(); got != "US" {
+		t.Errorf("order shipping country = %q, want US", got)
+	}
+
+	req.Address.Country = "Narnia"
+	if _, err := tc.svc.PlaceOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder() with an unknown country error = %v, want InvalidArgument", err)
+	}
+	if len(tc.payment.charges) != 1 {
+		t.Errorf("card charged %d times, want 1", len(tc.payment.charges))
+	}
+}
+
+func TestAddressRulesFromEnv(t *testing.T) {
+	rules, err := addressRulesFromEnv()
+	if err != nil || !slices.Equal(rules[anyCountry], []string{"street_address", "city", "zip_code"}) {
+		t.Errorf("addressRulesFromEnv() = %v, %v by default, want street, city and zip code everywhere", rules, err)
+	}
+
+	t.Setenv("CHECKOUT_ADDRESS_RULES", "hk=street_address,city; USA=street_address,city,state,zip_code;IE=")
+	rules, err = addressRulesFromEnv()
+	if err != nil {
+		t.Fatalf("addressRulesFromEnv() error = %v", err)
+	}
+	if !slices.Equal(rules["HK"], []string{"street_address", "city"}) || len(rules["US"]) != 4 || len(rules["IE"]) != 0 {
+		t.Errorf("addressRulesFromEnv() = %v, want rules for HK, US and IE", rules)
+	}
+	if !slices.Equal(rules[anyCountry], defaultAddressRules[anyCountry]) {
+		t.Errorf("default rule = %v, want it kept", rules[anyCountry])
+	}
+
+	for _, bad := range []string{"US", "Narnia=city", "US=street,city"} {
+		t.Setenv("CHECKOUT_ADDRESS_RULES", bad)
+		if _, err := addressRulesFromEnv(); err == nil {
+			t.Errorf("addressRulesFromEnv() accepted %q", bad)
+		}
+	}
+}
+
+func TestAddressRulesCheck(t *testing.T) {
+	rules := addressRules{
+		anyCountry: {"street_address", "city", "zip_code"},
+		"HK":       {"street_address", "city"},
+	}
+	complete := testPlaceOrderRequest().Address
+	if err := rules.check(complete); err != nil {
+		t.Errorf("check(complete address) = %v", err)
+	}
+
+	noZip := &pb.Address{StreetAddress: "1 Queen's Road", City: "Hong Kong", Country: "HK"}
+	if err := rules.check(noZip); err != nil {
+		t.Errorf("check(address in HK without a zip code) = %v, want it allowed", err)
+	}
+	noZip.Country = "US"
+	err := rules.check(noZip)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "zip_code") {
+		t.Errorf("check(address in US without a zip code) = %v, want InvalidArgument naming zip_code", err)
+	}
+
+	err = rules.check(&pb.Address{Country: "HK"})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "street_address, city") {
+		t.Errorf("check(empty address) = %v, want InvalidArgument naming every missing field", err)
+	}
+}
+
+func TestPlaceOrderRejectsIncompleteAddress(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.addressRules = defaultAddressRules
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() with a complete address: %v", err)
+	}
+
+	req := testPlaceOrderRequest()
+	req.Address.City = " "
+	req.Address.ZipCode = ""
+	_, err := tc.svc.PlaceOrder(context.Background(), req)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "city, zip_code") {
+		t.Errorf("PlaceOrder() with an incomplete address error = %v, want InvalidArgument naming city and zip_code", err)
+	}
+	if len(tc.payment.charges) != 1 {
+		t.Errorf("card charged %d times, want only for the complete address", len(tc.payment.charges))
+	}
+}
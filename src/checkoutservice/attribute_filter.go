@@ -0,0 +1,145 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// appAttributePrefix is the namespace of the attributes set by the handlers,
+// which are the only ones the allow list applies to.
+const appAttributePrefix = "app."
+
+// attributeFilter decides which span and event attributes are exported, to
+// keep high-cardinality or sensitive values such as user IDs out of the
+// traces. Patterns are attribute keys, or key prefixes ending in "*".
+type attributeFilter struct {
+	// allow, when set, lists the only app.* attributes that are exported.
+	allow []string
+	// deny lists attributes that are dropped.
+	deny []string
+	// hash lists attributes whose values are replaced by a hash, so spans of
+	// the same user can still be correlated without revealing who it is.
+	hash []string
+}
+
+// attributeFilterFromEnv reads the comma-separated patterns in
+// CHECKOUT_TRACE_ATTRIBUTES_ALLOW, CHECKOUT_TRACE_ATTRIBUTES_DENY and
+// CHECKOUT_TRACE_ATTRIBUTES_HASH, such as "app.user.id,app.order.*". It
+// returns nil when none is set.
+func attributeFilterFromEnv() *attributeFilter {
+	f := &attributeFilter{
+		allow: attributePatterns(os.Getenv("CHECKOUT_TRACE_ATTRIBUTES_ALLOW")),
+		deny:  attributePatterns(os.Getenv("CHECKOUT_TRACE_ATTRIBUTES_DENY")),
+		hash:  attributePatterns(os.Getenv("CHECKOUT_TRACE_ATTRIBUTES_HASH")),
+	}
+	if f.allow == nil && f.deny == nil && f.hash == nil {
+		return nil
+	}
+	return f
+}
+
+func attributePatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func matchesAttribute(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if pattern == key {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns attrs without the denied attributes, and the app.* attributes
+// not allowed, and with the values of the hashed attributes hashed. Denying
+// an attribute takes precedence over allowing or hashing it.
+func (f *attributeFilter) apply(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		key := string(kv.Key)
+		if matchesAttribute(f.deny, key) {
+			continue
+		}
+		if f.allow != nil && strings.HasPrefix(key, appAttributePrefix) && !matchesAttribute(f.allow, key) {
+			continue
+		}
+		if matchesAttribute(f.hash, key) {
+			kv = kv.Key.String(hashAttributeValue(kv.Value))
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+// hashAttributeValue returns the first 16 hex digits of the SHA-256 of v.
+func hashAttributeValue(v attribute.Value) string {
+	sum := sha256.Sum256([]byte(v.Emit()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// attributeFilterProcessor hands ended spans to next with their attributes
+// and those of their events filtered. Attributes are filtered once the span
+// ends, as handlers keep setting them until then.
+type attributeFilterProcessor struct {
+	next   sdktrace.SpanProcessor
+	filter *attributeFilter
+}
+
+func newAttributeFilterProcessor(next sdktrace.SpanProcessor, filter *attributeFilter) sdktrace.SpanProcessor {
+	return &attributeFilterProcessor{next: next, filter: filter}
+}
+
+func (p *attributeFilterProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *attributeFilterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	var events []sdktrace.Event
+	for _, event := range s.Events() {
+		event.Attributes = p.filter.apply(event.Attributes)
+		events = append(events, event)
+	}
+	p.next.OnEnd(filteredSpan{ReadOnlySpan: s, attrs: p.filter.apply(s.Attributes()), events: events})
+}
+
+func (p *attributeFilterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *attributeFilterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// filteredSpan is an ended span with its attributes and events replaced.
+type filteredSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+func (s filteredSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+func (s filteredSpan) Events() []sdktrace.Event {
+	return s.events
+}
@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAttributeFilterFromEnv(t *testing.T) {
+	if f := attributeFilterFromEnv(); f != nil {
+		t.Errorf("attributeFilterFromEnv() = %+v by default, want no filtering", f)
+	}
+	t.Setenv("CHECKOUT_TRACE_ATTRIBUTES_HASH", " app.user.id, ")
+	f := attributeFilterFromEnv()
+	if f == nil || len(f.hash) != 1 || f.hash[0] != "app.user.id" || f.allow != nil || f.deny != nil {
+		t.Errorf("attributeFilterFromEnv() = %+v, want app.user.id hashed", f)
+	}
+}
+
+// exportedAttributes ends a span carrying attrs in a tracer provider filtering
+// them with f, and returns the attributes that reach the exporter.
+func exportedAttributes(t *testing.T, f *attributeFilter, attrs ...attribute.KeyValue) map[attribute.Key]attribute.Value {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newAttributeFilterProcessor(recorder, f)))
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "PlaceOrder")
+	// attributes set after the span started are filtered too
+	span.SetAttributes(attrs...)
+	span.End()
+
+	if len(recorder.Ended()) != 1 {
+		t.Fatalf("%d spans exported, want 1", len(recorder.Ended()))
+	}
+	got := make(map[attribute.Key]attribute.Value)
+	for _, kv := range recorder.Ended()[0].Attributes() {
+		got[kv.Key] = kv.Value
+	}
+	return got
+}
+
+func TestAttributeFilterDropsAndHashes(t *testing.T) {
+	f := &attributeFilter{deny: []string{"app.order.*"}, hash: []string{"app.user.id"}}
+	got := exportedAttributes(t, f,
+		attribute.String("app.user.id", "user-1"),
+		attribute.String("app.order.id", "order-1"),
+		attribute.Float64("app.order.amount", 562.86),
+		attribute.Int("app.order.items.count", 3),
+		attribute.String("app.user.currency", "USD"),
+		attribute.String("rpc.method", "PlaceOrder"),
+	)
+
+	for _, key := range []attribute.Key{"app.order.id", "app.order.amount", "app.order.items.count"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("denied attribute %s was exported", key)
+		}
+	}
+	if v := got["app.user.id"].AsString(); v == "user-1" || v != hashAttributeValue(attribute.StringValue("user-1")) || len(v) != 16 {
+		t.Errorf("app.user.id = %q, want a 16-digit hash of the user ID", v)
+	}
+	if got["app.user.currency"].AsString() != "USD" || got["rpc.method"].AsString() != "PlaceOrder" {
+		t.Errorf("exported attributes = %v, want the others kept", got)
+	}
+}
+
+func TestAttributeFilterAllowList(t *testing.T) {
+	f := &attributeFilter{allow: []string{"app.user.currency", "app.cart.*"}, deny: []string{"app.cart.error"}}
+	got := exportedAttributes(t, f,
+		attribute.String("app.user.id", "user-1"),
+		attribute.String("app.user.currency", "USD"),
+		attribute.Int("app.cart.items.count", 3),
+		attribute.String("app.cart.error", "connection refused"),
+		attribute.String("rpc.method", "PlaceOrder"),
+	)
+
+	want := []attribute.Key{"app.user.currency", "app.cart.items.count", "rpc.method"}
+	if len(got) != len(want) {
+		t.Errorf("exported attributes = %v, want only %v", got, want)
+	}
+	for _, key := range want {
+		if _, ok := got[key]; !ok {
+			t.Errorf("allowed attribute %s was dropped", key)
+		}
+	}
+}
+
+func TestAttributeFilterEventAttributes(t *testing.T) {
+	f := &attributeFilter{deny: []string{"app.cart.error"}, hash: []string{"app.user.id"}}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newAttributeFilterProcessor(recorder, f)))
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "PlaceOrder")
+	span.AddEvent("cart unavailable, treated as empty", trace.WithAttributes(
+		attribute.String("app.cart.error", "connection refused"),
+		attribute.String("app.user.id", "user-1"),
+		attribute.String("app.user.currency", "USD"),
+	))
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 {
+		t.Fatalf("%d events exported, want 1", len(events))
+	}
+	got := make(map[attribute.Key]attribute.Value)
+	for _, kv := range events[0].Attributes {
+		got[kv.Key] = kv.Value
+	}
+	if _, ok := got["app.cart.error"]; ok {
+		t.Error("denied event attribute app.cart.error was exported")
+	}
+	if v := got["app.user.id"].AsString(); v != hashAttributeValue(attribute.StringValue("user-1")) {
+		t.Errorf("event attribute app.user.id = %q, want it hashed", v)
+	}
+	if got["app.user.currency"].AsString() != "USD" {
+		t.Errorf("event attributes = %v, want the others kept", got)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func auditRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("audit line %q is not JSON: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestPlaceOrderAuditRecords(t *testing.T) {
+	var buf bytes.Buffer
+	tc := newTestCheckout(t)
+	tc.svc.audit = NewAuditLogger(&buf, "pepper")
+	tc.svc.audit.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	req := testPlaceOrderRequest()
+	resp, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	tc.payment.err = status.Error(codes.InvalidArgument, "card 4432-8015-6152-0454 declined")
+	if _, err := tc.svc.PlaceOrder(context.Background(), req); err == nil {
+		t.Fatal("PlaceOrder() with a declined card succeeded")
+	}
+
+	records := auditRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d audit records, want 2", len(records))
+	}
+	placed, failed := records[0], records[1]
+	want := map[string]any{
+		"order_id":  resp.GetOrder().GetOrderId(),
+		"user_hash": NewAuditLogger(nil, "pepper").hashUser(req.UserId),
+		"amount":    "562.86",
+		"currency":  "USD",
+		"timestamp": "2024-05-01T12:00:00Z",
+		"outcome":   "placed",
+	}
+	for k, v := range want {
+		if placed[k] != v {
+			t.Errorf("placed record[%q] = %v, want %v", k, placed[k], v)
+		}
+	}
+	if failed["outcome"] != "failed" || failed["code"] != "Internal" || failed["order_id"] == "" {
+		t.Errorf("failed record = %v, want a failed outcome with its code", failed)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{req.UserId, req.Email, req.CreditCard.CreditCardNumber, req.Address.StreetAddress, "card", "cvv"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("audit log contains %q:\n%s", secret, out)
+		}
+	}
+	allowed := map[string]bool{"order_id": true, "user_hash": true, "amount": true, "currency": true, "timestamp": true, "outcome": true, "code": true}
+	for _, rec := range records {
+		for k := range rec {
+			if !allowed[k] {
+				t.Errorf("audit record has unexpected field %q", k)
+			}
+		}
+	}
+}
+
+func TestAuditLoggerHashesUsers(t *testing.T) {
+	a, b := NewAuditLogger(nil, "one"), NewAuditLogger(nil, "two")
+	if a.hashUser("user-1") != a.hashUser("user-1") {
+		t.Error("hashUser() is not stable")
+	}
+	if a.hashUser("user-1") == a.hashUser("user-2") || a.hashUser("user-1") == b.hashUser("user-1") {
+		t.Error("hashUser() collides across users or salts")
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := map[string]*pb.Money{
+		"562.86":   {Units: 562, Nanos: 860000000},
+		"10":       {Units: 10},
+		"0.000001": {Nanos: 1000},
+		"-1.5":     {Units: -1, Nanos: -500000000},
+	}
+	for want, m := range tests {
+		if got := formatAmount(m); got != want {
+			t.Errorf("formatAmount(%v) = %q, want %q", m, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// placeOrderDurationViews returns a view applying the explicit bucket
+// boundaries in CHECKOUT_PLACE_ORDER_BUCKETS_MS, a comma-separated list of
+// milliseconds such as "50,100,250,500,1000", to checkout.place_order_duration.
+// It returns no view when the variable is unset, keeping the SDK defaults.
+func placeOrderDurationViews() ([]sdkmetric.View, error) {
+	s := os.Getenv("CHECKOUT_PLACE_ORDER_BUCKETS_MS")
+	if s == "" {
+		return nil, nil
+	}
+	bounds, err := parseBucketBoundaries(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHECKOUT_PLACE_ORDER_BUCKETS_MS: %w", err)
+	}
+	return []sdkmetric.View{sdkmetric.NewView(
+		sdkmetric.Instrument{Name: "checkout.place_order_duration"},
+		sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: bounds}},
+	)}, nil
+}
+
+// parseBucketBoundaries parses comma-separated, strictly increasing bucket
+// boundaries.
+func parseBucketBoundaries(s string) ([]float64, error) {
+	var bounds []float64
+	for _, field := range strings.Split(s, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bucket boundary %q is not a number", field)
+		}
+		if len(bounds) > 0 && b <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket boundaries %v are not strictly increasing", append(slices.Clone(bounds), b))
+		}
+		bounds = append(bounds, b)
+	}
+	return bounds, nil
+}
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// placeOrderBounds records a duration through a meter provider with views and
+// returns the bucket boundaries of checkout.place_order_duration.
+func placeOrderBounds(t *testing.T, views []sdkmetric.View) []float64 {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views...))
+	hist, err := mp.Meter("checkoutservice").Int64Histogram("checkout.place_order_duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Record(context.Background(), 120)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0].Bounds
+}
+
+func TestPlaceOrderDurationBuckets(t *testing.T) {
+	t.Setenv("CHECKOUT_PLACE_ORDER_BUCKETS_MS", "50, 100,250,1000")
+	views, err := placeOrderDurationViews()
+	if err != nil {
+		t.Fatalf("placeOrderDurationViews() error = %v", err)
+	}
+	if got := placeOrderBounds(t, views); !slices.Equal(got, []float64{50, 100, 250, 1000}) {
+		t.Errorf("bucket boundaries = %v, want the configured ones", got)
+	}
+
+	t.Setenv("CHECKOUT_PLACE_ORDER_BUCKETS_MS", "")
+	views, err = placeOrderDurationViews()
+	if err != nil || len(views) != 0 {
+		t.Fatalf("placeOrderDurationViews() when unset = %v, %v, want no view", views, err)
+	}
+	if got, want := placeOrderBounds(t, nil), placeOrderBounds(t, views); !slices.Equal(got, want) || len(got) == 0 {
+		t.Errorf("bucket boundaries when unset = %v, want the SDK defaults", got)
+	}
+
+	for _, bad := range []string{"50,abc", "100,50", "50,50", ","} {
+		t.Setenv("CHECKOUT_PLACE_ORDER_BUCKETS_MS", bad)
+		if _, err := placeOrderDurationViews(); err == nil {
+			t.Errorf("placeOrderDurationViews() with %q succeeded", bad)
+		}
+	}
+}
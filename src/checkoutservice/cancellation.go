@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// contextFailure returns context.Canceled when a failed order was abandoned by
+// the client and context.DeadlineExceeded when it ran out of time, judging by
+// ctx first and then by err. It returns nil for any other failure.
+func contextFailure(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	switch {
+	case errors.Is(err, context.Canceled), status.Code(err) == codes.Canceled:
+		return context.Canceled
+	case errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// recordContextFailure tells a client disconnect apart from our own timeout in
+// the logs, the span status and the checkout.cancelled and checkout.timeout
+// counters. It returns the status PlaceOrder should answer with, which is err
+// itself unless the order failed because of its context.
+func recordContextFailure(ctx context.Context, err error) error {
+	cause := contextFailure(ctx, err)
+	if cause == nil {
+		return err
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if cause == context.Canceled {
+		checkoutCancelled.Add(ctx, 1)
+		span.SetStatus(otelcodes.Error, "order cancelled by the client")
+		logger.WarnContext(ctx, "order cancelled by the client", "error", err.Error(), "request_id", requestIDFromContext(ctx))
+	} else {
+		checkoutTimeout.Add(ctx, 1)
+		span.SetStatus(otelcodes.Error, "order timed out")
+		logger.ErrorContext(ctx, "order timed out", "error", err.Error(), "request_id", requestIDFromContext(ctx))
+	}
+	return status.FromContextError(cause).Err()
+}
+
+// checkDeadline rejects an order whose deadline leaves less than minimum to
+// place it, before anything is charged. Orders without a deadline always pass,
+// and so does every order when minimum is not positive.
+func checkDeadline(ctx context.Context, minimum time.Duration) error {
+	if minimum <= 0 {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	if remaining := time.Until(deadline); remaining < minimum {
+		return status.Errorf(codes.DeadlineExceeded, "deadline too short: %v left, at least %v needed",
+			remaining.Round(time.Millisecond), minimum)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestContextFailure(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	live := context.Background()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{"client went away", cancelled, errors.New("cart failed"), context.Canceled},
+		{"deadline passed", expired, errors.New("cart failed"), context.DeadlineExceeded},
+		{"wrapped cancellation", live, fmt.Errorf("prepare: %w", context.Canceled), context.Canceled},
+		{"downstream deadline", live, status.Error(codes.DeadlineExceeded, "slow"), context.DeadlineExceeded},
+		{"other failure", live, status.Error(codes.Unavailable, "down"), nil},
+		{"no failure", cancelled, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextFailure(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("contextFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderContextErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    func() (context.Context, context.CancelFunc)
+		metric string
+		want   codes.Code
+	}{
+		{"cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		}, "checkout.cancelled", codes.Canceled},
+		{"deadline exceeded", func() (context.Context, context.CancelFunc) {
+			return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		}, "checkout.timeout", codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			tc := newTestCheckout(t)
+			tc.cart.err = status.FromContextError(ctx.Err()).Err()
+			before := counterValue(t, tt.metric, "", "")
+
+			_, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("PlaceOrder() error = %v, want %v", err, tt.want)
+			}
+			if got := counterValue(t, tt.metric, "", ""); got != before+1 {
+				t.Errorf("%s = %d, want %d", tt.metric, got, before+1)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderMinDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration // 0 for none
+		wantCode codes.Code
+	}{
+		{"too short", 100 * time.Millisecond, codes.DeadlineExceeded},
+		{"adequate", 10 * time.Second, codes.OK},
+		{"no deadline", 0, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCheckout(t)
+			tc.svc.minDeadline = time.Second
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			_, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("PlaceOrder() code = %v, want %v (err: %v)", got, tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK && (len(tc.payment.charges) != 0 || tc.catalog.getCalls.Load() != 0) {
+				t.Error("an order with too short a deadline was priced or charged")
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/protobuf/proto"
+)
+
+// mergeDuplicateLines returns items with the lines of the same product merged
+// into the first of them, their quantities summed. Lines keep the order in
+// which their product first appears, and items is left unmodified.
+func mergeDuplicateLines(items []*pb.CartItem) []*pb.CartItem {
+	merged := make([]*pb.CartItem, 0, len(items))
+	lines := make(map[string]*pb.CartItem, len(items))
+	for _, item := range items {
+		if line, ok := lines[item.GetProductId()]; ok {
+			line.Quantity += item.GetQuantity()
+			continue
+		}
+		line := proto.Clone(item).(*pb.CartItem)
+		lines[item.GetProductId()] = line
+		merged = append(merged, line)
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+func TestMergeDuplicateLines(t *testing.T) {
+	items := []*pb.CartItem{
+		{ProductId: "A", Quantity: 1},
+		{ProductId: "B", Quantity: 2},
+		{ProductId: "A", Quantity: 3},
+		{ProductId: "C", Quantity: 1},
+		{ProductId: "B", Quantity: 1},
+	}
+	got := mergeDuplicateLines(items)
+
+	want := []*pb.CartItem{{ProductId: "A", Quantity: 4}, {ProductId: "B", Quantity: 3}, {ProductId: "C", Quantity: 1}}
+	if len(got) != len(want) {
+		t.Fatalf("mergeDuplicateLines() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].GetProductId() != want[i].ProductId || got[i].GetQuantity() != want[i].Quantity {
+			t.Errorf("line %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if items[0].Quantity != 1 {
+		t.Errorf("mergeDuplicateLines() modified its argument: %v", items[0])
+	}
+}
+
+func TestPlaceOrderMergesDuplicateLines(t *testing.T) {
+	for _, merge := range []bool{false, true} {
+		tc := newTestCheckout(t)
+		tc.cart.items = []*pb.CartItem{
+			{ProductId: "OLJCESPC7Z", Quantity: 1},
+			{ProductId: "66VCHSJNUP", Quantity: 1},
+			{ProductId: "OLJCESPC7Z", Quantity: 1},
+		}
+		tc.svc.mergeDuplicateLines = merge
+
+		resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+
+		wantLines, wantLookups := 3, int64(3)
+		if merge {
+			wantLines, wantLookups = 2, 2
+		}
+		items := resp.GetOrder().GetItems()
+		if len(items) != wantLines {
+			t.Errorf("with merging %v, order has %d lines, want %d", merge, len(items), wantLines)
+		}
+		if merge && (items[0].GetItem().GetProductId() != "OLJCESPC7Z" || items[0].GetItem().GetQuantity() != 2) {
+			t.Errorf("merged line = %v, want 2 x OLJCESPC7Z", items[0].GetItem())
+		}
+		if got := tc.catalog.getCalls.Load(); got != wantLookups {
+			t.Errorf("with merging %v, GetProduct called %d times, want %d", merge, got, wantLookups)
+		}
+		// the total is the same either way: 2 x 101.96 + 349.95 + 8.99 shipping
+		if got := tc.payment.charges[0].GetAmount(); got.GetUnits() != 562 || got.GetNanos() != 860000000 {
+			t.Errorf("with merging %v, charged %v, want USD 562.86", merge, got)
+		}
+	}
+}
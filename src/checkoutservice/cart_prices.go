@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkCartPrice compares the price the cart carries for item, if any, with
+// the catalog price of product. A mismatch is recorded as a span event and,
+// when strictCartPrices is set, rejects the order with FailedPrecondition so
+// the shopper can review the new price.
+func (cs *checkoutService) checkCartPrice(ctx context.Context, item *pb.CartItem, product *pb.Product) error {
+	cartPrice, catalogPrice := item.GetPriceUsd(), product.GetPriceUsd()
+	if cartPrice == nil || sameMoney(cartPrice, catalogPrice) {
+		return nil
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("cart price mismatch", trace.WithAttributes(
+		attribute.String("app.product.id", item.GetProductId()),
+		attribute.String("app.cart.price", formatMoney(cartPrice)),
+		attribute.String("app.catalog.price", formatMoney(catalogPrice)),
+		attribute.Bool("app.cart.price_rejected", cs.strictCartPrices),
+	))
+	logger.WarnContext(ctx, "cart price differs from the catalog", "product_id", item.GetProductId(),
+		"cart_price", formatMoney(cartPrice), "catalog_price", formatMoney(catalogPrice))
+	if cs.strictCartPrices {
+		return status.Errorf(codes.FailedPrecondition, "price of product %q changed from %s to %s",
+			item.GetProductId(), formatMoney(cartPrice), formatMoney(catalogPrice))
+	}
+	return nil
+}
+
+func sameMoney(a, b *pb.Money) bool {
+	return a.GetCurrencyCode() == b.GetCurrencyCode() && a.GetUnits() == b.GetUnits() && a.GetNanos() == b.GetNanos()
+}
+
+// formatMoney renders m as its currency code and amount, such as USD 101.96.
+func formatMoney(m *pb.Money) string {
+	return fmt.Sprintf("%s %s", m.GetCurrencyCode(), formatAmount(m))
+}
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func hasSpanEvent(recorder *tracetest.SpanRecorder, name string) bool {
+	for _, s := range recorder.Ended() {
+		for _, e := range s.Events() {
+			if e.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCartPriceCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    *pb.Money
+		strict   bool
+		want     codes.Code
+		mismatch bool
+	}{
+		{"cart without prices", nil, true, codes.OK, false},
+		{"matching price", &pb.Money{CurrencyCode: "USD", Units: 101, Nanos: 960000000}, true, codes.OK, false},
+		{"stale price", &pb.Money{CurrencyCode: "USD", Units: 99, Nanos: 990000000}, false, codes.OK, true},
+		{"stale price in strict mode", &pb.Money{CurrencyCode: "USD", Units: 99, Nanos: 990000000}, true, codes.FailedPrecondition, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			tc := newTestCheckout(t)
+			tc.cart.items[0].PriceUsd = tt.price
+			tc.svc.strictCartPrices = tt.strict
+
+			_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("PlaceOrder() error = %v, want %v", err, tt.want)
+			}
+			if got := hasSpanEvent(recorder, "cart price mismatch"); got != tt.mismatch {
+				t.Errorf("cart price mismatch event = %v, want %v", got, tt.mismatch)
+			}
+			if charged := len(tc.payment.charges) > 0; charged != (tt.want == codes.OK) {
+				t.Errorf("card charged = %v with error %v", charged, err)
+			}
+		})
+	}
+}
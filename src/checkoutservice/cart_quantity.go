@@ -0,0 +1,28 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dropEmptyLines handles cart lines with a zero or negative quantity, which
+// would otherwise be priced and added to the order. By default they are left
+// out of the returned items; with CHECKOUT_STRICT_QUANTITIES set, the cart is
+// rejected with an InvalidArgument status instead. items is left unmodified.
+func (cs *checkoutService) dropEmptyLines(items []*pb.CartItem) ([]*pb.CartItem, error) {
+	kept := make([]*pb.CartItem, 0, len(items))
+	for _, item := range items {
+		if item.GetQuantity() > 0 {
+			kept = append(kept, item)
+			continue
+		}
+		if cs.strictQuantities {
+			return nil, status.Errorf(codes.InvalidArgument, "quantity %d of product %q is not positive",
+				item.GetQuantity(), item.GetProductId())
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlaceOrderEmptyLines(t *testing.T) {
+	for _, quantity := range []int32{0, -2} {
+		for _, strict := range []bool{false, true} {
+			tc := newTestCheckout(t)
+			tc.svc.strictQuantities = strict
+			tc.cart.items = []*pb.CartItem{
+				{ProductId: "OLJCESPC7Z", Quantity: 1},
+				{ProductId: "66VCHSJNUP", Quantity: quantity},
+			}
+
+			resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			if strict {
+				if status.Code(err) != codes.InvalidArgument {
+					t.Errorf("strict PlaceOrder() with quantity %d error = %v, want InvalidArgument", quantity, err)
+				}
+				if len(tc.payment.charges) != 0 {
+					t.Errorf("strict PlaceOrder() with quantity %d charged the card", quantity)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Fatalf("PlaceOrder() with quantity %d error = %v", quantity, err)
+			}
+			items := resp.GetOrder().GetItems()
+			if len(items) != 1 || items[0].GetItem().GetProductId() != "OLJCESPC7Z" {
+				t.Errorf("order with a line of quantity %d = %v, want only OLJCESPC7Z", quantity, items)
+			}
+			if got := tc.catalog.getCalls.Load(); got != 1 {
+				t.Errorf("GetProduct called %d times with a line of quantity %d, want 1", got, quantity)
+			}
+		}
+	}
+}
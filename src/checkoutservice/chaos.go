@@ -0,0 +1,41 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failAfterN simulates a resource leak for the checkoutFailAfterN flag: once
+// N orders have been placed successfully, every following order fails until
+// the service is restarted.
+type failAfterN struct {
+	placed  atomic.Int64
+	tripped atomic.Bool
+}
+
+// check returns an error if the checkoutFailAfterN threshold has been reached,
+// adding a span event the first time it is.
+func (f *failAfterN) check(ctx context.Context, cs *checkoutService) error {
+	n := cs.getIntFeatureFlag(ctx, "checkoutFailAfterN")
+	if n <= 0 || f.placed.Load() < int64(n) {
+		return nil
+	}
+	if !f.tripped.Swap(true) {
+		trace.SpanFromContext(ctx).AddEvent("checkoutFailAfterN threshold crossed",
+			trace.WithAttributes(attribute.Int("app.checkout.fail_after_n", n)))
+		logger.WarnContext(ctx, "checkoutFailAfterN threshold crossed, failing every order until restart", "orders", n)
+	}
+	return status.Error(codes.Internal, "checkout is out of resources")
+}
+
+// orderPlaced counts a successfully placed order.
+func (f *failAfterN) orderPlaced() {
+	f.placed.Add(1)
+}
@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFailAfterN(t *testing.T) {
+	useFlagProvider(t, &recordingProvider{ints: map[string]int64{"checkoutFailAfterN": 2}})
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+
+	for i := 1; i <= 2; i++ {
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+			t.Fatalf("order %d: PlaceOrder() error = %v", i, err)
+		}
+	}
+
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	if _, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest()); status.Code(err) != codes.Internal {
+		t.Fatalf("order 3: PlaceOrder() error = %v, want Internal", err)
+	}
+	if len(tc.payment.charges) != 2 {
+		t.Errorf("card charged %d times, want 2", len(tc.payment.charges))
+	}
+
+	crossed := false
+	for _, span := range recorder.Ended() {
+		for _, event := range span.Events() {
+			if span.Name() == "PlaceOrder" && event.Name == "checkoutFailAfterN threshold crossed" {
+				crossed = true
+			}
+		}
+	}
+	if !crossed {
+		t.Error("no span event recorded when the threshold was crossed")
+	}
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); status.Code(err) != codes.Internal {
+		t.Errorf("order 4: PlaceOrder() error = %v, want Internal until restart", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultChargeRetryDelay is the pause between charge attempts when
+// CHECKOUT_CHARGE_RETRY_DELAY_MS is not set.
+const defaultChargeRetryDelay = 100 * time.Millisecond
+
+// chargeAttempts returns how many times a charge may be attempted. Retrying
+// is only safe when the payment service deduplicates charges by idempotency
+// key, which setting CHECKOUT_CHARGE_RETRIES asserts, so charges of orders
+// without a key are attempted once. The demo's paymentservice does not
+// deduplicate charges: only set CHECKOUT_CHARGE_RETRIES in front of a payment
+// service that does.
+func (cs *checkoutService) chargeAttempts(idempotencyKey string) int {
+	if idempotencyKey == "" {
+		return 1
+	}
+	return 1 + max(cs.chargeRetries, 0)
+}
+
+// chargeIdempotencyKey returns the idempotency key sent with the charges of
+// an order placed by userID with key, or "" for an order without a key. It is
+// derived from the order ID as well, so two users sending the same key never
+// share a charge, and a retry of a failed order, which gets a new order ID,
+// is charged again rather than answered with a charge that may have been
+// refunded.
+func chargeIdempotencyKey(userID, key, orderID string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userID + "\x00" + key + "\x00" + orderID))
+	return hex.EncodeToString(sum[:])
+}
+
+// chargeRetryable reports whether a failed charge may be retried, which is
+// when the payment service could not be reached.
+func chargeRetryable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// waitChargeRetry pauses before the next charge attempt, returning early with
+// an error when ctx is done.
+func (cs *checkoutService) waitChargeRetry(ctx context.Context) error {
+	timer := time.NewTimer(cs.chargeRetryDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
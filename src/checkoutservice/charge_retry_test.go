@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+func TestChargeRetry(t *testing.T) {
+	t.Run("retried with the idempotency key", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.chargeRetries = 2
+		tc.payment.chargeErrs = 1
+		req := testPlaceOrderRequest()
+		req.IdempotencyKey = "order-42"
+
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+		if len(tc.payment.charges) != 2 {
+			t.Fatalf("charge attempted %d times, want 2", len(tc.payment.charges))
+		}
+		key := tc.payment.charges[0].IdempotencyKey
+		if key == "" || key == "order-42" {
+			t.Errorf("charge carried idempotency key %q, want one derived from order-42", key)
+		}
+		if retried := tc.payment.charges[1].IdempotencyKey; retried != key {
+			t.Errorf("retried charge carried idempotency key %q, want %q", retried, key)
+		}
+	})
+
+	t.Run("keys are scoped to the user and the order", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		req := testPlaceOrderRequest()
+		req.IdempotencyKey = "order-44"
+		other := testPlaceOrderRequest()
+		other.UserId = "user-2"
+		other.IdempotencyKey = req.IdempotencyKey
+
+		for _, r := range []*pb.PlaceOrderRequest{req, other} {
+			if _, err := tc.svc.PlaceOrder(context.Background(), r); err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+		}
+		// without an idempotency cache, the same request places a new order
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+		keys := make(map[string]bool)
+		for _, charge := range tc.payment.charges {
+			keys[charge.IdempotencyKey] = true
+		}
+		if len(keys) != 3 {
+			t.Errorf("3 orders were charged with %d distinct idempotency keys, want 3", len(keys))
+		}
+	})
+
+	t.Run("not retried without a key", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.chargeRetries = 2
+		tc.payment.chargeErrs = 1
+
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+			t.Fatalf("PlaceOrder() succeeded, want the unavailable charge to fail the order")
+		}
+		if len(tc.payment.charges) != 1 {
+			t.Errorf("charge attempted %d times, want 1", len(tc.payment.charges))
+		}
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.chargeRetries = 1
+		tc.payment.chargeErrs = 5
+		req := testPlaceOrderRequest()
+		req.IdempotencyKey = "order-43"
+
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); err == nil {
+			t.Fatalf("PlaceOrder() succeeded, want the charge to fail")
+		}
+		if len(tc.payment.charges) != 2 {
+			t.Errorf("charge attempted %d times, want 2", len(tc.payment.charges))
+		}
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+)
+
+// confirmationPayloadVersion is the version of the order confirmation sent to
+// the email service. Version 1 payloads carried no version and only the email
+// address and the raw order.
+const confirmationPayloadVersion = 2
+
+// confirmationPayload is the body of an order confirmation. Order is the raw
+// OrderResult, kept for templates written against version 1; Summary is the
+// stable, preformatted view templates should use, with amounts formatted for
+// Locale.
+type confirmationPayload struct {
+	Version int             `json:"version"`
+	Email   string          `json:"email"`
+	Locale  string          `json:"locale,omitempty"`
+	Order   *pb.OrderResult `json:"order"`
+	Summary orderSummary    `json:"summary"`
+}
+
+// orderSummary is an order ready to be rendered, with every amount formatted
+// by money.Format.
+type orderSummary struct {
+	OrderID            string        `json:"order_id"`
+	Currency           string        `json:"currency"`
+	Items              []summaryLine `json:"items"`
+	ShippingCost       string        `json:"shipping_cost"`
+	ShippingTrackingID string        `json:"shipping_tracking_id"`
+	ShippingAddress    string        `json:"shipping_address"`
+	Subtotal           string        `json:"subtotal"`
+	Total              string        `json:"total"`
+}
+
+type summaryLine struct {
+	ProductID string `json:"product_id"`
+	Quantity  int32  `json:"quantity"`
+	UnitPrice string `json:"unit_price"`
+	LineTotal string `json:"line_total"`
+}
+
+// newConfirmationPayload builds the confirmation of order for email, written
+// for locale.
+func newConfirmationPayload(email string, order *pb.OrderResult, locale string) (confirmationPayload, error) {
+	summary, err := newOrderSummary(order, locale)
+	if err != nil {
+		return confirmationPayload{}, err
+	}
+	return confirmationPayload{
+		Version: confirmationPayloadVersion,
+		Email:   email,
+		Locale:  locale,
+		Order:   order,
+		Summary: summary,
+	}, nil
+}
+
+// newOrderSummary formats order for rendering, with amounts formatted for
+// locale by money.FormatLocale.
+func newOrderSummary(order *pb.OrderResult, locale string) (orderSummary, error) {
+	currency := order.GetShippingCost().GetCurrencyCode()
+	summary := orderSummary{
+		OrderID:            order.GetOrderId(),
+		Currency:           currency,
+		Items:              make([]summaryLine, 0, len(order.GetItems())),
+		ShippingCost:       money.FormatLocale(order.GetShippingCost(), locale),
+		ShippingTrackingID: order.GetShippingTrackingId(),
+		ShippingAddress:    formatAddress(order.GetShippingAddress()),
+	}
+
+	var lines []*pb.Money
+	for _, it := range order.GetItems() {
+		lineTotal := money.MultiplySlow(it.GetCost(), uint32(it.GetItem().GetQuantity()))
+		lines = append(lines, lineTotal)
+		summary.Items = append(summary.Items, summaryLine{
+			ProductID: it.GetItem().GetProductId(),
+			Quantity:  it.GetItem().GetQuantity(),
+			UnitPrice: money.FormatLocale(it.GetCost(), locale),
+			LineTotal: money.FormatLocale(lineTotal, locale),
+		})
+	}
+	subtotal, err := money.SumAll(currency, lines...)
+	if err != nil {
+		return orderSummary{}, fmt.Errorf("failed to total order %s: %w", order.GetOrderId(), err)
+	}
+	total, err := money.SumAll(currency, subtotal, order.GetShippingCost())
+	if err != nil {
+		return orderSummary{}, fmt.Errorf("failed to total order %s: %w", order.GetOrderId(), err)
+	}
+	summary.Subtotal = money.FormatLocale(subtotal, locale)
+	summary.Total = money.FormatLocale(total, locale)
+	return summary, nil
+}
+
+// formatAddress renders a as a single line, such as "1600 Amphitheatre
+// Parkway, Mountain View, CA, US 94043".
+func formatAddress(a *pb.Address) string {
+	var parts []string
+	for _, part := range []string{a.GetStreetAddress(), a.GetCity(), a.GetState()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if last := strings.TrimSpace(a.GetCountry() + " " + a.GetZipCode()); last != "" {
+		parts = append(parts, last)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOrderConfirmationPayload(t *testing.T) {
+	tc := newTestCheckout(t)
+	resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	var payload struct {
+		Version int             `json:"version"`
+		Email   string          `json:"email"`
+		Order   json.RawMessage `json:"order"`
+		Summary map[string]any  `json:"summary"`
+	}
+	if err := json.Unmarshal(tc.email.lastPayload(), &payload); err != nil {
+		t.Fatalf("confirmation is not JSON: %v", err)
+	}
+	if payload.Version != 2 || payload.Email != "someone@example.com" || len(payload.Order) == 0 {
+		t.Errorf("confirmation = version %d for %q with order %s, want version 2 with the raw order", payload.Version, payload.Email, payload.Order)
+	}
+
+	want := map[string]any{
+		"order_id":             resp.GetOrder().GetOrderId(),
+		"currency":             "USD",
+		"shipping_cost":        "USD 8.99",
+		"shipping_tracking_id": "TRACK-1",
+		"shipping_address":     "1600 Amphitheatre Parkway, Mountain View, CA, US 94043",
+		"subtotal":             "USD 553.87",
+		"total":                "USD 562.86",
+		"items": []any{
+			map[string]any{"product_id": "OLJCESPC7Z", "quantity": 2.0, "unit_price": "USD 101.96", "line_total": "USD 203.92"},
+			map[string]any{"product_id": "66VCHSJNUP", "quantity": 1.0, "unit_price": "USD 349.95", "line_total": "USD 349.95"},
+		},
+	}
+	if !reflect.DeepEqual(payload.Summary, want) {
+		t.Errorf("summary = %v, want %v", payload.Summary, want)
+	}
+}
+
+func TestOrderConfirmationLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		locale         string
+		acceptLanguage string
+		defaultLocale  string
+		wantLocale     string
+		wantTotal      string
+	}{
+		{name: "request locale", locale: "de-DE", acceptLanguage: "fr-CH, fr;q=0.9", wantLocale: "de-DE", wantTotal: "USD 562,86"},
+		{name: "accept-language", acceptLanguage: "en-GB;q=1, de;q=0.5", wantLocale: "en-GB", wantTotal: "USD 562.86"},
+		{name: "malformed request locale", locale: "de_DE!", acceptLanguage: "fr", wantLocale: "fr", wantTotal: "USD 562,86"},
+		{name: "default locale", defaultLocale: "es-ES", wantLocale: "es-ES", wantTotal: "USD 562,86"},
+		{name: "no locale", wantLocale: "", wantTotal: "USD 562.86"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCheckout(t)
+			tc.svc.defaultLocale = tt.defaultLocale
+			req := testPlaceOrderRequest()
+			req.Locale = tt.locale
+			ctx := context.Background()
+			if tt.acceptLanguage != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("accept-language", tt.acceptLanguage))
+			}
+			if _, err := tc.svc.PlaceOrder(ctx, req); err != nil {
+				t.Fatalf("PlaceOrder() error = %v", err)
+			}
+
+			var payload struct {
+				Locale  string `json:"locale"`
+				Summary struct {
+					Total string `json:"total"`
+				} `json:"summary"`
+			}
+			if err := json.Unmarshal(tc.email.lastPayload(), &payload); err != nil {
+				t.Fatalf("confirmation is not JSON: %v", err)
+			}
+			if payload.Locale != tt.wantLocale || payload.Summary.Total != tt.wantTotal {
+				t.Errorf("confirmation locale %q with total %q, want %q with %q", payload.Locale, payload.Summary.Total, tt.wantLocale, tt.wantTotal)
+			}
+		})
+	}
+}
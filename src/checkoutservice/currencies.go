@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+const defaultCurrencyCacheTTL = 5 * time.Minute
+
+// currencyCache holds the currency service's list of supported currencies for
+// ttl, so that listing them through checkout does not hit the currency service
+// on every call. A nil *currencyCache never caches.
+type currencyCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	codes     []string
+	fetchedAt time.Time
+}
+
+func newCurrencyCache(ttl time.Duration) *currencyCache {
+	return &currencyCache{ttl: ttl, now: time.Now}
+}
+
+// get returns the cached currency codes, or false if there are none or they
+// have expired.
+func (c *currencyCache) get() ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.codes == nil || c.now().Sub(c.fetchedAt) >= c.ttl {
+		return nil, false
+	}
+	return c.codes, true
+}
+
+func (c *currencyCache) set(codes []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.codes = codes
+	c.fetchedAt = c.now()
+}
+
+// GetSupportedCurrencies returns the currencies supported by the currency
+// service, so clients can build a currency picker without calling it directly.
+func (cs *checkoutService) GetSupportedCurrencies(ctx context.Context, _ *pb.Empty) (*pb.GetSupportedCurrenciesResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if cached, ok := cs.currencies.get(); ok {
+		span.SetAttributes(attribute.Bool("app.currencies.cached", true))
+		return &pb.GetSupportedCurrenciesResponse{CurrencyCodes: slices.Clone(cached)}, nil
+	}
+
+	done := cs.observeDependency(ctx, dependencyCurrency)
+	resp, err := cs.currencySvcClient.GetSupportedCurrencies(ctx, &pb.Empty{})
+	done()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get supported currencies: %v", err)
+	}
+	cs.currencies.set(slices.Clone(resp.GetCurrencyCodes()))
+	span.SetAttributes(
+		attribute.Bool("app.currencies.cached", false),
+		attribute.Int("app.currencies.count", len(resp.GetCurrencyCodes())),
+	)
+	return &pb.GetSupportedCurrenciesResponse{CurrencyCodes: resp.GetCurrencyCodes()}, nil
+}
+
+// isUnknownCurrency reports whether err is the currency service rejecting a
+// currency code it does not support, which is a caller error rather than an
+// outage.
+func isUnknownCurrency(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	if s.Code() == codes.InvalidArgument || s.Code() == codes.NotFound {
+		return true
+	}
+	msg := strings.ToLower(s.Message())
+	return strings.Contains(msg, "unknown code") || strings.Contains(msg, "unknown currency") || strings.Contains(msg, "unsupported currency")
+}
@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+func TestGetSupportedCurrencies(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newCurrencyCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	currency := &fakeCurrencyClient{supported: []string{"USD", "EUR"}}
+	cs := &checkoutService{currencySvcClient: currency, currencies: cache}
+
+	list := func() []string {
+		t.Helper()
+		resp, err := cs.GetSupportedCurrencies(context.Background(), &pb.Empty{})
+		if err != nil {
+			t.Fatalf("GetSupportedCurrencies: %v", err)
+		}
+		return resp.CurrencyCodes
+	}
+
+	if got := list(); !slices.Equal(got, []string{"USD", "EUR"}) {
+		t.Errorf("currencies = %v, want [USD EUR]", got)
+	}
+
+	currency.supported = []string{"USD", "EUR", "JPY"}
+	now = now.Add(30 * time.Second)
+	if got := list(); len(got) != 2 || currency.listCalls != 1 {
+		t.Errorf("currencies within the TTL = %v after %d calls, want the cached list from 1 call", got, currency.listCalls)
+	}
+
+	now = now.Add(time.Minute)
+	if got := list(); len(got) != 3 || currency.listCalls != 2 {
+		t.Errorf("currencies after the TTL = %v after %d calls, want a refreshed list of 3", got, currency.listCalls)
+	}
+
+	now = now.Add(time.Minute)
+	currency.err = errors.New("currency service down")
+	if _, err := cs.GetSupportedCurrencies(context.Background(), &pb.Empty{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetSupportedCurrencies with a failing currency service = %v, want Unavailable", err)
+	}
+}
+
+func TestIsUnknownCurrency(t *testing.T) {
+	for _, err := range []error{
+		status.Error(codes.InvalidArgument, "invalid currency"),
+		status.Error(codes.NotFound, "XYZ"),
+		status.Error(codes.Unknown, "Unknown code: XYZ"),
+	} {
+		if !isUnknownCurrency(err) {
+			t.Errorf("isUnknownCurrency(%v) = false, want true", err)
+		}
+	}
+	for _, err := range []error{
+		nil,
+		errors.New("unknown currency"),
+		status.Error(codes.Unavailable, "connection refused"),
+		status.Error(codes.Canceled, "conversion failed"),
+	} {
+		if isUnknownCurrency(err) {
+			t.Errorf("isUnknownCurrency(%v) = true, want false", err)
+		}
+	}
+}
+
+func TestPlaceOrderUnknownCurrency(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.currency.err = status.Error(codes.InvalidArgument, "unknown currency code XYZ")
+	tc.svc.fallbackRates = fallbackRates{"EUR": 1, "USD": 1.1305}
+	before := counterValue(t, "checkout.unknown_currency", "app.currency.code", "XYZ")
+
+	req := testPlaceOrderRequest()
+	req.UserCurrency = "XYZ"
+	_, err := tc.svc.PlaceOrder(context.Background(), req)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("PlaceOrder() error = %v, want InvalidArgument", err)
+	}
+	if got := counterValue(t, "checkout.unknown_currency", "app.currency.code", "XYZ"); got <= before {
+		t.Errorf("checkout.unknown_currency = %d, want it incremented from %d", got, before)
+	}
+	if len(tc.payment.charges) != 0 {
+		t.Error("card charged in an unsupported currency")
+	}
+}
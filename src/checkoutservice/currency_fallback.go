@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// fallbackRates are static exchange rates against the euro, used to keep
+// checkout working in a degraded mode while the currency service is down. A
+// nil fallbackRates never converts.
+type fallbackRates map[string]float64
+
+// fallbackRatesFromEnv loads the rates from the file named by
+// CHECKOUT_CURRENCY_FALLBACK_RATES when CHECKOUT_CURRENCY_FALLBACK is true.
+// The file has the format of the currency service's conversion table, such as
+// {"EUR": "1.0", "USD": "1.1305"}.
+func fallbackRatesFromEnv() (fallbackRates, error) {
+	if enabled, _ := strconv.ParseBool(os.Getenv("CHECKOUT_CURRENCY_FALLBACK")); !enabled {
+		return nil, nil
+	}
+	path := os.Getenv("CHECKOUT_CURRENCY_FALLBACK_RATES")
+	if path == "" {
+		return nil, errors.New("CHECKOUT_CURRENCY_FALLBACK requires CHECKOUT_CURRENCY_FALLBACK_RATES")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fallback currency rates: %w", err)
+	}
+	return parseFallbackRates(data)
+}
+
+func parseFallbackRates(data []byte) (fallbackRates, error) {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid fallback currency rates: %w", err)
+	}
+	rates := make(fallbackRates, len(raw))
+	for code, s := range raw {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid fallback rate %q for %s", s, code)
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// convert converts from to toCurrency through the euro, as the currency
+// service does.
+func (r fallbackRates) convert(from *pb.Money, toCurrency string) (*pb.Money, bool) {
+	fromRate, ok := r[from.GetCurrencyCode()]
+	if !ok {
+		return nil, false
+	}
+	toRate, ok := r[toCurrency]
+	if !ok {
+		return nil, false
+	}
+
+	amount := float64(from.GetUnits()) + float64(from.GetNanos())/1e9
+	nanos := int64(math.Round(amount / fromRate * toRate * 1e9))
+	return &pb.Money{
+		CurrencyCode: toCurrency,
+		Units:        nanos / 1e9,
+		Nanos:        int32(nanos % 1e9),
+	}, true
+}
+
+type currencyFallbackKey struct{}
+
+// withCurrencyFallbackMarker returns a context in which conversions that fall
+// back to static rates set the returned flag.
+func withCurrencyFallbackMarker(ctx context.Context) (context.Context, *atomic.Bool) {
+	used := new(atomic.Bool)
+	return context.WithValue(ctx, currencyFallbackKey{}, used), used
+}
+
+func markCurrencyFallback(ctx context.Context) {
+	if used, ok := ctx.Value(currencyFallbackKey{}).(*atomic.Bool); ok {
+		used.Store(true)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlaceOrderCurrencyFallback(t *testing.T) {
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	tc.currency.err = status.Error(codes.Unavailable, "currency service down")
+	rates, err := parseFallbackRates([]byte(`{"EUR": "1.0", "USD": "1.25", "JPY": "125"}`))
+	if err != nil {
+		t.Fatalf("parseFallbackRates: %v", err)
+	}
+	tc.svc.fallbackRates = rates
+
+	req := testPlaceOrderRequest()
+	req.UserCurrency = "EUR"
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	resp, err := tc.svc.PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("PlaceOrder() during a currency outage error = %v", err)
+	}
+
+	// 8.99 USD at 1.25 USD per EUR
+	if got := resp.GetOrder().GetShippingCost(); got.GetCurrencyCode() != "EUR" || got.GetUnits() != 7 || got.GetNanos() != 192000000 {
+		t.Errorf("shipping cost = %v, want EUR 7.192", got)
+	}
+	// (2 x 101.96 + 349.95 + 8.99) USD at 1.25 USD per EUR
+	if got := tc.payment.charges[0].GetAmount(); got.GetCurrencyCode() != "EUR" || got.GetUnits() != 450 || got.GetNanos() != 288000000 {
+		t.Errorf("charged %v, want EUR 450.288", got)
+	}
+
+	var marked bool
+	for _, span := range recorder.Ended() {
+		if span.Name() != "PlaceOrder" {
+			continue
+		}
+		for _, kv := range span.Attributes() {
+			marked = marked || kv == attribute.Bool("app.currency.fallback", true)
+		}
+	}
+	if !marked {
+		t.Error("order span not marked with app.currency.fallback")
+	}
+}
+
+func TestCurrencyFallbackDisabledOrUnknown(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.currency.err = status.Error(codes.Unavailable, "currency service down")
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+		t.Error("PlaceOrder() during a currency outage succeeded without fallback rates")
+	}
+
+	tc.svc.fallbackRates = fallbackRates{"EUR": 1, "USD": 1.25}
+	req := testPlaceOrderRequest()
+	req.UserCurrency = "CHF"
+	if _, err := tc.svc.PlaceOrder(context.Background(), req); err == nil {
+		t.Error("PlaceOrder() into a currency without a fallback rate succeeded")
+	}
+}
+
+func TestFallbackRatesFromEnv(t *testing.T) {
+	t.Setenv("CHECKOUT_CURRENCY_FALLBACK", "")
+	if rates, err := fallbackRatesFromEnv(); rates != nil || err != nil {
+		t.Errorf("fallbackRatesFromEnv() when disabled = %v, %v", rates, err)
+	}
+
+	t.Setenv("CHECKOUT_CURRENCY_FALLBACK", "true")
+	if _, err := fallbackRatesFromEnv(); err == nil {
+		t.Error("fallbackRatesFromEnv() without a rates file succeeded")
+	}
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"EUR": "1.0", "USD": "1.1305"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHECKOUT_CURRENCY_FALLBACK_RATES", path)
+	rates, err := fallbackRatesFromEnv()
+	if err != nil || rates["USD"] != 1.1305 {
+		t.Errorf("fallbackRatesFromEnv() = %v, %v", rates, err)
+	}
+
+	for _, bad := range []string{`[]`, `{"USD": "abc"}`, `{"USD": "0"}`} {
+		if _, err := parseFallbackRates([]byte(bad)); err == nil {
+			t.Errorf("parseFallbackRates(%s) succeeded", bad)
+		}
+	}
+	if _, ok := rates.convert(&pb.Money{CurrencyCode: "GBP", Units: 1}, "USD"); ok {
+		t.Error("convert() from a currency without a rate succeeded")
+	}
+}
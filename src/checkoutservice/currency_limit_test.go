@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"google.golang.org/grpc"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// slowCurrencyClient records the peak number of concurrent conversions.
+type slowCurrencyClient struct {
+	fakeCurrencyClient
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (f *slowCurrencyClient) Convert(ctx context.Context, in *pb.CurrencyConversionRequest, opts ...grpc.CallOption) (*pb.Money, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+	return f.fakeCurrencyClient.Convert(ctx, in, opts...)
+}
+
+func TestPrepOrderItemsCurrencyConcurrency(t *testing.T) {
+	const limit = 3
+
+	catalog := &fakeCatalogClient{products: make(map[string]*pb.Product)}
+	var items []*pb.CartItem
+	for i := range 40 {
+		id := fmt.Sprintf("P%02d", i)
+		catalog.products[id] = &pb.Product{Id: id, PriceUsd: &pb.Money{CurrencyCode: "USD", Units: int64(i)}}
+		items = append(items, &pb.CartItem{ProductId: id, Quantity: 1})
+	}
+	currency := &slowCurrencyClient{delay: 5 * time.Millisecond}
+	cs := &checkoutService{
+		productCatalogSvcClient: catalog,
+		currencySvcClient:       currency,
+		currencyConcurrency:     semaphore.NewWeighted(limit),
+	}
+
+	out, err := cs.prepOrderItems(context.Background(), items, "EUR")
+	if err != nil {
+		t.Fatalf("prepOrderItems: %v", err)
+	}
+	if peak := currency.peak.Load(); peak > limit || peak < 2 {
+		t.Errorf("peak concurrent conversions = %d, want between 2 and %d", peak, limit)
+	}
+	for i, item := range out {
+		if item.Item.ProductId != items[i].ProductId || item.Cost.Units != int64(i) || item.Cost.CurrencyCode != "EUR" {
+			t.Errorf("order item %d = %v, want %s priced at %d EUR", i, item, items[i].ProductId, i)
+		}
+	}
+}
+
+func TestConvertCurrencyCancelledWhileWaiting(t *testing.T) {
+	sem := semaphore.NewWeighted(1)
+	if !sem.TryAcquire(1) {
+		t.Fatal("could not saturate the semaphore")
+	}
+	cs := &checkoutService{currencySvcClient: &fakeCurrencyClient{}, currencyConcurrency: sem}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cs.convertCurrency(ctx, &pb.Money{CurrencyCode: "USD"}, "EUR"); err == nil {
+		t.Error("convertCurrency succeeded while every conversion slot was taken")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Downstream services, as reported in the service attribute of
+// checkout.dependency.duration.
+const (
+	dependencyCart           = "cart"
+	dependencyProductCatalog = "productcatalog"
+	dependencyCurrency       = "currency"
+	dependencyShipping       = "shipping"
+	dependencyPayment        = "payment"
+	dependencyEmail          = "email"
+)
+
+// anyDependency sets the slow call threshold of the services without one of
+// their own.
+const anyDependency = "*"
+
+// slowDependencies are the durations above which a call to a service is
+// flagged as slow, by service. A nil slowDependencies flags nothing.
+type slowDependencies map[string]time.Duration
+
+// slowDependenciesFromEnv reads CHECKOUT_SLOW_DEPENDENCY_MS, a
+// semicolon-separated list of thresholds in milliseconds such as
+// "payment=500;email=200;*=1000". Nothing is flagged when it is not set.
+func slowDependenciesFromEnv() (slowDependencies, error) {
+	list := os.Getenv("CHECKOUT_SLOW_DEPENDENCY_MS")
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	thresholds := make(slowDependencies)
+	for _, entry := range strings.Split(list, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		service, ms, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q in CHECKOUT_SLOW_DEPENDENCY_MS, want service=milliseconds", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q for %s in CHECKOUT_SLOW_DEPENDENCY_MS", ms, service)
+		}
+		thresholds[strings.TrimSpace(service)] = time.Duration(n) * time.Millisecond
+	}
+	return thresholds, nil
+}
+
+// threshold returns the duration above which a call to service is slow, or
+// zero when calls to it are never flagged.
+func (s slowDependencies) threshold(service string) time.Duration {
+	if t, ok := s[service]; ok {
+		return t
+	}
+	return s[anyDependency]
+}
+
+// observeDependency starts timing a call to service and returns the function
+// recording its duration once the call returns. A call slower than the
+// service's threshold adds a slow_dependency event to the span in ctx.
+func (cs *checkoutService) observeDependency(ctx context.Context, service string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		dependencyHistogram.Record(ctx, elapsed.Milliseconds(),
+			metric.WithAttributes(attribute.String("service", service)))
+		if threshold := cs.slowDependencies.threshold(service); threshold > 0 && elapsed > threshold {
+			trace.SpanFromContext(ctx).AddEvent("slow_dependency", trace.WithAttributes(
+				attribute.String("app.dependency.service", service),
+				attribute.Int64("app.dependency.duration_ms", elapsed.Milliseconds()),
+				attribute.Int64("app.dependency.threshold_ms", threshold.Milliseconds()),
+			))
+		}
+	}
+}
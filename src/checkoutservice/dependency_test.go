@@ -0,0 +1,121 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// dependencyCalls returns how many calls to each service
+// checkout.dependency.duration has recorded.
+func dependencyCalls(t *testing.T) map[string]uint64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := metricReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	calls := make(map[string]uint64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "checkout.dependency.duration" {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[int64])
+			if !ok {
+				t.Fatalf("metric %s is a %T, want an int64 histogram", m.Name, m.Data)
+			}
+			for _, dp := range hist.DataPoints {
+				if v, ok := dp.Attributes.Value("service"); ok {
+					calls[v.AsString()] += dp.Count
+				}
+			}
+		}
+	}
+	return calls
+}
+
+func TestPlaceOrderRecordsDependencyDurations(t *testing.T) {
+	tc := newTestCheckout(t)
+	before := dependencyCalls(t)
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	after := dependencyCalls(t)
+	for _, service := range []string{
+		dependencyCart, dependencyProductCatalog, dependencyCurrency,
+		dependencyShipping, dependencyPayment, dependencyEmail,
+	} {
+		if after[service] <= before[service] {
+			t.Errorf("no duration recorded for %s", service)
+		}
+	}
+}
+
+func TestSlowDependencyEvent(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.payment.delay = 20 * time.Millisecond
+	tc.svc.slowDependencies = slowDependencies{dependencyPayment: 5 * time.Millisecond, anyDependency: time.Hour}
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "order")
+	req := testPlaceOrderRequest()
+	if _, err := tc.svc.chargeCard(ctx, &pb.Money{CurrencyCode: "USD", Units: 10}, req.CreditCard, ""); err != nil {
+		t.Fatalf("chargeCard: %v", err)
+	}
+	if err := tc.svc.sendOrderConfirmation(ctx, req.Email, &pb.OrderResult{OrderId: "order-1"}, ""); err != nil {
+		t.Fatalf("sendOrderConfirmation: %v", err)
+	}
+	span.End()
+
+	var flagged []string
+	var events []sdktrace.Event
+	for _, s := range recorder.Ended() {
+		if s.Name() == "order" {
+			events = s.Events()
+		}
+	}
+	for _, event := range events {
+		if event.Name != "slow_dependency" {
+			continue
+		}
+		for _, kv := range event.Attributes {
+			if kv.Key == "app.dependency.service" {
+				flagged = append(flagged, kv.Value.AsString())
+			}
+			if kv.Key == "app.dependency.duration_ms" && kv.Value.AsInt64() < 20 {
+				t.Errorf("slow_dependency duration = %dms, want at least 20ms", kv.Value.AsInt64())
+			}
+		}
+	}
+	if len(flagged) != 1 || flagged[0] != dependencyPayment {
+		t.Errorf("slow_dependency events for %v, want only %s", flagged, dependencyPayment)
+	}
+}
+
+func TestSlowDependenciesFromEnv(t *testing.T) {
+	t.Setenv("CHECKOUT_SLOW_DEPENDENCY_MS", "payment=500; *=1000")
+	got, err := slowDependenciesFromEnv()
+	if err != nil {
+		t.Fatalf("slowDependenciesFromEnv: %v", err)
+	}
+	if got.threshold(dependencyPayment) != 500*time.Millisecond || got.threshold(dependencyEmail) != time.Second {
+		t.Errorf("thresholds = %v, want payment at 500ms and the rest at 1s", got)
+	}
+
+	for _, env := range []string{"payment", "payment=fast", "payment=0"} {
+		t.Setenv("CHECKOUT_SLOW_DEPENDENCY_MS", env)
+		if _, err := slowDependenciesFromEnv(); err == nil {
+			t.Errorf("slowDependenciesFromEnv() with %q returned no error", env)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseEmailEndpoint validates EMAIL_SERVICE_ADDR and returns the base URL
+// order confirmations are posted to. A bare host:port defaults to http, so a
+// misconfigured address fails at startup rather than on every order.
+func parseEmailEndpoint(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid email service address %q: %w", addr, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid email service address %q: scheme must be http or https", addr)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("invalid email service address %q: missing host", addr)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid email service address %q: unexpected query or fragment", addr)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
@@ -0,0 +1,26 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import "testing"
+
+func TestParseEmailEndpoint(t *testing.T) {
+	for addr, want := range map[string]string{
+		"http://emailservice:6060":   "http://emailservice:6060",
+		"https://email.example.com/": "https://email.example.com",
+		"http://email:6060/v1":       "http://email:6060/v1",
+		"emailservice:6060":          "http://emailservice:6060",
+		" emailservice:6060 ":        "http://emailservice:6060",
+	} {
+		got, err := parseEmailEndpoint(addr)
+		if err != nil || got != want {
+			t.Errorf("parseEmailEndpoint(%q) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+
+	for _, addr := range []string{"", "ftp://emailservice:21", "http://", "http://:6060", "http://email:6060?x=1", "http://email:bad port"} {
+		if got, err := parseEmailEndpoint(addr); err == nil {
+			t.Errorf("parseEmailEndpoint(%q) = %q, want an error", addr, got)
+		}
+	}
+}
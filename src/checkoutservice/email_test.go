@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+func TestSendOrderConfirmationTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	tc := newTestCheckout(t)
+	tc.svc.emailSvcAddr = slow.URL
+	tc.svc.emailTimeout = 50 * time.Millisecond
+
+	t.Run("confirmation times out", func(t *testing.T) {
+		err := tc.svc.sendOrderConfirmation(context.Background(), "someone@example.com", nil, "")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("sendOrderConfirmation error = %v, want a deadline exceeded error", err)
+		}
+	})
+
+	t.Run("order still completes", func(t *testing.T) {
+		start := time.Now()
+		resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		if resp.GetOrder().GetOrderId() == "" {
+			t.Errorf("PlaceOrder returned an order without an ID")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("PlaceOrder took %v, want it bounded by the email timeout", elapsed)
+		}
+	})
+}
+
+func TestSendOrderConfirmationPayload(t *testing.T) {
+	tc := newTestCheckout(t)
+	order := &pb.OrderResult{
+		OrderId:      "order-1",
+		ShippingCost: &pb.Money{CurrencyCode: "USD", Units: 5},
+	}
+	if err := tc.svc.sendOrderConfirmation(context.Background(), "someone@example.com", order, ""); err != nil {
+		t.Fatalf("sendOrderConfirmation: %v", err)
+	}
+
+	if got := tc.email.lastContentType(); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var payload struct {
+		Email   string       `json:"email"`
+		Summary orderSummary `json:"summary"`
+	}
+	if err := json.Unmarshal(tc.email.lastPayload(), &payload); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	if payload.Email != "someone@example.com" || payload.Summary.OrderID != "order-1" {
+		t.Errorf("payload has email %q and order %q, want someone@example.com and order-1", payload.Email, payload.Summary.OrderID)
+	}
+}
+
+func TestSendOrderConfirmationOutlivesRequestDeadline(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(slow.Close)
+
+	tc := newTestCheckout(t)
+	tc.svc.emailSvcAddr = slow.URL
+	tc.svc.emailTimeout = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tc.svc.sendOrderConfirmation(ctx, "someone@example.com", nil, ""); err != nil {
+		t.Errorf("sendOrderConfirmation with a short request deadline: %v, want the email timeout to apply", err)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeCartClient struct {
+	pb.CartServiceClient
+
+	mu      sync.Mutex
+	items   []*pb.CartItem
+	err     error
+	emptied int
+}
+
+func (f *fakeCartClient) GetCart(ctx context.Context, in *pb.GetCartRequest, opts ...grpc.CallOption) (*pb.Cart, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.Cart{UserId: in.GetUserId(), Items: f.items}, nil
+}
+
+func (f *fakeCartClient) AddItem(ctx context.Context, in *pb.AddItemRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = append(f.items, in.GetItem())
+	return &pb.Empty{}, nil
+}
+
+func (f *fakeCartClient) EmptyCart(ctx context.Context, in *pb.EmptyCartRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.emptied++
+	return &pb.Empty{}, nil
+}
+
+type fakeCatalogClient struct {
+	pb.ProductCatalogServiceClient
+	products map[string]*pb.Product
+	err      error
+	getCalls atomic.Int64
+}
+
+func (f *fakeCatalogClient) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.Product, error) {
+	f.getCalls.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	p, ok := f.products[in.GetId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Product Not Found: %s", in.GetId())
+	}
+	return p, nil
+}
+
+func (f *fakeCatalogClient) ListProducts(ctx context.Context, in *pb.ListProductsRequest, opts ...grpc.CallOption) (*pb.ListProductsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := &pb.ListProductsResponse{}
+	for _, p := range f.products {
+		resp.Products = append(resp.Products, p)
+	}
+	return resp, nil
+}
+
+// fakeCurrencyClient converts at a 1:1 rate, only relabelling the currency.
+type fakeCurrencyClient struct {
+	pb.CurrencyServiceClient
+	err       error
+	supported []string
+	listCalls int
+	// rate is applied by Convert, which converts at par when it is zero.
+	rate         float64
+	convertCalls atomic.Int64
+}
+
+func (f *fakeCurrencyClient) GetSupportedCurrencies(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (*pb.GetSupportedCurrenciesResponse, error) {
+	f.listCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.GetSupportedCurrenciesResponse{CurrencyCodes: f.supported}, nil
+}
+
+func (f *fakeCurrencyClient) Convert(ctx context.Context, in *pb.CurrencyConversionRequest, opts ...grpc.CallOption) (*pb.Money, error) {
+	f.convertCalls.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.rate != 0 {
+		return money.Convert(in.GetFrom(), f.rate, in.GetToCode())
+	}
+	return &pb.Money{CurrencyCode: in.GetToCode(), Units: in.GetFrom().GetUnits(), Nanos: in.GetFrom().GetNanos()}, nil
+}
+
+type fakeShippingClient struct {
+	pb.ShippingServiceClient
+	quoteErr error
+	shipErr  error
+	// shipResp is returned by ShipOrder instead of a TRACK-1 shipment when
+	// set.
+	shipResp *pb.ShipOrderResponse
+}
+
+func (f *fakeShippingClient) GetQuote(ctx context.Context, in *pb.GetQuoteRequest, opts ...grpc.CallOption) (*pb.GetQuoteResponse, error) {
+	if f.quoteErr != nil {
+		return nil, f.quoteErr
+	}
+	return &pb.GetQuoteResponse{CostUsd: &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000}}, nil
+}
+
+func (f *fakeShippingClient) ShipOrder(ctx context.Context, in *pb.ShipOrderRequest, opts ...grpc.CallOption) (*pb.ShipOrderResponse, error) {
+	if f.shipErr != nil {
+		return nil, f.shipErr
+	}
+	if f.shipResp != nil {
+		return f.shipResp, nil
+	}
+	return &pb.ShipOrderResponse{TrackingId: "TRACK-1"}, nil
+}
+
+type fakePaymentClient struct {
+	pb.PaymentServiceClient
+
+	mu      sync.Mutex
+	charges []*pb.ChargeRequest
+	err     error
+	delay   time.Duration
+	// chargeErrs is the number of charges to fail as unavailable before
+	// succeeding.
+	chargeErrs int
+
+	refunds    []*pb.RefundRequest
+	refundErrs int // number of refunds to fail before succeeding
+}
+
+func (f *fakePaymentClient) Charge(ctx context.Context, in *pb.ChargeRequest, opts ...grpc.CallOption) (*pb.ChargeResponse, error) {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.charges = append(f.charges, in)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.chargeErrs > 0 {
+		f.chargeErrs--
+		return nil, status.Error(codes.Unavailable, "payment service unavailable")
+	}
+	return &pb.ChargeResponse{TransactionId: "tx-1"}, nil
+}
+
+func (f *fakePaymentClient) Refund(ctx context.Context, in *pb.RefundRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.refundErrs > 0 {
+		f.refundErrs--
+		return nil, status.Error(codes.Unavailable, "payment service unavailable")
+	}
+	f.refunds = append(f.refunds, in)
+	return &pb.Empty{}, nil
+}
+
+func (f *fakePaymentClient) refundCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.refunds)
+}
+
+// fakeEmailServer counts order confirmations posted to it.
+type fakeEmailServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	received    int
+	last        []byte
+	contentType string
+}
+
+func newFakeEmailServer(t *testing.T) *fakeEmailServer {
+	t.Helper()
+	f := &fakeEmailServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.received++
+		f.last = body
+		f.contentType = r.Header.Get("Content-Type")
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// lastPayload returns the body of the last confirmation received.
+func (f *fakeEmailServer) lastPayload() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// lastContentType returns the content type of the last confirmation received.
+func (f *fakeEmailServer) lastContentType() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.contentType
+}
+
+func (f *fakeEmailServer) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received
+}
+
+// testCheckout bundles a checkoutService wired to fakes for every dependency.
+type testCheckout struct {
+	svc      *checkoutService
+	cart     *fakeCartClient
+	catalog  *fakeCatalogClient
+	currency *fakeCurrencyClient
+	shipping *fakeShippingClient
+	payment  *fakePaymentClient
+	email    *fakeEmailServer
+}
+
+func newTestCheckout(t *testing.T) *testCheckout {
+	t.Helper()
+	tc := &testCheckout{
+		cart: &fakeCartClient{items: []*pb.CartItem{
+			{ProductId: "OLJCESPC7Z", Quantity: 2},
+			{ProductId: "66VCHSJNUP", Quantity: 1},
+		}},
+		catalog: &fakeCatalogClient{products: map[string]*pb.Product{
+			"OLJCESPC7Z": {Id: "OLJCESPC7Z", Name: "National Park Foundation Explorascope", PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 101, Nanos: 960000000}},
+			"66VCHSJNUP": {Id: "66VCHSJNUP", Name: "Starsense Explorer Refractor Telescope", PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 349, Nanos: 950000000}},
+		}},
+		currency: &fakeCurrencyClient{},
+		shipping: &fakeShippingClient{},
+		payment:  &fakePaymentClient{},
+		email:    newFakeEmailServer(t),
+	}
+	tc.svc = newCheckoutService(checkoutDeps{
+		cart:           tc.cart,
+		productCatalog: tc.catalog,
+		currency:       tc.currency,
+		shipping:       tc.shipping,
+		payment:        tc.payment,
+		emailAddr:      tc.email.URL,
+	})
+	return tc
+}
+
+// useKafkaProducer makes svc publish to producer through a running
+// kafkaAcks, as main does when order events are not batched. The reader
+// stops once producer is closed.
+func useKafkaProducer(svc *checkoutService, producer sarama.AsyncProducer) {
+	svc.KafkaProducerClient = producer
+	svc.kafkaAcks = newKafkaAcks(producer, svc.deadLetter)
+	go svc.kafkaAcks.run()
+}
+
+func testPlaceOrderRequest() *pb.PlaceOrderRequest {
+	return &pb.PlaceOrderRequest{
+		UserId:       "user-1",
+		UserCurrency: "USD",
+		Email:        "someone@example.com",
+		Address: &pb.Address{
+			StreetAddress: "1600 Amphitheatre Parkway",
+			City:          "Mountain View",
+			State:         "CA",
+			Country:       "US",
+			ZipCode:       "94043",
+		},
+		CreditCard: &pb.CreditCardInfo{
+			CreditCardNumber:          "4432-8015-6152-0454",
+			CreditCardCvv:             672,
+			CreditCardExpirationYear:  2030,
+			CreditCardExpirationMonth: 1,
+		},
+	}
+}
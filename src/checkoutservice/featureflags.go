@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flagAttributesHook records every evaluated feature flag and its value on the
+// active span as app.featureflag.<flag>, so a trace shaped by a chaos flag
+// names the flag that caused it.
+type flagAttributesHook struct {
+	openfeature.UnimplementedHook
+}
+
+func (flagAttributesHook) After(ctx context.Context, hookContext openfeature.HookContext, details openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) error {
+	trace.SpanFromContext(ctx).SetAttributes(flagAttribute(hookContext.FlagKey(), details.Value))
+	return nil
+}
+
+func flagAttribute(flag string, value interface{}) attribute.KeyValue {
+	key := "app.featureflag." + flag
+	switch v := value.(type) {
+	case bool:
+		return attribute.Bool(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case string:
+		return attribute.String(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+// flagErrorsHook counts feature flag evaluations that failed, such as while
+// flagd is unreachable, in featureflag.eval_errors by flag and service. The
+// default value is used for those evaluations, which is otherwise silent.
+type flagErrorsHook struct {
+	openfeature.UnimplementedHook
+	service string
+	errors  metric.Int64Counter
+}
+
+func newFlagErrorsHook(meter metric.Meter, service string) (flagErrorsHook, error) {
+	errors, err := meter.Int64Counter("featureflag.eval_errors",
+		metric.WithDescription("The number of feature flag evaluations that failed and fell back to the default value"),
+		metric.WithUnit("1"))
+	return flagErrorsHook{service: service, errors: errors}, err
+}
+
+func (h flagErrorsHook) Error(ctx context.Context, hookContext openfeature.HookContext, err error, _ openfeature.HookHints) {
+	h.errors.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("feature_flag.key", hookContext.FlagKey()),
+		attribute.String("service", h.service),
+	))
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestFlagAttributesHook(t *testing.T) {
+	openfeature.AddHooks(flagAttributesHook{})
+	useFlagProvider(t, &recordingProvider{
+		bools: map[string]bool{"paymentServiceUnreachable": true},
+		ints:  map[string]int64{"kafkaQueueProblems": 3},
+	})
+	recorder := recordSpans(t)
+
+	ctx, span := tracer.Start(context.Background(), "PlaceOrder")
+	cs := &checkoutService{}
+	cs.isFeatureFlagEnabled(ctx, "paymentServiceUnreachable")
+	cs.getIntFeatureFlag(ctx, "kafkaQueueProblems")
+	span.End()
+
+	attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+	if v, ok := attrs.Value("app.featureflag.paymentServiceUnreachable"); !ok || !v.AsBool() {
+		t.Errorf("app.featureflag.paymentServiceUnreachable = %v, want true", v.Emit())
+	}
+	if v, ok := attrs.Value("app.featureflag.kafkaQueueProblems"); !ok || v.AsInt64() != 3 {
+		t.Errorf("app.featureflag.kafkaQueueProblems = %v, want 3", v.Emit())
+	}
+}
+
+// failingProvider is a flag provider that fails every evaluation, as flagd
+// does while it is unreachable.
+type failingProvider struct {
+	openfeature.NoopProvider
+}
+
+func (failingProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	return openfeature.BoolResolutionDetail{
+		Value:                    defaultValue,
+		ProviderResolutionDetail: openfeature.ProviderResolutionDetail{ResolutionError: openfeature.NewGeneralResolutionError("flagd unreachable")},
+	}
+}
+
+func (failingProvider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx openfeature.FlattenedContext) openfeature.IntResolutionDetail {
+	return openfeature.IntResolutionDetail{
+		Value:                    defaultValue,
+		ProviderResolutionDetail: openfeature.ProviderResolutionDetail{ResolutionError: openfeature.NewGeneralResolutionError("flagd unreachable")},
+	}
+}
+
+func TestFlagErrorsHook(t *testing.T) {
+	hook, err := newFlagErrorsHook(otel.Meter("checkoutservice"), "checkoutservice")
+	if err != nil {
+		t.Fatalf("newFlagErrorsHook: %v", err)
+	}
+	openfeature.AddHooks(hook)
+	useFlagProvider(t, failingProvider{})
+	boolBefore := counterValue(t, "featureflag.eval_errors", "feature_flag.key", "paymentServiceUnreachable")
+	intBefore := counterValue(t, "featureflag.eval_errors", "feature_flag.key", "kafkaQueueProblems")
+
+	cs := &checkoutService{}
+	if cs.isFeatureFlagEnabled(context.Background(), "paymentServiceUnreachable") {
+		t.Error("isFeatureFlagEnabled = true for a failed evaluation, want the default")
+	}
+	if got := cs.getIntFeatureFlag(context.Background(), "kafkaQueueProblems"); got != 0 {
+		t.Errorf("getIntFeatureFlag = %d for a failed evaluation, want the default", got)
+	}
+
+	if got := counterValue(t, "featureflag.eval_errors", "feature_flag.key", "paymentServiceUnreachable"); got != boolBefore+1 {
+		t.Errorf("featureflag.eval_errors for paymentServiceUnreachable = %d, want %d", got, boolBefore+1)
+	}
+	if got := counterValue(t, "featureflag.eval_errors", "feature_flag.key", "kafkaQueueProblems"); got != intBefore+1 {
+		t.Errorf("featureflag.eval_errors for kafkaQueueProblems = %d, want %d", got, intBefore+1)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// dependencyProbeTimeout bounds each downstream health probe so one hung
+// dependency cannot stall the whole report.
+const dependencyProbeTimeout = 2 * time.Second
+
+// GetDependencyHealth probes the gRPC health endpoint of every downstream
+// service concurrently and reports the status of each one. The email service
+// is not probed as it is reached over plain HTTP.
+func (cs *checkoutService) GetDependencyHealth(ctx context.Context, req *pb.Empty) (*pb.GetDependencyHealthResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make(map[string]*pb.DependencyHealth, len(cs.healthClients))
+	)
+	for name, client := range cs.healthClients {
+		wg.Add(1)
+		go func(name string, client healthpb.HealthClient) {
+			defer wg.Done()
+			health := probeDependency(ctx, client)
+
+			mu.Lock()
+			out[name] = health
+			mu.Unlock()
+		}(name, client)
+	}
+	wg.Wait()
+
+	var unhealthy int
+	for name, health := range out {
+		if health.Status != healthpb.HealthCheckResponse_SERVING.String() {
+			unhealthy++
+			logger.WarnContext(ctx, "dependency is not serving", "service", name, "status", health.Status, "error", health.Error)
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("app.dependencies.count", len(out)),
+		attribute.Int("app.dependencies.unhealthy.count", unhealthy),
+	)
+	return &pb.GetDependencyHealthResponse{Dependencies: out}, nil
+}
+
+func probeDependency(ctx context.Context, client healthpb.HealthClient) *pb.DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, dependencyProbeTimeout)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return &pb.DependencyHealth{
+			Status: healthpb.HealthCheckResponse_UNKNOWN.String(),
+			Error:  err.Error(),
+		}
+	}
+	return &pb.DependencyHealth{Status: resp.GetStatus().String()}
+}
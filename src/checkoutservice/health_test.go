@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeHealthClient struct {
+	healthpb.HealthClient
+	status healthpb.HealthCheckResponse_ServingStatus
+	err    error
+	hang   bool
+}
+
+func (f fakeHealthClient) Check(ctx context.Context, in *healthpb.HealthCheckRequest, opts ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	if f.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &healthpb.HealthCheckResponse{Status: f.status}, nil
+}
+
+func TestGetDependencyHealth(t *testing.T) {
+	cs := &checkoutService{healthClients: map[string]healthpb.HealthClient{
+		"shipping": fakeHealthClient{status: healthpb.HealthCheckResponse_SERVING},
+		"cart":     fakeHealthClient{status: healthpb.HealthCheckResponse_SERVING},
+		"payment":  fakeHealthClient{status: healthpb.HealthCheckResponse_NOT_SERVING},
+		"currency": fakeHealthClient{err: errors.New("connection refused")},
+	}}
+
+	resp, err := cs.GetDependencyHealth(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("GetDependencyHealth: %v", err)
+	}
+
+	want := map[string]string{
+		"shipping": "SERVING",
+		"cart":     "SERVING",
+		"payment":  "NOT_SERVING",
+		"currency": "UNKNOWN",
+	}
+	if len(resp.Dependencies) != len(want) {
+		t.Fatalf("got %d dependencies, want %d", len(resp.Dependencies), len(want))
+	}
+	for name, status := range want {
+		if got := resp.Dependencies[name].GetStatus(); got != status {
+			t.Errorf("%s status = %q, want %q", name, got, status)
+		}
+	}
+	if resp.Dependencies["currency"].GetError() == "" {
+		t.Errorf("currency error is empty, want the probe error")
+	}
+}
+
+func TestGetDependencyHealthTimeout(t *testing.T) {
+	cs := &checkoutService{healthClients: map[string]healthpb.HealthClient{
+		"shipping": fakeHealthClient{hang: true},
+		"cart":     fakeHealthClient{status: healthpb.HealthCheckResponse_SERVING},
+	}}
+
+	start := time.Now()
+	resp, err := cs.GetDependencyHealth(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("GetDependencyHealth: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > dependencyProbeTimeout+time.Second {
+		t.Errorf("GetDependencyHealth took %v, want at most about %v", elapsed, dependencyProbeTimeout)
+	}
+	if got := resp.Dependencies["shipping"].GetStatus(); got != "UNKNOWN" {
+		t.Errorf("shipping status = %q, want UNKNOWN", got)
+	}
+	if got := resp.Dependencies["cart"].GetStatus(); got != "SERVING" {
+		t.Errorf("cart status = %q, want SERVING", got)
+	}
+}
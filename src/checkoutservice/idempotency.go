@@ -0,0 +1,129 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxIdempotencyKeyLen bounds idempotency keys, which fits a UUID with
+	// room to spare.
+	maxIdempotencyKeyLen = 64
+	// defaultIdempotencyMaxKeys bounds how many orders are remembered when
+	// CHECKOUT_IDEMPOTENCY_MAX_KEYS is not set.
+	defaultIdempotencyMaxKeys = 10000
+)
+
+// validateIdempotencyKey rejects keys that are too long or contain anything
+// but letters, digits, '-' and '_', with an InvalidArgument status. An empty
+// key is valid and means the order is not idempotent.
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLen {
+		return status.Errorf(codes.InvalidArgument, "idempotency key is longer than %d characters", maxIdempotencyKeyLen)
+	}
+	for _, r := range key {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_':
+		default:
+			return status.Errorf(codes.InvalidArgument, "idempotency key contains invalid character %q", r)
+		}
+	}
+	return nil
+}
+
+// idempotencyCache remembers the orders placed with an idempotency key for
+// ttl, so a retried PlaceOrder returns the order of the first attempt instead
+// of charging the customer again. Keys are scoped to the user placing the
+// order, and at most maxKeys orders are remembered at a time. A nil
+// *idempotencyCache remembers nothing.
+type idempotencyCache struct {
+	ttl     time.Duration
+	maxKeys int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[[2]string]idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	resp    *pb.PlaceOrderResponse // nil while the order is being placed
+	expires time.Time
+}
+
+// newIdempotencyCache returns a cache remembering orders for ttl, or nil when
+// ttl is not positive.
+func newIdempotencyCache(ttl time.Duration, maxKeys int) *idempotencyCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &idempotencyCache{
+		ttl:     ttl,
+		maxKeys: maxKeys,
+		now:     time.Now,
+		entries: make(map[[2]string]idempotencyEntry),
+	}
+}
+
+// begin claims key for an order of userID. It returns the response of the
+// order already placed with key, if any, or an Aborted status while that
+// order is still being placed. When the cache is full, the order is placed
+// without being remembered.
+func (c *idempotencyCache) begin(userID, key string) (*pb.PlaceOrderResponse, error) {
+	if c == nil || key == "" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	id := [2]string{userID, key}
+	if entry, ok := c.entries[id]; ok && now.Before(entry.expires) {
+		if entry.resp == nil {
+			return nil, status.Error(codes.Aborted, "an order with this idempotency key is being placed")
+		}
+		return entry.resp, nil
+	}
+	if len(c.entries) >= c.maxKeys {
+		c.dropExpired(now)
+		if len(c.entries) >= c.maxKeys {
+			logger.Warn("idempotency cache is full, placing order without remembering it", "max_keys", c.maxKeys)
+			return nil, nil
+		}
+	}
+	c.entries[id] = idempotencyEntry{expires: now.Add(c.ttl)}
+	return nil, nil
+}
+
+// finish records resp as the order placed with key. A nil resp, for an order
+// that failed, releases the key so that a retry places the order again.
+func (c *idempotencyCache) finish(userID, key string, resp *pb.PlaceOrderResponse) {
+	if c == nil || key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := [2]string{userID, key}
+	if _, ok := c.entries[id]; !ok {
+		return
+	}
+	if resp == nil {
+		delete(c.entries, id)
+		return
+	}
+	c.entries[id] = idempotencyEntry{resp: resp, expires: c.now().Add(c.ttl)}
+}
+
+func (c *idempotencyCache) dropExpired(now time.Time) {
+	for id, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+}
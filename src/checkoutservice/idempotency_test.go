@@ -0,0 +1,162 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama/mocks"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	for _, key := range []string{"", uuid.NewString(), "order_42-retry", strings.Repeat("k", maxIdempotencyKeyLen)} {
+		if err := validateIdempotencyKey(key); err != nil {
+			t.Errorf("validateIdempotencyKey(%q) = %v, want it accepted", key, err)
+		}
+	}
+	for _, key := range []string{strings.Repeat("k", maxIdempotencyKeyLen+1), "order 42", "key/../..", "clé"} {
+		if err := validateIdempotencyKey(key); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("validateIdempotencyKey(%q) = %v, want InvalidArgument", key, err)
+		}
+	}
+}
+
+func TestPlaceOrderIdempotencyKey(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.idempotency = newIdempotencyCache(time.Minute, 10)
+
+	// without a key every call places an order
+	for range 2 {
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+			t.Fatalf("PlaceOrder() without a key error = %v", err)
+		}
+	}
+	if len(tc.payment.charges) != 2 {
+		t.Fatalf("charged %d times without a key, want 2", len(tc.payment.charges))
+	}
+
+	req := testPlaceOrderRequest()
+	req.IdempotencyKey = uuid.NewString()
+	first, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	retry, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil || retry.GetOrder().GetOrderId() != first.GetOrder().GetOrderId() {
+		t.Errorf("retried PlaceOrder() = %v, %v, want order %s", retry, err, first.GetOrder().GetOrderId())
+	}
+	if len(tc.payment.charges) != 3 {
+		t.Errorf("charged %d times, want the retry not to charge again", len(tc.payment.charges))
+	}
+
+	// another user's key is unrelated
+	other := testPlaceOrderRequest()
+	other.UserId = "user-2"
+	other.IdempotencyKey = req.IdempotencyKey
+	if resp, err := tc.svc.PlaceOrder(context.Background(), other); err != nil || resp.GetOrder().GetOrderId() == first.GetOrder().GetOrderId() {
+		t.Errorf("PlaceOrder() by another user with the same key = %v, %v, want a new order", resp, err)
+	}
+
+	oversized := testPlaceOrderRequest()
+	oversized.IdempotencyKey = strings.Repeat("k", 10000)
+	if _, err := tc.svc.PlaceOrder(context.Background(), oversized); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PlaceOrder() with an oversized key error = %v, want InvalidArgument", err)
+	}
+	if len(tc.payment.charges) != 4 || len(tc.svc.idempotency.entries) != 2 {
+		t.Errorf("an oversized key charged or was remembered: %d charges, %d keys", len(tc.payment.charges), len(tc.svc.idempotency.entries))
+	}
+}
+
+func TestIdempotencyCacheReleasesFailedOrders(t *testing.T) {
+	now := time.Now()
+	c := newIdempotencyCache(time.Minute, 1)
+	c.now = func() time.Time { return now }
+
+	if placed, err := c.begin("user-1", "key"); placed != nil || err != nil {
+		t.Fatalf("begin() = %v, %v, want the key claimed", placed, err)
+	}
+	if _, err := c.begin("user-1", "key"); status.Code(err) != codes.Aborted {
+		t.Errorf("begin() while the order is placed error = %v, want Aborted", err)
+	}
+	c.finish("user-1", "key", nil)
+	if placed, err := c.begin("user-1", "key"); placed != nil || err != nil {
+		t.Errorf("begin() after a failed order = %v, %v, want the key claimed again", placed, err)
+	}
+
+	// a full cache places orders without remembering them
+	if placed, err := c.begin("user-1", "other"); placed != nil || err != nil || len(c.entries) != 1 {
+		t.Errorf("begin() on a full cache = %v, %v with %d keys, want the order placed", placed, err, len(c.entries))
+	}
+	now = now.Add(time.Minute)
+	if _, err := c.begin("user-1", "other"); err != nil || len(c.entries) != 1 {
+		t.Errorf("begin() once the other key expired = %v with %d keys, want it to replace it", err, len(c.entries))
+	}
+}
+
+func TestRetriedOrderSendsOneConfirmation(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.idempotency = newIdempotencyCache(time.Minute, 10)
+
+	req := testPlaceOrderRequest()
+	req.IdempotencyKey = "order-1"
+	for i := 0; i < 2; i++ {
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("PlaceOrder attempt %d: %v", i+1, err)
+		}
+	}
+	if got := tc.email.count(); got != 1 {
+		t.Errorf("confirmations sent for a retried order = %d, want 1", got)
+	}
+
+	// Placing the same cart again without a key is a new order, which is
+	// charged and confirmed again.
+	req.IdempotencyKey = ""
+	for i := 0; i < 2; i++ {
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("PlaceOrder without a key, attempt %d: %v", i+1, err)
+		}
+	}
+	if got := tc.email.count(); got != 3 {
+		t.Errorf("confirmations sent = %d, want 3", got)
+	}
+}
+
+func TestRetryAfterFailedPublishSendsOneConfirmation(t *testing.T) {
+	producer := mocks.NewAsyncProducer(t, mocks.NewTestConfig())
+	defer producer.Close()
+	// the order event fails after the confirmation was sent
+	producer.ExpectInputAndFail(errors.New("broker down"))
+
+	tc := newTestCheckout(t)
+	tc.svc.idempotency = newIdempotencyCache(time.Minute, 10)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	useKafkaProducer(tc.svc, producer)
+
+	req := testPlaceOrderRequest()
+	req.IdempotencyKey = "order-1"
+	first, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrder with a failed order event: %v", err)
+	}
+	retried, err := tc.svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("retried PlaceOrder: %v", err)
+	}
+
+	if retried.GetOrder().GetOrderId() != first.GetOrder().GetOrderId() {
+		t.Errorf("retried order = %s, want the first order %s", retried.GetOrder().GetOrderId(), first.GetOrder().GetOrderId())
+	}
+	if got := tc.email.count(); got != 1 {
+		t.Errorf("confirmations sent = %d, want 1", got)
+	}
+	if got := len(tc.payment.charges); got != 1 {
+		t.Errorf("card charged %d times, want 1", got)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package kafka
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// DeadLetter is a message that could not be published to Kafka. Its headers
+// carry the trace context of the order, which the replay continues.
+type DeadLetter struct {
+	Topic    string    `json:"topic"`
+	Value    []byte    `json:"value"`
+	Headers  []Header  `json:"headers,omitempty"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Header is a Kafka record header of a DeadLetter.
+type Header struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// DeadLetterQueue stores undeliverable messages as JSON lines in a file so
+// they can be replayed once Kafka is healthy again.
+type DeadLetterQueue struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewDeadLetterQueue(path string) *DeadLetterQueue {
+	return &DeadLetterQueue{path: path}
+}
+
+// Add appends msg to the queue, recording why it could not be published.
+func (q *DeadLetterQueue) Add(msg *sarama.ProducerMessage, cause error) error {
+	value, err := msg.Value.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+	headers := make([]Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers = append(headers, Header{Key: string(h.Key), Value: h.Value})
+	}
+	line, err := json.Marshal(DeadLetter{
+		Topic:    msg.Topic,
+		Value:    value,
+		Headers:  headers,
+		Error:    cause.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replay hands every queued message to publish. Messages that are published
+// are removed from the queue and the rest are kept, so replaying again only
+// retries what is still undelivered.
+func (q *DeadLetterQueue) Replay(publish func(*sarama.ProducerMessage) error) (replayed, failed int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters, err := q.read()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var remaining []DeadLetter
+	for _, dl := range letters {
+		msg := &sarama.ProducerMessage{Topic: dl.Topic, Value: sarama.ByteEncoder(dl.Value)}
+		for _, h := range dl.Headers {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value})
+		}
+		if err := publish(msg); err != nil {
+			dl.Error = err.Error()
+			remaining = append(remaining, dl)
+			failed++
+			continue
+		}
+		replayed++
+	}
+
+	if replayed > 0 {
+		if err := q.write(remaining); err != nil {
+			return replayed, failed, err
+		}
+	}
+	return replayed, failed, nil
+}
+
+// Len returns the number of messages in the queue.
+func (q *DeadLetterQueue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters, err := q.read()
+	return len(letters), err
+}
+
+func (q *DeadLetterQueue) read() ([]DeadLetter, error) {
+	f, err := os.Open(q.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			logger.Error("Skipping malformed dead letter", "error", err.Error())
+			continue
+		}
+		letters = append(letters, dl)
+	}
+	return letters, scanner.Err()
+}
+
+// write atomically replaces the queue with letters.
+func (q *DeadLetterQueue) write(letters []DeadLetter) error {
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, dl := range letters {
+		line, err := json.Marshal(dl)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
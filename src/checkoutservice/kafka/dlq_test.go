@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package kafka
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func TestDeadLetterQueueReplay(t *testing.T) {
+	q := NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	for _, v := range []string{"one", "two", "three"} {
+		msg := &sarama.ProducerMessage{Topic: Topic, Value: sarama.StringEncoder(v)}
+		if err := q.Add(msg, errors.New("broker down")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	var published []string
+	replayed, failed, err := q.Replay(func(msg *sarama.ProducerMessage) error {
+		v, _ := msg.Value.Encode()
+		if string(v) == "two" {
+			return errors.New("still down")
+		}
+		if msg.Topic != Topic {
+			t.Errorf("replayed topic = %q, want %q", msg.Topic, Topic)
+		}
+		published = append(published, string(v))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed != 2 || failed != 1 {
+		t.Errorf("Replay = %d replayed, %d failed, want 2 and 1", replayed, failed)
+	}
+	if len(published) != 2 || published[0] != "one" || published[1] != "three" {
+		t.Errorf("published = %v, want [one three]", published)
+	}
+
+	replayed, failed, err = q.Replay(func(*sarama.ProducerMessage) error { return nil })
+	if err != nil || replayed != 1 || failed != 0 {
+		t.Errorf("second Replay = %d, %d, %v, want only the failed letter replayed", replayed, failed, err)
+	}
+	if n, err := q.Len(); err != nil || n != 0 {
+		t.Errorf("Len after replay = %d, %v, want an empty queue", n, err)
+	}
+}
+
+func TestDeadLetterQueueReplayMissingFile(t *testing.T) {
+	q := NewDeadLetterQueue(filepath.Join(t.TempDir(), "missing.jsonl"))
+	replayed, failed, err := q.Replay(func(*sarama.ProducerMessage) error {
+		t.Fatal("publish called for an empty queue")
+		return nil
+	})
+	if err != nil || replayed != 0 || failed != 0 {
+		t.Errorf("Replay = %d, %d, %v, want nothing replayed", replayed, failed, err)
+	}
+}
+
+func TestDeadLetterQueueKeepsHeaders(t *testing.T) {
+	q := NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	msg := &sarama.ProducerMessage{Topic: Topic, Value: sarama.StringEncoder("one"), Headers: []sarama.RecordHeader{
+		{Key: []byte("traceparent"), Value: []byte(traceparent)},
+	}}
+	if err := q.Add(msg, errors.New("broker down")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	_, _, err := q.Replay(func(msg *sarama.ProducerMessage) error {
+		if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != "traceparent" || string(msg.Headers[0].Value) != traceparent {
+			t.Errorf("replayed headers = %v, want the traceparent of the order", msg.Headers)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 var (
 	Topic           = "orders"
+	RejectedTopic   = "order-rejected"
 	ProtocolVersion = sarama.V3_0_0_0
 	logger          = otelslog.NewLogger("kafka")
 )
@@ -0,0 +1,172 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errKafkaAcksPending is the shutdown error when the producer's
+// acknowledgements could not all be read in time.
+var errKafkaAcksPending = errors.New("kafka acknowledgements still pending")
+
+// kafkaAcks is the only reader of the producer's Successes and Errors when
+// order events are not batched. Every publisher hands its messages to the
+// producer through it and gets their outcome back through the message
+// metadata, so concurrent orders, dead letter replays and the
+// kafkaQueueProblems overload never take each other's acknowledgements. It
+// counts the outcomes in checkout.kafka.success and checkout.kafka.error and
+// dead-letters failed messages nobody waits for.
+type kafkaAcks struct {
+	producer   sarama.AsyncProducer
+	deadLetter func(context.Context, *sarama.ProducerMessage, error)
+	done       chan struct{}
+}
+
+// pendingAck is the metadata of a message sent through kafkaAcks.
+type pendingAck struct {
+	span  trace.Span
+	start time.Time
+	// result receives the outcome of a message whose sender waits for it.
+	result chan error
+}
+
+func newKafkaAcks(producer sarama.AsyncProducer, deadLetter func(context.Context, *sarama.ProducerMessage, error)) *kafkaAcks {
+	return &kafkaAcks{producer: producer, deadLetter: deadLetter, done: make(chan struct{})}
+}
+
+// send hands msg to the producer without waiting for Kafka to acknowledge it,
+// as in fire-and-forget mode (KAFKA_FIRE_AND_FORGET). The message is
+// dead-lettered if the producer does not take it before ctx is done. It
+// reports whether the producer took msg.
+func (a *kafkaAcks) send(ctx context.Context, msg *sarama.ProducerMessage) bool {
+	return a.enqueue(ctx, msg, nil)
+}
+
+// publish hands msg to the producer and waits for its acknowledgement until
+// ctx is done, dead-lettering msg if it fails or the producer does not take
+// it in time. It reports whether the producer took msg.
+func (a *kafkaAcks) publish(ctx context.Context, msg *sarama.ProducerMessage) bool {
+	result := make(chan error, 1)
+	if !a.enqueue(ctx, msg, result) {
+		a.deadLetter(context.WithoutCancel(ctx), msg, ctx.Err())
+		return false
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			a.deadLetter(ctx, msg, err)
+		}
+	case <-ctx.Done():
+		logger.WarnContext(ctx, "Context canceled before success message received", "error", ctx.Err())
+	}
+	return true
+}
+
+// sendSync hands msg to the producer and waits for the reader to receive its
+// acknowledgement, for callers such as the dead letter replay that must know
+// whether it was published.
+func (a *kafkaAcks) sendSync(ctx context.Context, msg *sarama.ProducerMessage) error {
+	result := make(chan error, 1)
+	if !a.enqueue(ctx, msg, result) {
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *kafkaAcks) enqueue(ctx context.Context, msg *sarama.ProducerMessage, result chan error) bool {
+	span := createProducerSpan(ctx, msg)
+	msg.Metadata = &pendingAck{span: span, start: time.Now(), result: result}
+	select {
+	case a.producer.Input() <- msg:
+		return true
+	case <-ctx.Done():
+		span.SetAttributes(attribute.Bool("messaging.kafka.producer.success", false))
+		span.SetStatus(otelcodes.Error, "Failed to send: "+ctx.Err().Error())
+		span.End()
+		logger.ErrorContext(ctx, "Failed to send message to Kafka within context deadline", "error", ctx.Err())
+		if result == nil {
+			a.deadLetter(context.WithoutCancel(ctx), msg, ctx.Err())
+		}
+		return false
+	}
+}
+
+// run reads acknowledgements until the producer closes its channels.
+func (a *kafkaAcks) run() {
+	defer close(a.done)
+	successes, errs := a.producer.Successes(), a.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			a.finish(msg, nil)
+		case perr, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			a.finish(perr.Msg, perr.Err)
+		}
+	}
+}
+
+// close shuts the producer down and waits until run has read the remaining
+// acknowledgements, or ctx is done. It reports the outcome like flushKafka.
+func (a *kafkaAcks) close(ctx context.Context) (string, error) {
+	a.producer.AsyncClose()
+	select {
+	case <-a.done:
+		return kafkaFlushClean, nil
+	case <-ctx.Done():
+		return kafkaFlushFailed, errKafkaAcksPending
+	}
+}
+
+// finish records the outcome of msg, ends its producer span and
+// dead-letters it if publishing failed.
+func (a *kafkaAcks) finish(msg *sarama.ProducerMessage, err error) {
+	ack, ok := msg.Metadata.(*pendingAck)
+	if !ok {
+		ack = &pendingAck{span: trace.SpanFromContext(context.Background()), start: time.Now()}
+	}
+	defer ack.span.End()
+	ctx := trace.ContextWithSpan(context.Background(), ack.span)
+
+	ack.span.SetAttributes(
+		attribute.Bool("messaging.kafka.producer.success", err == nil),
+		attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(ack.start).Milliseconds())),
+	)
+	if err == nil {
+		kafkaSuccess.Add(ctx, 1)
+		ack.span.SetAttributes(semconv.MessagingKafkaOffset(int(msg.Offset)))
+	} else {
+		kafkaError.Add(ctx, 1)
+		ack.span.SetStatus(otelcodes.Error, err.Error())
+		logger.ErrorContext(ctx, "Failed to write message", "error", err)
+	}
+	if ack.result != nil {
+		// the waiting sender decides what to do with a failed message
+		ack.result <- err
+		return
+	}
+	if err != nil {
+		a.deadLetter(ctx, msg, err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+)
+
+// heldProducer buffers one message and acknowledges it only when the test
+// sends on its channels.
+type heldProducer struct {
+	stuckProducer
+}
+
+func newHeldProducer() *heldProducer {
+	return &heldProducer{stuckProducer{
+		input:     make(chan *sarama.ProducerMessage, 1),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+	}}
+}
+
+func (p *heldProducer) AsyncClose() {
+	close(p.successes)
+	close(p.errors)
+}
+
+func TestPlaceOrderFireAndForgetReturnsBeforeAck(t *testing.T) {
+	producer := newHeldProducer()
+	tc := newTestCheckout(t)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	tc.svc.fireAndForget = true
+	useKafkaProducer(tc.svc, producer)
+	before := counterValue(t, "checkout.kafka.success", "", "")
+
+	placed := make(chan error, 1)
+	go func() {
+		_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		placed <- err
+	}()
+	select {
+	case err := <-placed:
+		if err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PlaceOrder() waited for Kafka to acknowledge the order")
+	}
+
+	msg := <-producer.input
+	if got := counterValue(t, "checkout.kafka.success", "", ""); got != before {
+		t.Errorf("checkout.kafka.success = %d before the ack, want %d", got, before)
+	}
+	producer.successes <- msg
+	if flush, err := tc.svc.kafkaAcks.close(context.Background()); flush != kafkaFlushClean || err != nil {
+		t.Fatalf("close() = %q, %v, want clean", flush, err)
+	}
+	if got := counterValue(t, "checkout.kafka.success", "", ""); got != before+1 {
+		t.Errorf("checkout.kafka.success = %d after the ack, want %d", got, before+1)
+	}
+}
+
+func TestKafkaAcksDeadLettersFailures(t *testing.T) {
+	producer := newHeldProducer()
+	dlq := kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	cs := &checkoutService{KafkaProducerClient: producer, deadLetters: dlq}
+	a := newKafkaAcks(producer, cs.deadLetter)
+	go a.run()
+	before := counterValue(t, "checkout.kafka.error", "", "")
+
+	a.send(context.Background(), &sarama.ProducerMessage{Topic: kafka.Topic, Value: sarama.StringEncoder("order-1")})
+	msg := <-producer.input
+	producer.errors <- &sarama.ProducerError{Msg: msg, Err: errors.New("broker down")}
+	if _, err := a.close(context.Background()); err != nil {
+		t.Fatalf("close() = %v", err)
+	}
+
+	if n, err := dlq.Len(); err != nil || n != 1 {
+		t.Errorf("dead letters = %d, %v, want the failed order event", n, err)
+	}
+	if got := counterValue(t, "checkout.kafka.error", "", ""); got != before+1 {
+		t.Errorf("checkout.kafka.error = %d, want %d", got, before+1)
+	}
+}
+
+func TestKafkaAcksCloseTimesOut(t *testing.T) {
+	// run is never started, so the acknowledgements are never drained
+	a := newKafkaAcks(newHeldProducer(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if flush, err := a.close(ctx); flush != kafkaFlushFailed || !errors.Is(err, errKafkaAcksPending) {
+		t.Errorf("close() = %q, %v, want failed with pending acknowledgements", flush, err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults for the batching mode enabled by KAFKA_BATCH_SIZE.
+const (
+	defaultKafkaBatchInterval  = 100 * time.Millisecond
+	defaultKafkaBatchQueueSize = 1000
+)
+
+// errKafkaQueueFull is the dead letter cause of order events dropped because
+// the batching queue was full.
+var errKafkaQueueFull = errors.New("kafka batch queue full")
+
+// orderEventBatcher decouples PlaceOrder from Kafka: order events are queued
+// and a background flusher publishes them in batches of up to batchSize, or
+// whatever has queued up every interval. Each event carries a pendingAck with
+// its producer span in its metadata, and the span ends once Kafka
+// acknowledges the message. The flusher is the only reader of the producer's
+// acknowledgements, so other publishers such as the dead letter replay go
+// through sendSync, and the kafkaQueueProblems overload does not run in this
+// mode.
+type orderEventBatcher struct {
+	producer   sarama.AsyncProducer
+	events     chan *sarama.ProducerMessage
+	batchSize  int
+	interval   time.Duration
+	deadLetter func(context.Context, *sarama.ProducerMessage, error)
+	done       chan struct{}
+}
+
+// orderEventBatcherFromEnv returns a batcher for producer when KAFKA_BATCH_SIZE
+// is positive, tuned by KAFKA_BATCH_INTERVAL_MS and KAFKA_BATCH_QUEUE_SIZE.
+// Order events are published synchronously when it returns nil.
+func orderEventBatcherFromEnv(producer sarama.AsyncProducer, deadLetter func(context.Context, *sarama.ProducerMessage, error)) *orderEventBatcher {
+	batchSize := envInt("KAFKA_BATCH_SIZE", 0)
+	if producer == nil || batchSize <= 0 {
+		return nil
+	}
+	return newOrderEventBatcher(producer, batchSize,
+		envDurationMs("KAFKA_BATCH_INTERVAL_MS", defaultKafkaBatchInterval),
+		envInt("KAFKA_BATCH_QUEUE_SIZE", defaultKafkaBatchQueueSize),
+		deadLetter)
+}
+
+func newOrderEventBatcher(producer sarama.AsyncProducer, batchSize int, interval time.Duration, queueSize int, deadLetter func(context.Context, *sarama.ProducerMessage, error)) *orderEventBatcher {
+	return &orderEventBatcher{
+		producer:   producer,
+		events:     make(chan *sarama.ProducerMessage, max(queueSize, batchSize)),
+		batchSize:  batchSize,
+		interval:   interval,
+		deadLetter: deadLetter,
+		done:       make(chan struct{}),
+	}
+}
+
+// enqueue queues msg for publishing without waiting for Kafka. The message is
+// dead-lettered when the queue is full.
+func (b *orderEventBatcher) enqueue(ctx context.Context, msg *sarama.ProducerMessage) {
+	span := createProducerSpan(ctx, msg)
+	msg.Metadata = &pendingAck{span: span, start: time.Now()}
+	select {
+	case b.events <- msg:
+	default:
+		span.SetAttributes(attribute.Bool("messaging.kafka.producer.success", false))
+		span.SetStatus(otelcodes.Error, errKafkaQueueFull.Error())
+		span.End()
+		logger.ErrorContext(ctx, "Kafka batch queue is full, dropping order event")
+		b.deadLetter(ctx, msg, errKafkaQueueFull)
+	}
+}
+
+// sendSync queues msg with the next batch and waits for the flusher to
+// receive its acknowledgement, for callers such as the dead letter replay that
+// must know whether it was published. Unlike enqueue, it waits for room in the
+// queue until ctx is done, and it leaves failed messages to the caller.
+func (b *orderEventBatcher) sendSync(ctx context.Context, msg *sarama.ProducerMessage) error {
+	span := createProducerSpan(ctx, msg)
+	result := make(chan error, 1)
+	msg.Metadata = &pendingAck{span: span, start: time.Now(), result: result}
+	select {
+	case b.events <- msg:
+	case <-ctx.Done():
+		span.SetAttributes(attribute.Bool("messaging.kafka.producer.success", false))
+		span.SetStatus(otelcodes.Error, "Failed to send: "+ctx.Err().Error())
+		span.End()
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run flushes queued events until ctx is done, then publishes whatever is
+// still queued and returns.
+func (b *orderEventBatcher) run(ctx context.Context) {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]*sarama.ProducerMessage, 0, b.batchSize)
+	for {
+		select {
+		case msg := <-b.events:
+			batch = append(batch, msg)
+			if len(batch) >= b.batchSize {
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ctx.Done():
+		drain:
+			for {
+				select {
+				case msg := <-b.events:
+					batch = append(batch, msg)
+				default:
+					break drain
+				}
+			}
+			if len(batch) > 0 {
+				b.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+// wait blocks until run has published the remaining events, or ctx is done.
+func (b *orderEventBatcher) wait(ctx context.Context) bool {
+	select {
+	case <-b.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// flush hands batch to the producer and waits for every message to be
+// acknowledged, reading results while sending so a batch larger than the
+// producer's buffers cannot deadlock.
+func (b *orderEventBatcher) flush(batch []*sarama.ProducerMessage) {
+	start := time.Now()
+	kafkaBatchSize.Record(context.Background(), int64(len(batch)))
+
+	sent, pending := 0, len(batch)
+	for pending > 0 {
+		var input chan<- *sarama.ProducerMessage
+		var next *sarama.ProducerMessage
+		if sent < len(batch) {
+			input, next = b.producer.Input(), batch[sent]
+		}
+		select {
+		case input <- next:
+			sent++
+		case msg := <-b.producer.Successes():
+			b.finish(msg, nil, start)
+			pending--
+		case perr := <-b.producer.Errors():
+			b.finish(perr.Msg, perr.Err, start)
+			pending--
+		}
+	}
+}
+
+// finish ends the producer span of msg and dead-letters it if publishing
+// failed, unless a sender is waiting for its outcome.
+func (b *orderEventBatcher) finish(msg *sarama.ProducerMessage, err error, start time.Time) {
+	ack, ok := msg.Metadata.(*pendingAck)
+	if !ok {
+		ack = &pendingAck{span: trace.SpanFromContext(context.Background())}
+	}
+	defer ack.span.End()
+	ctx := trace.ContextWithSpan(context.Background(), ack.span)
+
+	ack.span.SetAttributes(
+		attribute.Bool("messaging.kafka.producer.success", err == nil),
+		attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(start).Milliseconds())),
+	)
+	if err != nil {
+		ack.span.SetStatus(otelcodes.Error, err.Error())
+		logger.ErrorContext(ctx, "Failed to write message", "error", err)
+	}
+	if ack.result != nil {
+		ack.result <- err
+		return
+	}
+	if err != nil {
+		b.deadLetter(ctx, msg, err)
+	}
+}
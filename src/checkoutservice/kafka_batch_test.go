@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// batchSizes returns how many batches checkout.kafka.batch_size has recorded
+// and how many events they held in total.
+func batchSizes(t *testing.T) (batches uint64, events int64) {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := metricReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "checkout.kafka.batch_size" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[int64]).DataPoints {
+				batches += dp.Count
+				events += dp.Sum
+			}
+		}
+	}
+	return batches, events
+}
+
+func TestOrderEventBatcher(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+	for range 7 {
+		producer.ExpectInputAndSucceed()
+	}
+
+	recorder := recordSpans(t)
+	b := newOrderEventBatcher(producer, 5, time.Hour, 10, func(context.Context, *sarama.ProducerMessage, error) {
+		t.Error("order event dead-lettered")
+	})
+	batchesBefore, eventsBefore := batchSizes(t)
+
+	ctx, stop := context.WithCancel(context.Background())
+	go b.run(ctx)
+	for range 7 {
+		b.enqueue(context.Background(), &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("order")})
+	}
+
+	// The first five events fill a batch; the remaining two are flushed on
+	// shutdown, as the interval never elapses.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if batches, _ := batchSizes(t); batches > batchesBefore {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no batch flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !b.wait(waitCtx) {
+		t.Fatal("batcher did not publish the queued events on shutdown")
+	}
+
+	batches, events := batchSizes(t)
+	if batches-batchesBefore != 2 || events-eventsBefore != 7 {
+		t.Errorf("published %d events in %d batches, want 7 in 2", events-eventsBefore, batches-batchesBefore)
+	}
+
+	var publishSpans int
+	for _, s := range recorder.Ended() {
+		if s.Name() == "orders publish" {
+			publishSpans++
+		}
+	}
+	if publishSpans != 7 {
+		t.Errorf("%d producer spans ended, want 7", publishSpans)
+	}
+}
+
+func TestOrderEventBatcherDeadLettersWhenFull(t *testing.T) {
+	b := newOrderEventBatcher(mocks.NewAsyncProducer(t, mocks.NewTestConfig()), 1, time.Hour, 1, nil)
+	var dropped []error
+	b.deadLetter = func(_ context.Context, _ *sarama.ProducerMessage, err error) { dropped = append(dropped, err) }
+
+	for range 2 {
+		b.enqueue(context.Background(), &sarama.ProducerMessage{Topic: "orders"})
+	}
+	if len(dropped) != 1 || dropped[0] != errKafkaQueueFull {
+		t.Errorf("dead letters = %v, want one errKafkaQueueFull", dropped)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// languageTag matches the BCP 47 language tags accepted as locales, such as
+// "fr" or "de-DE".
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,3}(?:-[A-Za-z0-9]{2,8})*$`)
+
+// defaultLocaleFromEnv reads CHECKOUT_DEFAULT_LOCALE, the locale of order
+// confirmations when the order names none. Amounts are formatted without
+// digit grouping when it is not set.
+func defaultLocaleFromEnv() string {
+	locale := strings.TrimSpace(os.Getenv("CHECKOUT_DEFAULT_LOCALE"))
+	if locale != "" && !languageTag.MatchString(locale) {
+		logger.Warn("ignoring invalid CHECKOUT_DEFAULT_LOCALE", "locale", locale)
+		return ""
+	}
+	return locale
+}
+
+// orderLocale returns the locale the confirmation of req is written for: the
+// locale of the request, else the preferred language of the accept-language
+// metadata, else the default locale. Malformed tags are skipped. The locale is
+// recorded on the span in ctx.
+func (cs *checkoutService) orderLocale(ctx context.Context, req *pb.PlaceOrderRequest) string {
+	locale := cs.defaultLocale
+	if tag := strings.TrimSpace(req.GetLocale()); languageTag.MatchString(tag) {
+		locale = tag
+	} else if tag := acceptedLanguage(ctx); tag != "" {
+		locale = tag
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("app.order.locale", locale))
+	return locale
+}
+
+// acceptedLanguage returns the first language of the accept-language metadata
+// in ctx, such as "fr-CH" for "fr-CH, fr;q=0.9, en;q=0.8", or "" if there is
+// none.
+func acceptedLanguage(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("accept-language") {
+		for _, language := range strings.Split(header, ",") {
+			tag, _, _ := strings.Cut(language, ";")
+			if tag = strings.TrimSpace(tag); languageTag.MatchString(tag) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
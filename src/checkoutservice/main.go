@@ -6,17 +6,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/IBM/sarama"
 	"github.com/google/uuid"
@@ -44,8 +52,8 @@ import (
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -62,6 +70,42 @@ var resource *sdkresource.Resource
 var initResourcesOnce sync.Once
 var placeOrderCounter metric.Int64Counter
 var placeOrderHistogram metric.Int64Histogram
+var slaBreachCounter metric.Int64Counter
+var reconciliationPending metric.Int64UpDownCounter
+var dependencyHistogram metric.Int64Histogram
+var kafkaProducerUnavailable metric.Int64Counter
+var kafkaBatchSize metric.Int64Histogram
+var kafkaSuccess metric.Int64Counter
+var kafkaError metric.Int64Counter
+var checkoutCancelled metric.Int64Counter
+var checkoutTimeout metric.Int64Counter
+var unknownCurrencyCounter metric.Int64Counter
+var stepDuration metric.Int64Histogram
+var ordersPerUser metric.Int64Histogram
+var activeOrders metric.Int64UpDownCounter
+
+// errKafkaProducerUnavailable is the dead letter cause of orders that could
+// not be published because there is no Kafka producer.
+var errKafkaProducerUnavailable = errors.New("kafka producer unavailable")
+
+// defaultEmailTimeout bounds the order confirmation POST when EMAIL_TIMEOUT_MS
+// is not set.
+const defaultEmailTimeout = 5 * time.Second
+
+// defaultCurrencyConcurrency caps simultaneous currency conversions when
+// CHECKOUT_CURRENCY_CONCURRENCY is not set.
+const defaultCurrencyConcurrency = 8
+
+// clientConnectParams controls how downstream connections are re-established.
+// It defaults to gRPC's own backoff and can be tuned through the environment.
+var clientConnectParams = grpc.ConnectParams{
+	Backoff:           backoff.DefaultConfig,
+	MinConnectTimeout: 20 * time.Second,
+}
+
+// transport secures the gRPC server and the downstream connections. It
+// defaults to plaintext.
+var transport transportSecurity
 
 //var meter   otel.Meter(name)
 
@@ -83,6 +127,98 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	slaBreachCounter, err = meter.Int64Counter("checkout.sla_breach_count",
+		metric.WithDescription("The number of orders that took longer than CHECKOUT_SLA_MS, by the stage that took longest"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	reconciliationPending, err = meter.Int64UpDownCounter("checkout.reconciliation.pending",
+		metric.WithDescription("The number of failed compensations waiting to be retried"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	dependencyHistogram, err = meter.Int64Histogram("checkout.dependency.duration",
+		metric.WithDescription("The distribution of time taken by calls to downstream services, by service"),
+		metric.WithUnit("ms"))
+	if err != nil {
+		panic(err)
+	}
+
+	kafkaProducerUnavailable, err = meter.Int64Counter("checkout.kafka.producer_unavailable",
+		metric.WithDescription("The number of orders not published because the Kafka producer could not be created"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	kafkaBatchSize, err = meter.Int64Histogram("checkout.kafka.batch_size",
+		metric.WithDescription("The number of order events published per batch when batching is enabled"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	kafkaSuccess, err = meter.Int64Counter("checkout.kafka.success",
+		metric.WithDescription("The number of order events Kafka acknowledged when publishing fire-and-forget"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	kafkaError, err = meter.Int64Counter("checkout.kafka.error",
+		metric.WithDescription("The number of order events Kafka failed to write when publishing fire-and-forget"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	checkoutCancelled, err = meter.Int64Counter("checkout.cancelled",
+		metric.WithDescription("The number of orders that failed because the client went away"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	checkoutTimeout, err = meter.Int64Counter("checkout.timeout",
+		metric.WithDescription("The number of orders that failed because they ran out of time"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	unknownCurrencyCounter, err = meter.Int64Counter("checkout.unknown_currency",
+		metric.WithDescription("The number of conversions rejected because the currency is not supported"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	stepDuration, err = meter.Int64Histogram("checkout.step.duration",
+		metric.WithDescription("The distribution of time taken by each checkout step when step metrics are enabled"),
+		metric.WithUnit("ms"))
+	if err != nil {
+		panic(err)
+	}
+
+	ordersPerUser, err = meter.Int64Histogram("checkout.orders_per_user",
+		metric.WithDescription("The number of orders the user placed within CHECKOUT_ORDER_RATE_WINDOW_MS, recorded for each placed order"),
+		metric.WithUnit("1"),
+		metric.WithExplicitBucketBoundaries(1, 2, 3, 5, 10, 20, 50, 100))
+	if err != nil {
+		panic(err)
+	}
+
+	activeOrders, err = meter.Int64UpDownCounter("checkout.active_orders",
+		metric.WithDescription("The number of PlaceOrder calls currently executing"),
+		metric.WithUnit("1"))
+	if err != nil {
+		panic(err)
+	}
 }
 
 func initResource() *sdkresource.Resource {
@@ -134,8 +270,12 @@ func initTracerProvider() *sdktrace.TracerProvider {
 		//log.Fatalf("new otlp trace grpc exporter failed: %v", err)
 		logger.Error("new otlp trace grpc exporter failed")
 	}
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	if filter := attributeFilterFromEnv(); filter != nil {
+		processor = newAttributeFilterProcessor(processor, filter)
+	}
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSpanProcessor(processor),
 		sdktrace.WithResource(initResource()),
 	)
 	otel.SetTracerProvider(tp)
@@ -154,23 +294,24 @@ func initMeterProvider() *sdkmetric.MeterProvider {
 		logger.Error("new otlp metric grpc exporter failed")
 	}
 
+	views, err := placeOrderDurationViews()
+	if err != nil {
+		panic(err)
+	}
+
 	mp := sdkmetric.NewMeterProvider(
 		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
 			exporter, sdkmetric.WithInterval(3*time.Second))),
 		sdkmetric.WithResource(initResource()),
+		sdkmetric.WithView(views...),
 	)
 	otel.SetMeterProvider(mp)
 	return mp
 }
 
 type checkoutService struct {
-	productCatalogSvcAddr string
-	cartSvcAddr           string
-	currencySvcAddr       string
-	shippingSvcAddr       string
-	emailSvcAddr          string
-	paymentSvcAddr        string
-	kafkaBrokerSvcAddr    string
+	emailSvcAddr       string
+	kafkaBrokerSvcAddr string
 	pb.UnimplementedCheckoutServiceServer
 	KafkaProducerClient     sarama.AsyncProducer
 	shippingSvcClient       pb.ShippingServiceClient
@@ -179,6 +320,88 @@ type checkoutService struct {
 	currencySvcClient       pb.CurrencyServiceClient
 	emailSvcClient          pb.EmailServiceClient
 	paymentSvcClient        pb.PaymentServiceClient
+	healthClients           map[string]healthpb.HealthClient
+	emailTimeout            time.Duration
+	slowDependencies        slowDependencies
+	settlementCurrency      string
+	trackingIDPattern       *regexp.Regexp
+	defaultLocale           string
+	skipUnavailableProducts bool
+	maxLineQuantity         int
+	deadLetters             *kafka.DeadLetterQueue
+	currencies              *currencyCache
+	stats                   *runStats
+	currencyConcurrency     *semaphore.Weighted
+	maintenance             maintenanceMode
+	failAfter               failAfterN
+	slaThreshold            time.Duration
+	reconciler              *reconciler
+	audit                   *AuditLogger
+	fallbackRates           fallbackRates
+	strictCartPrices        bool
+	emptyCartOnError        bool
+	orderEvents             *orderEventBatcher
+	overloadMaxMessages     int
+	overloadTimeout         time.Duration
+	rates                   *rateCache
+	stepMetrics             bool
+	mergeDuplicateLines     bool
+	rejectedTopic           string
+	orderRates              *orderRates
+	chargeMinorUnits        bool
+	chargeRetries           int
+	chargeRetryDelay        time.Duration
+	strictQuantities        bool
+	idempotency             *idempotencyCache
+	orders                  *orderStore
+	minDeadline             time.Duration
+	paymentCurrencies       paymentCurrencies
+	kafkaAcks               *kafkaAcks
+	fireAndForget           bool
+	addressRules            addressRules
+	simulationEnabled       bool
+	// warming is set while the rate cache is being prewarmed at startup.
+	warming atomic.Bool
+}
+
+// checkoutDeps are the downstream services a checkoutService calls.
+type checkoutDeps struct {
+	cart           pb.CartServiceClient
+	productCatalog pb.ProductCatalogServiceClient
+	currency       pb.CurrencyServiceClient
+	shipping       pb.ShippingServiceClient
+	payment        pb.PaymentServiceClient
+	email          pb.EmailServiceClient
+	healthClients  map[string]healthpb.HealthClient
+
+	// emailAddr is the base URL order confirmations are posted to.
+	emailAddr string
+	// kafkaProducer publishes placed orders to kafkaBrokerAddr. Orders are
+	// not published when kafkaBrokerAddr is empty.
+	kafkaProducer   sarama.AsyncProducer
+	kafkaBrokerAddr string
+}
+
+// newCheckoutService returns a checkoutService calling deps. Optional
+// behaviour configured from the environment is left disabled.
+func newCheckoutService(deps checkoutDeps) *checkoutService {
+	healthClients := deps.healthClients
+	if healthClients == nil {
+		healthClients = make(map[string]healthpb.HealthClient)
+	}
+	return &checkoutService{
+		emailSvcAddr:            deps.emailAddr,
+		kafkaBrokerSvcAddr:      deps.kafkaBrokerAddr,
+		KafkaProducerClient:     deps.kafkaProducer,
+		shippingSvcClient:       deps.shipping,
+		productCatalogSvcClient: deps.productCatalog,
+		cartSvcClient:           deps.cart,
+		currencySvcClient:       deps.currency,
+		emailSvcClient:          deps.email,
+		paymentSvcClient:        deps.payment,
+		healthClients:           healthClients,
+		stats:                   newRunStats(),
+	}
 }
 
 func main() {
@@ -193,6 +416,7 @@ func main() {
 		}
 	}()
 	global.SetLoggerProvider(lp)
+	logger = stdoutLogsFromEnv(logger, os.Stdout)
 
 	tp := initTracerProvider()
 	defer func() {
@@ -204,9 +428,9 @@ func main() {
 
 	mp := initMeterProvider()
 	defer func() {
-		if err := mp.Shutdown(context.Background()); err != nil {
+		if err := shutdownMeterProvider(mp, envDurationMs("CHECKOUT_METRICS_FLUSH_TIMEOUT_MS", defaultMetricsFlushTimeout)); err != nil {
 			//log.Printf("Error shutting down meter provider: %v", err)
-			logger.Error("Error shutting down meter provider")
+			logger.Error("Error shutting down meter provider", "error", err)
 		}
 	}()
 
@@ -217,52 +441,148 @@ func main() {
 	}
 
 	openfeature.SetProvider(flagd.NewProvider())
-	openfeature.AddHooks(otelhooks.NewTracesHook())
+	flagErrors, err := newFlagErrorsHook(otel.Meter("checkoutservice"), "checkoutservice")
+	if err != nil {
+		panic(err)
+	}
+	openfeature.AddHooks(otelhooks.NewTracesHook(), flagAttributesHook{}, flagErrors)
 
 	tracer = tp.Tracer("checkoutservice")
 
-	svc := new(checkoutService)
+	clientConnectParams = connectParamsFromEnv()
+	if transport, err = transportSecurityFromEnv(); err != nil {
+		panic(err)
+	}
+
+	deps := checkoutDeps{healthClients: make(map[string]healthpb.HealthClient)}
 
-	mustMapEnv(&svc.shippingSvcAddr, "SHIPPING_SERVICE_ADDR")
-	c := mustCreateClient(svc.shippingSvcAddr)
-	svc.shippingSvcClient = pb.NewShippingServiceClient(c)
+	var shippingSvcAddr string
+	mustMapEnv(&shippingSvcAddr, "SHIPPING_SERVICE_ADDR")
+	c := mustCreateClient(shippingSvcAddr)
+	deps.shipping = pb.NewShippingServiceClient(c)
+	deps.healthClients["shipping"] = healthpb.NewHealthClient(c)
 	defer c.Close()
 
-	mustMapEnv(&svc.productCatalogSvcAddr, "PRODUCT_CATALOG_SERVICE_ADDR")
-	c = mustCreateClient(svc.productCatalogSvcAddr)
-	svc.productCatalogSvcClient = pb.NewProductCatalogServiceClient(c)
+	var productCatalogSvcAddr string
+	mustMapEnv(&productCatalogSvcAddr, "PRODUCT_CATALOG_SERVICE_ADDR")
+	c = mustCreateClient(productCatalogSvcAddr)
+	deps.productCatalog = pb.NewProductCatalogServiceClient(c)
+	deps.healthClients["productcatalog"] = healthpb.NewHealthClient(c)
 	defer c.Close()
 
-	mustMapEnv(&svc.cartSvcAddr, "CART_SERVICE_ADDR")
-	c = mustCreateClient(svc.cartSvcAddr)
-	svc.cartSvcClient = pb.NewCartServiceClient(c)
+	var cartSvcAddr string
+	mustMapEnv(&cartSvcAddr, "CART_SERVICE_ADDR")
+	c = mustCreateClient(cartSvcAddr)
+	deps.cart = pb.NewCartServiceClient(c)
+	deps.healthClients["cart"] = healthpb.NewHealthClient(c)
 	defer c.Close()
 
-	mustMapEnv(&svc.currencySvcAddr, "CURRENCY_SERVICE_ADDR")
-	c = mustCreateClient(svc.currencySvcAddr)
-	svc.currencySvcClient = pb.NewCurrencyServiceClient(c)
+	var currencySvcAddr string
+	mustMapEnv(&currencySvcAddr, "CURRENCY_SERVICE_ADDR")
+	c = mustCreateClient(currencySvcAddr)
+	deps.currency = pb.NewCurrencyServiceClient(c)
+	deps.healthClients["currency"] = healthpb.NewHealthClient(c)
 	defer c.Close()
 
-	mustMapEnv(&svc.emailSvcAddr, "EMAIL_SERVICE_ADDR")
-	c = mustCreateClient(svc.emailSvcAddr)
-	svc.emailSvcClient = pb.NewEmailServiceClient(c)
+	var emailSvcAddr string
+	mustMapEnv(&emailSvcAddr, "EMAIL_SERVICE_ADDR")
+	if deps.emailAddr, err = parseEmailEndpoint(emailSvcAddr); err != nil {
+		panic(err)
+	}
+	logger.Info("email service endpoint", "url", deps.emailAddr)
+	c = mustCreateClient(emailSvcAddr)
+	deps.email = pb.NewEmailServiceClient(c)
 	defer c.Close()
 
-	mustMapEnv(&svc.paymentSvcAddr, "PAYMENT_SERVICE_ADDR")
-	c = mustCreateClient(svc.paymentSvcAddr)
-	svc.paymentSvcClient = pb.NewPaymentServiceClient(c)
+	var paymentSvcAddr string
+	mustMapEnv(&paymentSvcAddr, "PAYMENT_SERVICE_ADDR")
+	c = mustCreateClient(paymentSvcAddr)
+	deps.payment = pb.NewPaymentServiceClient(c)
+	deps.healthClients["payment"] = healthpb.NewHealthClient(c)
 	defer c.Close()
 
-	svc.kafkaBrokerSvcAddr = os.Getenv("KAFKA_SERVICE_ADDR")
+	deps.kafkaBrokerAddr = os.Getenv("KAFKA_SERVICE_ADDR")
 
-	if svc.kafkaBrokerSvcAddr != "" {
-		svc.KafkaProducerClient, err = kafka.CreateKafkaProducer([]string{svc.kafkaBrokerSvcAddr}, nil)
+	if deps.kafkaBrokerAddr != "" {
+		deps.kafkaProducer, err = kafka.CreateKafkaProducer([]string{deps.kafkaBrokerAddr}, nil)
 		if err != nil {
 			logger.Error(err.Error())
 			//log.Fatal(err)
 		}
 	}
 
+	svc := newCheckoutService(deps)
+	svc.emailTimeout = envDurationMs("EMAIL_TIMEOUT_MS", defaultEmailTimeout)
+	svc.maxLineQuantity = envInt("CHECKOUT_MAX_LINE_QUANTITY", 0)
+	svc.currencies = newCurrencyCache(envDurationMs("CHECKOUT_CURRENCY_CACHE_TTL_MS", defaultCurrencyCacheTTL))
+	svc.rates = newRateCache(envDurationMs("CHECKOUT_RATE_CACHE_TTL_MS", 0))
+	svc.currencyConcurrency = semaphore.NewWeighted(int64(envInt("CHECKOUT_CURRENCY_CONCURRENCY", defaultCurrencyConcurrency)))
+	svc.slaThreshold = envDurationMs("CHECKOUT_SLA_MS", 0)
+	svc.reconciler = newReconciler(
+		envDurationMs("CHECKOUT_RECONCILE_BASE_DELAY_MS", defaultReconcileBaseDelay),
+		envDurationMs("CHECKOUT_RECONCILE_MAX_DELAY_MS", defaultReconcileMaxDelay),
+		envInt("CHECKOUT_RECONCILE_MAX_ATTEMPTS", defaultReconcileMaxAttempts),
+	)
+	reconcileCtx, stopReconciler := context.WithCancel(context.Background())
+	go svc.reconciler.run(reconcileCtx)
+
+	if svc.audit, err = auditLoggerFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.fallbackRates, err = fallbackRatesFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.paymentCurrencies, err = paymentCurrenciesFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.addressRules, err = addressRulesFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.slowDependencies, err = slowDependenciesFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.settlementCurrency, err = settlementCurrencyFromEnv(); err != nil {
+		panic(err)
+	}
+	if svc.trackingIDPattern, err = trackingIDPatternFromEnv(); err != nil {
+		panic(err)
+	}
+	prewarmCurrencies, err := prewarmCurrenciesFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	svc.strictCartPrices, _ = strconv.ParseBool(os.Getenv("CHECKOUT_STRICT_CART_PRICES"))
+	svc.emptyCartOnError, _ = strconv.ParseBool(os.Getenv("CHECKOUT_EMPTY_CART_ON_ERROR"))
+	svc.stepMetrics, _ = strconv.ParseBool(os.Getenv("CHECKOUT_STEP_METRICS"))
+	svc.mergeDuplicateLines, _ = strconv.ParseBool(os.Getenv("CHECKOUT_MERGE_DUPLICATE_LINES"))
+	svc.chargeMinorUnits, _ = strconv.ParseBool(os.Getenv("CHECKOUT_CHARGE_MINOR_UNITS"))
+	svc.strictQuantities, _ = strconv.ParseBool(os.Getenv("CHECKOUT_STRICT_QUANTITIES"))
+	svc.simulationEnabled, _ = strconv.ParseBool(os.Getenv("CHECKOUT_SIMULATION_ENABLED"))
+	svc.skipUnavailableProducts, _ = strconv.ParseBool(os.Getenv("CHECKOUT_SKIP_UNAVAILABLE_PRODUCTS"))
+	svc.maintenance.enabled, _ = strconv.ParseBool(os.Getenv("CHECKOUT_MAINTENANCE_MODE"))
+	svc.fireAndForget, _ = strconv.ParseBool(os.Getenv("KAFKA_FIRE_AND_FORGET"))
+	svc.minDeadline = envDurationMs("CHECKOUT_MIN_DEADLINE_MS", 0)
+	svc.defaultLocale = defaultLocaleFromEnv()
+	svc.chargeRetries = envInt("CHECKOUT_CHARGE_RETRIES", 0)
+	svc.chargeRetryDelay = envDurationMs("CHECKOUT_CHARGE_RETRY_DELAY_MS", defaultChargeRetryDelay)
+	svc.orders = newOrderStore(envInt("CHECKOUT_ORDER_STORE_SIZE", 0))
+	svc.idempotency = newIdempotencyCache(envDurationMs("CHECKOUT_IDEMPOTENCY_TTL_MS", 0), envInt("CHECKOUT_IDEMPOTENCY_MAX_KEYS", defaultIdempotencyMaxKeys))
+	svc.rejectedTopic = rejectedTopicFromEnv()
+	svc.orderRates = newOrderRates(envDurationMs("CHECKOUT_ORDER_RATE_WINDOW_MS", 0), envInt("CHECKOUT_ORDER_RATE_MAX_USERS", defaultOrderRateMaxUsers))
+	svc.overloadMaxMessages = envInt("CHECKOUT_KAFKA_OVERLOAD_MAX", defaultOverloadMaxMessages)
+	svc.overloadTimeout = envDurationMs("CHECKOUT_KAFKA_OVERLOAD_TIMEOUT_MS", defaultOverloadTimeout)
+	if path := os.Getenv("KAFKA_DLQ_PATH"); path != "" {
+		svc.deadLetters = kafka.NewDeadLetterQueue(path)
+	}
+	orderEventsCtx, stopOrderEvents := context.WithCancel(context.Background())
+	if svc.orderEvents = orderEventBatcherFromEnv(svc.KafkaProducerClient, svc.deadLetter); svc.orderEvents != nil {
+		go svc.orderEvents.run(orderEventsCtx)
+	} else if svc.KafkaProducerClient != nil {
+		// the batcher reads its own acknowledgements
+		svc.kafkaAcks = newKafkaAcks(svc.KafkaProducerClient, svc.deadLetter)
+		go svc.kafkaAcks.run()
+	}
+
 	logger.Info("service config", "config", svc)
 	//log.Infof("service config: %+v", svc)
 
@@ -272,16 +592,62 @@ func main() {
 		logger.Error(err.Error())
 	}
 
-	var srv = grpc.NewServer(
+	var srv = grpc.NewServer(append(transport.serverOptions(),
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-	)
+		grpc.ChainUnaryInterceptor(requestIDsFromEnv().unaryInterceptor),
+	)...)
 	pb.RegisterCheckoutServiceServer(srv, svc)
 	healthpb.RegisterHealthServer(srv, svc)
+	registerReflection(srv)
 	logger.Info("starting to listen on tcp", "addr", lis.Addr())
 	//log.Infof("starting to listen on tcp: %q", lis.Addr().String())
-	err = srv.Serve(lis)
-	//log.Fatal(err)
-	logger.Error(err.Error())
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		<-sigs
+		srv.GracefulStop()
+	}()
+
+	// readiness stays down until the rate cache is warm
+	svc.warming.Store(true)
+	prewarmCtx, cancelPrewarm := context.WithTimeout(context.Background(), envDurationMs("CHECKOUT_PREWARM_TIMEOUT_MS", defaultPrewarmTimeout))
+	go func() {
+		defer cancelPrewarm()
+		svc.prewarmRates(prewarmCtx, prewarmCurrencies)
+	}()
+
+	if err := srv.Serve(lis); err != nil {
+		//log.Fatal(err)
+		logger.Error(err.Error())
+	}
+
+	stopReconciler()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 10*time.Second)
+	if pending := svc.reconciler.drain(drainCtx); pending > 0 {
+		logger.Error("compensations still pending at shutdown", "pending", pending)
+	}
+	cancelDrain()
+
+	stopOrderEvents()
+	if svc.orderEvents != nil {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 10*time.Second)
+		if !svc.orderEvents.wait(flushCtx) {
+			logger.Error("order events still queued at shutdown")
+		}
+		cancelFlush()
+	}
+
+	var kafkaFlush string
+	var kafkaErr error
+	if svc.kafkaAcks != nil {
+		closeCtx, cancelClose := context.WithTimeout(context.Background(), 10*time.Second)
+		kafkaFlush, kafkaErr = svc.kafkaAcks.close(closeCtx)
+		cancelClose()
+	} else {
+		kafkaFlush, kafkaErr = flushKafka(svc.KafkaProducerClient)
+	}
+	logger.Info("shutdown report", svc.stats.report(time.Now(), kafkaFlush, kafkaErr).logArgs()...)
 }
 
 func mustMapEnv(target *string, envKey string) {
@@ -292,7 +658,55 @@ func mustMapEnv(target *string, envKey string) {
 	*target = v
 }
 
+// envDurationMs reads envKey as a number of milliseconds, falling back to def
+// when the variable is unset or not a positive integer.
+func envDurationMs(envKey string, def time.Duration) time.Duration {
+	v := os.Getenv(envKey)
+	if v == "" {
+		return def
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms <= 0 {
+		logger.Warn("ignoring invalid environment variable", "key", envKey, "value", v)
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// envInt reads envKey as a positive integer, falling back to def when the
+// variable is unset or invalid.
+func envInt(envKey string, def int) int {
+	v := os.Getenv(envKey)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		logger.Warn("ignoring invalid environment variable", "key", envKey, "value", v)
+		return def
+	}
+	return i
+}
+
+// envFloat reads envKey as a positive float, falling back to def when the
+// variable is unset or invalid.
+func envFloat(envKey string, def float64) float64 {
+	v := os.Getenv(envKey)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		logger.Warn("ignoring invalid environment variable", "key", envKey, "value", v)
+		return def
+	}
+	return f
+}
+
 func (cs *checkoutService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if cs.warming.Load() {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
 	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
 }
 
@@ -300,21 +714,88 @@ func (cs *checkoutService) Watch(req *healthpb.HealthCheckRequest, ws healthpb.H
 	return status.Errorf(codes.Unimplemented, "health check via Watch not implemented")
 }
 
-func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderRequest) (*pb.PlaceOrderResponse, error) {
+func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderRequest) (resp *pb.PlaceOrderResponse, err error) {
 	span := trace.SpanFromContext(ctx)
 	defer span.End()
 
+	activeOrders.Add(ctx, 1)
+	// deferred before anything can fail, so errors and panics decrement it too
+	defer activeOrders.Add(context.WithoutCancel(ctx), -1)
+
+	audit := &orderAudit{userID: req.UserId, currency: req.UserCurrency}
+	defer func() { cs.audit.logOrder(ctx, audit, err) }()
+
+	ctx, currentStep := withStepTracker(ctx)
+	defer func() {
+		if err != nil {
+			cs.publishRejection(ctx, req, audit.orderID, checkoutStep(currentStep.Load()), err)
+		}
+	}()
+
 	span.SetAttributes(
 		attribute.String("app.user.id", req.UserId),
 		attribute.String("app.user.currency", req.UserCurrency),
 	)
-	logger.InfoContext(ctx, "[PlaceOrder]", "user_id", req.UserId, "user_currency", req.UserCurrency)
+	logger.InfoContext(ctx, "[PlaceOrder]", "user_id", req.UserId, "user_currency", req.UserCurrency, "request_id", requestIDFromContext(ctx))
 	// log.Infof("[PlaceOrder] user_id=%q user_currency=%q", req.UserId, req.UserCurrency)
 
-	var err error
+	ctx = withFlagEvaluationContext(ctx, req.UserId, req.UserCurrency)
+
+	if err := cs.maintenance.check(ctx, cs); err != nil {
+		span.AddEvent("rejected for maintenance")
+		return nil, err
+	}
+	if err := checkDeadline(ctx, cs.minDeadline); err != nil {
+		span.AddEvent("rejected, deadline too short")
+		return nil, err
+	}
+	if err := cs.failAfter.check(ctx, cs); err != nil {
+		cs.stats.orderFailed()
+		span.RecordError(err)
+		return nil, err
+	}
+
+	endStep := cs.startStep(ctx, stepValidate)
+	address, err := normalizeAddress(req.Address)
+	if err == nil {
+		err = cs.addressRules.check(address)
+	}
+	if err == nil && !money.IsValidCurrencyCode(req.UserCurrency) {
+		err = status.Errorf(codes.InvalidArgument, "invalid currency code %q", req.UserCurrency)
+	}
+	if err == nil {
+		err = validateIdempotencyKey(req.IdempotencyKey)
+	}
+	if err == nil {
+		err = cs.paymentCurrencies.check(ctx, req.UserCurrency)
+	}
+	endStep(err)
+	if err != nil {
+		cs.stats.orderFailed()
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if req.IdempotencyKey != "" {
+		span.SetAttributes(attribute.Bool("app.order.idempotent", true))
+	}
+	if placed, err := cs.idempotency.begin(req.UserId, req.IdempotencyKey); err != nil || placed != nil {
+		if placed != nil {
+			span.AddEvent("order already placed with this idempotency key")
+			audit.orderID = placed.GetOrder().GetOrderId()
+		}
+		return placed, err
+	}
+	defer func() { cs.idempotency.finish(req.UserId, req.IdempotencyKey, resp) }()
+
+	timer := newOrderTimer()
+	defer cs.recordOrderDuration(ctx, timer)
+
 	defer func() {
 		if err != nil {
+			cs.stats.orderFailed()
 			span.RecordError(err)
+			err = recordContextFailure(ctx, err)
 			//span.AddEvent("error", trace.WithAttributes(semconv.ExceptionMessageKey.String(err.Error())))
 		}
 	}()
@@ -324,52 +805,82 @@ func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderReq
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to generate order uuid")
 	}
-
-	prep, err := cs.prepareOrderItemsAndShippingQuoteFromCart(ctx, req.UserId, req.UserCurrency, req.Address)
+	audit.orderID = orderID.String()
+
+	prepCtx, currencyFallback := withCurrencyFallbackMarker(ctx)
+	done := timer.stage("prepare")
+	prep, err := cs.prepareOrderItemsAndShippingQuoteFromCart(prepCtx, req.UserId, req.UserCurrency, address)
+	done()
+	if currencyFallback.Load() {
+		span.SetAttributes(attribute.Bool("app.currency.fallback", true))
+	}
 	if err != nil {
-		logger.ErrorContext(ctx, err.Error(), "event", "prepareOrderItemsAndShippingQuoteFromCart failed")
+		logger.ErrorContext(ctx, err.Error(), "event", "prepareOrderItemsAndShippingQuoteFromCart failed", "request_id", requestIDFromContext(ctx))
 		span.RecordError(err)
+		if _, ok := status.FromError(err); ok {
+			// validation failures already carry the status to return
+			return nil, err
+		}
 		return nil, status.Errorf(codes.Internal, err.Error())
 	}
-	span.AddEvent("prepared")
+	ctx = withFlagCartSize(ctx, prep.cartItems)
 
-	total := &pb.Money{CurrencyCode: req.UserCurrency,
-		Units: 0,
-		Nanos: 0}
-	total = money.Must(money.Sum(total, prep.shippingCostLocalized))
+	amounts := []*pb.Money{prep.shippingCostLocalized}
 	for _, it := range prep.orderItems {
-		multPrice := money.MultiplySlow(it.Cost, uint32(it.GetItem().GetQuantity()))
-		total = money.Must(money.Sum(total, multPrice))
+		amounts = append(amounts, money.MultiplySlow(it.Cost, uint32(it.GetItem().GetQuantity())))
 	}
-
-	txID, err := cs.chargeCard(ctx, total, req.CreditCard)
+	total, err := money.SumAll(req.UserCurrency, amounts...)
 	if err != nil {
-		logger.ErrorContext(ctx, err.Error(), "event", "chargeCard failed")
+		logger.ErrorContext(ctx, err.Error(), "event", "order total failed", "request_id", requestIDFromContext(ctx))
+		return nil, status.Errorf(codes.Internal, "failed to compute order total: %v", err)
+	}
+	audit.total = total
+
+	done = timer.stage("charge")
+	endStep = cs.startStep(ctx, stepCharge)
+	charged, err := cs.settlementAmount(ctx, total)
+	var txID string
+	if err == nil {
+		txID, err = cs.chargeCard(ctx, charged, req.CreditCard, chargeIdempotencyKey(req.UserId, req.IdempotencyKey, orderID.String()))
+	}
+	done()
+	if err != nil {
+		endStep(err)
+		logger.ErrorContext(ctx, err.Error(), "event", "chargeCard failed", "request_id", requestIDFromContext(ctx))
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to charge card: %+v", err)
 	}
 	logger.InfoContext(ctx, "payment went through", "transaction_id", txID)
 
 	// log.Infof("payment went through (transaction_id: %s)", txID)
-	span.AddEvent("charged",
-		trace.WithAttributes(attribute.String("app.payment.transaction.id", txID)))
+	endStep(nil, attribute.String("app.payment.transaction.id", txID))
 
-	shippingTrackingID, err := cs.shipOrder(ctx, req.Address, prep.cartItems)
+	done = timer.stage("ship")
+	endStep = cs.startStep(ctx, stepShip)
+	shippingTrackingID, err := cs.shipOrder(ctx, address, prep.cartItems)
+	done()
 	if err != nil {
-		logger.ErrorContext(ctx, err.Error(), "event", "shipOrder failed")
+		endStep(err)
+		logger.ErrorContext(ctx, err.Error(), "event", "shipOrder failed", "request_id", requestIDFromContext(ctx))
 		span.RecordError(err)
+		cs.refundCharge(context.WithoutCancel(ctx), orderID.String(), txID, charged)
+		if _, ok := status.FromError(err); ok {
+			// a shipment without a valid tracking ID already carries its status
+			return nil, err
+		}
 		return nil, status.Errorf(codes.Unavailable, "shipping error: %+v", err)
 	}
 	shippingTrackingAttribute := attribute.String("app.shipping.tracking.id", shippingTrackingID)
-	span.AddEvent("shipped", trace.WithAttributes(shippingTrackingAttribute))
+	endStep(nil, shippingTrackingAttribute)
 
-	_ = cs.emptyUserCart(ctx, req.UserId)
+	endStep = cs.startStep(ctx, stepEmptyCart)
+	endStep(cs.emptyUserCart(ctx, req.UserId, prep.skippedItems))
 
 	orderResult := &pb.OrderResult{
 		OrderId:            orderID.String(),
 		ShippingTrackingId: shippingTrackingID,
 		ShippingCost:       prep.shippingCostLocalized,
-		ShippingAddress:    req.Address,
+		ShippingAddress:    address,
 		Items:              prep.orderItems,
 	}
 
@@ -384,51 +895,144 @@ func (cs *checkoutService) PlaceOrder(ctx context.Context, req *pb.PlaceOrderReq
 		shippingTrackingAttribute,
 	)
 
-	if err := cs.sendOrderConfirmation(ctx, req.Email, orderResult); err != nil {
+	done = timer.stage("confirm")
+	endStep = cs.startStep(ctx, stepEmail)
+	if err := cs.sendOrderConfirmation(ctx, req.Email, orderResult, cs.orderLocale(ctx, req)); err != nil {
 		logger.WarnContext(ctx, "failed to send order confirmation", "receiver", req.Email, "error", err.Error())
 		//log.Warnf("failed to send order confirmation to %q: %+v", req.Email, err)
+		endStep(err)
 	} else {
 		logger.InfoContext(ctx, "order confirmation email sent", "receiver", req.Email)
 		//log.Infof("order confirmation email sent to %q", req.Email)
+		endStep(nil)
 	}
 
+	done()
+
 	// send to kafka only if kafka broker address is set
 	if cs.kafkaBrokerSvcAddr != "" {
+		done = timer.stage("publish")
+		defer done()
 		logger.InfoContext(ctx, "sending to postProcessor")
 		//log.Infof("sending to postProcessor")
+		endStep = cs.startStep(ctx, stepPublish)
 		cs.sendToPostProcessor(ctx, orderResult)
+		endStep(nil)
 	}
 
 	placeOrderCounter.Add(ctx, 1)
-	resp := &pb.PlaceOrderResponse{Order: orderResult}
-	return resp, nil
+	cs.orderRates.record(ctx, req.UserId)
+	cs.orders.put(orderResult)
+	cs.stats.orderPlaced()
+	cs.failAfter.orderPlaced()
+	return &pb.PlaceOrderResponse{Order: orderResult}, nil
 }
 
 type orderPrep struct {
 	orderItems            []*pb.OrderItem
 	cartItems             []*pb.CartItem
+	skippedItems          []*pb.CartItem // left out of the order, and kept in the cart
 	shippingCostLocalized *pb.Money
 }
 
 func (cs *checkoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, userID, userCurrency string, address *pb.Address) (orderPrep, error) {
+	// step events go to the PlaceOrder span, alongside those of later steps
+	orderCtx := ctx
 	ctx, span := tracer.Start(ctx, "prepareOrderItemsAndShippingQuoteFromCart")
 	defer span.End()
 
 	var out orderPrep
-	cartItems, err := cs.getUserCart(ctx, userID)
+	var cartItems []*pb.CartItem
+	// pricing starts with reading the cart
+	endStep := cs.startStep(orderCtx, stepPrice)
+	err := prepStep(ctx, "getUserCart", func(ctx context.Context) (err error) {
+		cartItems, err = cs.getUserCart(ctx, userID)
+		return err
+	})
+	if err != nil && cs.emptyCartOnError {
+		// an unavailable cart is treated as an empty one, which cannot be
+		// checked out
+		logger.WarnContext(ctx, "cart unavailable, treating it as empty", "error", err.Error())
+		span.AddEvent("cart unavailable, treated as empty", trace.WithAttributes(
+			attribute.String("app.cart.error", err.Error()),
+		))
+		err = status.Error(codes.FailedPrecondition, "cart is empty or unavailable")
+		endStep(err)
+		return out, err
+	}
 	if err != nil {
+		endStep(err)
 		return out, fmt.Errorf("cart failure: %+v", err)
 	}
-	orderItems, err := cs.prepOrderItems(ctx, cartItems, userCurrency)
+	kept, err := cs.dropEmptyLines(cartItems)
+	if err != nil {
+		endStep(err)
+		return out, err
+	}
+	if n := len(cartItems) - len(kept); n > 0 {
+		logger.WarnContext(ctx, "dropped cart lines without a positive quantity", "lines", n)
+		span.SetAttributes(attribute.Int("app.cart.dropped_lines", n))
+	}
+	cartItems = kept
+	if cs.mergeDuplicateLines {
+		merged := mergeDuplicateLines(cartItems)
+		if n := len(cartItems) - len(merged); n > 0 {
+			span.SetAttributes(attribute.Int("app.cart.merged_lines", n))
+		}
+		cartItems = merged
+	}
+	if err := cs.validateCartItems(cartItems); err != nil {
+		endStep(err)
+		return out, err
+	}
+	var orderItems []*pb.OrderItem
+	err = prepStep(ctx, "prepOrderItems", func(ctx context.Context) (err error) {
+		orderItems, err = cs.prepOrderItems(ctx, cartItems, userCurrency)
+		return err
+	})
+	endStep(err)
 	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return out, err
+		}
 		return out, fmt.Errorf("failed to prepare order: %+v", err)
 	}
-	shippingUSD, err := cs.quoteShipping(ctx, address, cartItems)
+	if n := len(cartItems) - len(orderItems); n > 0 {
+		span.SetAttributes(attribute.Int("app.cart.skipped_lines", n))
+		ordered := make(map[*pb.CartItem]bool, len(orderItems))
+		for _, it := range orderItems {
+			ordered[it.GetItem()] = true
+		}
+		for _, item := range cartItems {
+			if !ordered[item] {
+				out.skippedItems = append(out.skippedItems, item)
+			}
+		}
+		cartItems = make([]*pb.CartItem, len(orderItems))
+		for i, it := range orderItems {
+			cartItems[i] = it.GetItem()
+		}
+	}
+	var shippingUSD *pb.Money
+	endStep = cs.startStep(orderCtx, stepQuoteShipping)
+	err = prepStep(ctx, "quoteShipping", func(ctx context.Context) (err error) {
+		shippingUSD, err = cs.quoteShipping(ctx, address, cartItems)
+		return err
+	})
 	if err != nil {
+		endStep(err)
 		return out, fmt.Errorf("shipping quote failure: %+v", err)
 	}
-	shippingPrice, err := cs.convertCurrency(ctx, shippingUSD, userCurrency)
+	var shippingPrice *pb.Money
+	err = prepStep(ctx, "convertShippingCost", func(ctx context.Context) (err error) {
+		shippingPrice, err = cs.convertCurrency(ctx, shippingUSD, userCurrency)
+		return err
+	})
+	endStep(err)
 	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return out, err
+		}
 		return out, fmt.Errorf("failed to convert shipping cost to currency: %+v", err)
 	}
 
@@ -450,10 +1054,43 @@ func (cs *checkoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context
 	return out, nil
 }
 
+// prepStep runs one step of preparing an order in a child span named after
+// the step, so traces show which step dominates the preparation latency.
+func prepStep(ctx context.Context, name string, step func(context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attribute.String("app.checkout.step", name)))
+	defer span.End()
+
+	err := step(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return err
+}
+
+// connectParamsFromEnv builds the reconnection backoff applied to every
+// downstream client, starting from gRPC's defaults.
+func connectParamsFromEnv() grpc.ConnectParams {
+	params := grpc.ConnectParams{
+		Backoff:           backoff.DefaultConfig,
+		MinConnectTimeout: 20 * time.Second,
+	}
+	params.Backoff.BaseDelay = envDurationMs("CHECKOUT_CLIENT_BACKOFF_BASE_DELAY_MS", params.Backoff.BaseDelay)
+	params.Backoff.MaxDelay = envDurationMs("CHECKOUT_CLIENT_BACKOFF_MAX_DELAY_MS", params.Backoff.MaxDelay)
+	params.Backoff.Multiplier = envFloat("CHECKOUT_CLIENT_BACKOFF_MULTIPLIER", params.Backoff.Multiplier)
+	if params.Backoff.MaxDelay < params.Backoff.BaseDelay {
+		logger.Warn("client backoff max delay is below the base delay, using the base delay",
+			"base_delay", params.Backoff.BaseDelay, "max_delay", params.Backoff.MaxDelay)
+		params.Backoff.MaxDelay = params.Backoff.BaseDelay
+	}
+	return params
+}
+
 func mustCreateClient(svcAddr string) *grpc.ClientConn {
 	c, err := grpc.NewClient(svcAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(transport.clientCredentials()),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithConnectParams(clientConnectParams),
 	)
 	if err != nil {
 		logger.Error("could not connect", "service", svcAddr, "error", err.Error())
@@ -464,10 +1101,12 @@ func mustCreateClient(svcAddr string) *grpc.ClientConn {
 }
 
 func (cs *checkoutService) quoteShipping(ctx context.Context, address *pb.Address, items []*pb.CartItem) (*pb.Money, error) {
+	done := cs.observeDependency(ctx, dependencyShipping)
 	shippingQuote, err := cs.shippingSvcClient.
 		GetQuote(ctx, &pb.GetQuoteRequest{
 			Address: address,
 			Items:   items})
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get shipping quote: %+v", err)
 	}
@@ -475,50 +1114,144 @@ func (cs *checkoutService) quoteShipping(ctx context.Context, address *pb.Addres
 }
 
 func (cs *checkoutService) getUserCart(ctx context.Context, userID string) ([]*pb.CartItem, error) {
+	done := cs.observeDependency(ctx, dependencyCart)
 	cart, err := cs.cartSvcClient.GetCart(ctx, &pb.GetCartRequest{UserId: userID})
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user cart during checkout: %+v", err)
 	}
 	return cart.GetItems(), nil
 }
 
-func (cs *checkoutService) emptyUserCart(ctx context.Context, userID string) error {
+// emptyUserCart removes the ordered items from the cart of userID. The cart
+// service can only empty a whole cart, so the lines in keep, which were left
+// out of the order, are added back once it is emptied.
+func (cs *checkoutService) emptyUserCart(ctx context.Context, userID string, keep []*pb.CartItem) error {
+	done := cs.observeDependency(ctx, dependencyCart)
+	defer done()
 	if _, err := cs.cartSvcClient.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: userID}); err != nil {
 		return fmt.Errorf("failed to empty user cart during checkout: %+v", err)
 	}
+	for _, item := range keep {
+		if _, err := cs.cartSvcClient.AddItem(ctx, &pb.AddItemRequest{UserId: userID, Item: item}); err != nil {
+			return fmt.Errorf("failed to keep product %q in the cart during checkout: %+v", item.GetProductId(), err)
+		}
+	}
+	return nil
+}
+
+// validateCartItems rejects carts that cannot be priced, returning an
+// InvalidArgument status describing the offending line.
+func (cs *checkoutService) validateCartItems(items []*pb.CartItem) error {
+	for _, item := range items {
+		if cs.maxLineQuantity > 0 && int(item.GetQuantity()) > cs.maxLineQuantity {
+			return status.Errorf(codes.InvalidArgument, "quantity %d of product %q exceeds the maximum of %d per line",
+				item.GetQuantity(), item.GetProductId(), cs.maxLineQuantity)
+		}
+	}
 	return nil
 }
 
+// prepOrderItems prices items in userCurrency. A product the catalog cannot
+// return fails the order with the catalog's status code, unless the catalog
+// is unavailable and skipUnavailableProducts is set: the product is then left
+// out of the order, and in the cart, as long as any product is left.
 func (cs *checkoutService) prepOrderItems(ctx context.Context, items []*pb.CartItem, userCurrency string) ([]*pb.OrderItem, error) {
 	out := make([]*pb.OrderItem, len(items))
 
+	g, ctx := errgroup.WithContext(ctx)
 	for i, item := range items {
-		product, err := cs.productCatalogSvcClient.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get product #%q", item.GetProductId())
-		}
-		price, err := cs.convertCurrency(ctx, product.GetPriceUsd(), userCurrency)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert price of %q to %s", item.GetProductId(), userCurrency)
-		}
-		out[i] = &pb.OrderItem{
-			Item: item,
-			Cost: price}
+		g.Go(func() error {
+			done := cs.observeDependency(ctx, dependencyProductCatalog)
+			product, err := cs.productCatalogSvcClient.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
+			done()
+			if err != nil && cs.skipUnavailableProducts && status.Code(err) == codes.Unavailable {
+				logger.WarnContext(ctx, "product catalog is unavailable, leaving product out of the order",
+					"product_id", item.GetProductId(), "error", err.Error())
+				trace.SpanFromContext(ctx).AddEvent("product skipped", trace.WithAttributes(
+					attribute.String("app.product.id", item.GetProductId()),
+				))
+				return nil
+			}
+			if err != nil {
+				// keep the code, so a missing product is told apart from an outage
+				return status.Errorf(status.Code(err), "failed to get product %q: %s", item.GetProductId(), status.Convert(err).Message())
+			}
+			if err := cs.checkCartPrice(ctx, item, product); err != nil {
+				return err
+			}
+			price, err := cs.convertCurrency(ctx, product.GetPriceUsd(), userCurrency)
+			if err != nil {
+				if _, ok := status.FromError(err); ok {
+					return err
+				}
+				return fmt.Errorf("failed to convert price of %q to %s", item.GetProductId(), userCurrency)
+			}
+			out[i] = &pb.OrderItem{
+				Item: item,
+				Cost: price}
+			return nil
+		})
 	}
-	return out, nil
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	kept := slices.DeleteFunc(out, func(it *pb.OrderItem) bool { return it == nil })
+	if len(kept) == 0 && len(items) > 0 {
+		return nil, status.Error(codes.Unavailable, "product catalog is unavailable for every product in the cart")
+	}
+	return kept, nil
 }
 
+// convertCurrency converts from to toCurrency. At most currencyConcurrency
+// conversions run at once, so pricing a large cart does not flood the currency
+// service.
 func (cs *checkoutService) convertCurrency(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error) {
+	useRates := cs.rates != nil && !cs.isFeatureFlagEnabled(ctx, "currencyRateCacheBypass")
+	if rate, ok := cs.rates.get(from.GetCurrencyCode(), toCurrency); ok && useRates {
+		if converted, err := money.Convert(from, rate, toCurrency); err == nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("app.currency.rate_cached", true))
+			return converted, nil
+		}
+	}
+
+	if cs.currencyConcurrency != nil {
+		if err := cs.currencyConcurrency.Acquire(ctx, 1); err != nil {
+			return nil, fmt.Errorf("failed to convert currency: %w", err)
+		}
+		defer cs.currencyConcurrency.Release(1)
+	}
+
+	done := cs.observeDependency(ctx, dependencyCurrency)
 	result, err := cs.currencySvcClient.Convert(ctx, &pb.CurrencyConversionRequest{
 		From:   from,
 		ToCode: toCurrency})
+	done()
+	if err != nil && isUnknownCurrency(err) {
+		unknownCurrencyCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("app.currency.code", toCurrency)))
+		logger.WarnContext(ctx, "currency is not supported", "from", from.GetCurrencyCode(), "to", toCurrency, "error", err.Error())
+		return nil, status.Errorf(codes.InvalidArgument, "currency %q is not supported", toCurrency)
+	}
 	if err != nil {
+		if converted, ok := cs.fallbackRates.convert(from, toCurrency); ok {
+			logger.WarnContext(ctx, "currency service failed, converting with fallback rates",
+				"from", from.GetCurrencyCode(), "to", toCurrency, "error", err.Error())
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("app.currency.fallback", true))
+			markCurrencyFallback(ctx)
+			return converted, nil
+		}
 		return nil, fmt.Errorf("failed to convert currency: %+v", err)
 	}
+	if useRates {
+		cs.rates.put(from, result)
+	}
 	return result, err
 }
 
-func (cs *checkoutService) chargeCard(ctx context.Context, amount *pb.Money, paymentInfo *pb.CreditCardInfo) (string, error) {
+// chargeCard charges amount to the card. Charges of orders placed with an
+// idempotency key carry it, and are retried up to chargeRetries times when
+// the payment service is unavailable.
+func (cs *checkoutService) chargeCard(ctx context.Context, amount *pb.Money, paymentInfo *pb.CreditCardInfo, idempotencyKey string) (string, error) {
 	paymentService := cs.paymentSvcClient
 	if cs.isFeatureFlagEnabled(ctx, "paymentServiceUnreachable") {
 		badAddress := "badAddress:50051"
@@ -526,26 +1259,69 @@ func (cs *checkoutService) chargeCard(ctx context.Context, amount *pb.Money, pay
 		paymentService = pb.NewPaymentServiceClient(c)
 	}
 
-	paymentResp, err := paymentService.Charge(ctx, &pb.ChargeRequest{
-		Amount:     amount,
-		CreditCard: paymentInfo})
+	charge := &pb.ChargeRequest{
+		Amount:         amount,
+		CreditCard:     paymentInfo,
+		IdempotencyKey: idempotencyKey}
+	if cs.chargeMinorUnits {
+		minor, err := money.ToMinorUnits(amount, amount.GetCurrencyCode())
+		if err != nil {
+			return "", fmt.Errorf("could not convert %s to minor units: %w", money.Format(amount), err)
+		}
+		charge.AmountMinorUnits = minor
+	}
+
+	var paymentResp *pb.ChargeResponse
+	var err error
+	attempts := cs.chargeAttempts(idempotencyKey)
+	for attempt := 1; ; attempt++ {
+		done := cs.observeDependency(ctx, dependencyPayment)
+		paymentResp, err = paymentService.Charge(ctx, charge)
+		done()
+		if err == nil || attempt == attempts || !chargeRetryable(err) {
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.Bool("app.payment.idempotency_key_used", idempotencyKey != ""),
+				attribute.Int("app.payment.charge_attempts", attempt),
+			)
+			break
+		}
+		logger.WarnContext(ctx, "charge failed, retrying with the same idempotency key", "attempt", attempt, "error", err.Error())
+		if waitErr := cs.waitChargeRetry(ctx); waitErr != nil {
+			break
+		}
+	}
 	if err != nil {
 		return "", fmt.Errorf("could not charge the card: %+v", err)
 	}
 	return paymentResp.GetTransactionId(), nil
 }
 
-func (cs *checkoutService) sendOrderConfirmation(ctx context.Context, email string, order *pb.OrderResult) error {
-	emailServicePayload, err := json.Marshal(map[string]interface{}{
-		"email": email,
-		"order": order,
-	})
+func (cs *checkoutService) sendOrderConfirmation(ctx context.Context, email string, order *pb.OrderResult, locale string) error {
+	payload, err := newConfirmationPayload(email, order, locale)
+	if err != nil {
+		return err
+	}
+	emailServicePayload, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal order to JSON: %+v", err)
 	}
 
+	timeout := cs.emailTimeout
+	if timeout <= 0 {
+		timeout = defaultEmailTimeout
+	}
+	// the confirmation gets its full timeout even when little is left of the
+	// request deadline, since the order is already placed
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	done := cs.observeDependency(ctx, dependencyEmail)
+	defer done()
 	resp, err := otelhttp.Post(ctx, cs.emailSvcAddr+"/send_order_confirmation", "application/json", bytes.NewBuffer(emailServicePayload))
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("email service did not respond within %v: %w", timeout, err)
+		}
 		return fmt.Errorf("failed POST to email service: %+v", err)
 	}
 	defer resp.Body.Close()
@@ -558,12 +1334,28 @@ func (cs *checkoutService) sendOrderConfirmation(ctx context.Context, email stri
 }
 
 func (cs *checkoutService) shipOrder(ctx context.Context, address *pb.Address, items []*pb.CartItem) (string, error) {
-	resp, err := cs.shippingSvcClient.ShipOrder(ctx, &pb.ShipOrderRequest{
+	shippingService := cs.shippingSvcClient
+	// Quotes keep working so that the card is charged and its refund is
+	// exercised when the shipment fails.
+	if cs.isFeatureFlagEnabled(ctx, "shippingServiceUnreachable") {
+		trace.SpanFromContext(ctx).AddEvent("shipping service unreachable")
+		badAddress := "badAddress:50051"
+		c := mustCreateClient(badAddress)
+		defer c.Close()
+		shippingService = pb.NewShippingServiceClient(c)
+	}
+
+	done := cs.observeDependency(ctx, dependencyShipping)
+	resp, err := shippingService.ShipOrder(ctx, &pb.ShipOrderRequest{
 		Address: address,
 		Items:   items})
+	done()
 	if err != nil {
 		return "", fmt.Errorf("shipment failed: %+v", err)
 	}
+	if err := cs.checkTrackingID(ctx, resp.GetTrackingId()); err != nil {
+		return "", err
+	}
 	return resp.GetTrackingId(), nil
 }
 
@@ -580,69 +1372,40 @@ func (cs *checkoutService) sendToPostProcessor(ctx context.Context, result *pb.O
 		Value: sarama.ByteEncoder(message),
 	}
 
-	// Inject tracing info into message
-	span := createProducerSpan(ctx, &msg)
-	defer span.End()
-
-	// Send message and handle response
-	startTime := time.Now()
-	select {
-	case cs.KafkaProducerClient.Input() <- &msg:
-		logger.InfoContext(ctx, "Message sent to Kafkauf", "event", msg)
-		//log.Infof("Message sent to Kafka: %v", msg)
-		select {
-		case successMsg := <-cs.KafkaProducerClient.Successes():
-			span.SetAttributes(
-				attribute.Bool("messaging.kafka.producer.success", true),
-				attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(startTime).Milliseconds())),
-				attribute.KeyValue(semconv.MessagingKafkaOffset(int(successMsg.Offset))),
-			)
-			logger.InfoContext(ctx, "Successful to write message", "offset", successMsg.Offset, "duration", time.Since(startTime))
-			//log.Infof("Successful to write message. offset: %v, duration: %v", successMsg.Offset, time.Since(startTime))
-		case errMsg := <-cs.KafkaProducerClient.Errors():
-			span.SetAttributes(
-				attribute.Bool("messaging.kafka.producer.success", false),
-				attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(startTime).Milliseconds())),
-			)
-			span.SetStatus(otelcodes.Error, errMsg.Err.Error())
-			logger.ErrorContext(ctx, "Failed to write message", "error", errMsg.Err)
-			//log.Errorf("Failed to write message: %v", errMsg.Err)
-		case <-ctx.Done():
-			span.SetAttributes(
-				attribute.Bool("messaging.kafka.producer.success", false),
-				attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(startTime).Milliseconds())),
-			)
-			span.SetStatus(otelcodes.Error, "Context cancelled: "+ctx.Err().Error())
-			logger.WarnContext(ctx, "Context canceled before success message received", "error", ctx.Err)
-			//log.Warnf("Context canceled before success message received: %v", ctx.Err())
-		}
-	case <-ctx.Done():
-		span.SetAttributes(
-			attribute.Bool("messaging.kafka.producer.success", false),
-			attribute.Int("messaging.kafka.producer.duration_ms", int(time.Since(startTime).Milliseconds())),
-		)
-		span.SetStatus(otelcodes.Error, "Failed to send: "+ctx.Err().Error())
-		logger.ErrorContext(ctx, "Failed to send message to Kafka within context deadline", "error", ctx.Err)
-
-		//log.Errorf("Failed to send message to Kafka within context deadline: %v", ctx.Err())
+	if !cs.publish(ctx, &msg) {
 		return
 	}
 
 	ffValue := cs.getIntFeatureFlag(ctx, "kafkaQueueProblems")
 	if ffValue > 0 {
 		logger.WarnContext(ctx, "FeatureFlag 'kafkaQueueProblems' is activated, overloading queue now")
+		go cs.simulateQueueOverload(context.WithoutCancel(ctx), &msg, ffValue)
+	}
+}
 
-		//log.Infof("Warning: FeatureFlag 'kafkaQueueProblems' is activated, overloading queue now.")
-		for i := 0; i < ffValue; i++ {
-			go func(i int) {
-				cs.KafkaProducerClient.Input() <- &msg
-				_ = <-cs.KafkaProducerClient.Successes()
-			}(i)
-		}
-		logger.InfoContext(ctx, "Done with #%d messages for overload simulation.", "amount", ffValue)
+// publish sends msg to Kafka, through the batcher when batching is enabled or
+// through the reader of the producer's acknowledgements otherwise, and
+// dead-letters it when it cannot be delivered. PlaceOrder waits for Kafka to
+// acknowledge msg unless fireAndForget is set. It reports whether msg was
+// handed to the producer directly.
+func (cs *checkoutService) publish(ctx context.Context, msg *sarama.ProducerMessage) bool {
+	// the producer is nil when it could not be created at startup, and
+	// sending to its nil input channel would block forever
+	if cs.KafkaProducerClient == nil {
+		kafkaProducerUnavailable.Add(ctx, 1)
+		logger.ErrorContext(ctx, "Kafka producer is not available, skipping post processing", "topic", msg.Topic)
+		cs.deadLetter(ctx, msg, errKafkaProducerUnavailable)
+		return false
+	}
 
-		//log.Infof("Done with #%d messages for overload simulation.", ffValue)
+	if cs.orderEvents != nil {
+		cs.orderEvents.enqueue(ctx, msg)
+		return false
+	}
+	if cs.fireAndForget {
+		return cs.kafkaAcks.send(ctx, msg)
 	}
+	return cs.kafkaAcks.publish(ctx, msg)
 }
 
 func createProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) trace.Span {
@@ -664,6 +1427,11 @@ func createProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) trace.
 	propagator := otel.GetTextMapPropagator()
 	propagator.Inject(spanContext, carrier)
 
+	// a replayed message already carries the headers of its first attempt
+	msg.Headers = slices.DeleteFunc(msg.Headers, func(h sarama.RecordHeader) bool {
+		_, ok := carrier[string(h.Key)]
+		return ok
+	})
 	for key, value := range carrier {
 		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
 	}
@@ -671,6 +1439,28 @@ func createProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) trace.
 	return span
 }
 
+// withFlagEvaluationContext attaches the caller to ctx as an OpenFeature
+// transaction context, so every flag evaluated while serving the request can be
+// targeted at a user or a cohort of users.
+func withFlagEvaluationContext(ctx context.Context, userID, userCurrency string) context.Context {
+	return openfeature.WithTransactionContext(ctx, openfeature.NewEvaluationContext(userID, map[string]interface{}{
+		"userId":   userID,
+		"currency": userCurrency,
+	}))
+}
+
+// withFlagCartSize adds the total number of items in the cart to the
+// transaction context once the cart is known.
+func withFlagCartSize(ctx context.Context, items []*pb.CartItem) context.Context {
+	var cartSize int64
+	for _, ci := range items {
+		cartSize += int64(ci.GetQuantity())
+	}
+	return openfeature.MergeTransactionContext(ctx, openfeature.NewTargetlessEvaluationContext(map[string]interface{}{
+		"cartSize": cartSize,
+	}))
+}
+
 func (cs *checkoutService) isFeatureFlagEnabled(ctx context.Context, featureFlagName string) bool {
 	client := openfeature.NewClient("checkout")
 
@@ -0,0 +1,220 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// metricReader collects the metrics recorded by the tests.
+var metricReader = sdkmetric.NewManualReader()
+
+func TestMain(m *testing.M) {
+	tracer = otel.Tracer("checkoutservice")
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)))
+	m.Run()
+}
+
+// counterValue returns the current value of the int64 counter name for the
+// data point with the attribute key=value, or of its only data point when key
+// is empty. It returns 0 if there is no such data point.
+func counterValue(t *testing.T, name string, key attribute.Key, value string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := metricReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("metric %s is a %T, want an int64 sum", name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				if key == "" {
+					return dp.Value
+				}
+				if v, ok := dp.Attributes.Value(key); ok && v.AsString() == value {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// recordingProvider is an OpenFeature provider that serves fixed flag values
+// and remembers the evaluation context of the last evaluation.
+type recordingProvider struct {
+	openfeature.NoopProvider
+
+	mu      sync.Mutex
+	bools   map[string]bool
+	ints    map[string]int64
+	lastCtx openfeature.FlattenedContext
+}
+
+func (p *recordingProvider) record(evalCtx openfeature.FlattenedContext) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastCtx = evalCtx
+}
+
+func (p *recordingProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	p.record(evalCtx)
+	if v, ok := p.bools[flag]; ok {
+		return openfeature.BoolResolutionDetail{Value: v}
+	}
+	return openfeature.BoolResolutionDetail{Value: defaultValue}
+}
+
+func (p *recordingProvider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx openfeature.FlattenedContext) openfeature.IntResolutionDetail {
+	p.record(evalCtx)
+	if v, ok := p.ints[flag]; ok {
+		return openfeature.IntResolutionDetail{Value: v}
+	}
+	return openfeature.IntResolutionDetail{Value: defaultValue}
+}
+
+// useFlagProvider installs p as the provider for the "checkout" client for the
+// duration of the test.
+func useFlagProvider(t *testing.T, p openfeature.FeatureProvider) {
+	t.Helper()
+	if err := openfeature.SetNamedProviderAndWait("checkout", p); err != nil {
+		t.Fatalf("SetNamedProviderAndWait: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = openfeature.SetNamedProviderAndWait("checkout", openfeature.NoopProvider{})
+	})
+}
+
+func TestFeatureFlagEvaluationContext(t *testing.T) {
+	p := &recordingProvider{bools: map[string]bool{"paymentServiceUnreachable": true}}
+	useFlagProvider(t, p)
+
+	ctx := withFlagEvaluationContext(context.Background(), "user-1", "EUR")
+	ctx = withFlagCartSize(ctx, []*pb.CartItem{{ProductId: "A", Quantity: 2}, {ProductId: "B", Quantity: 3}})
+
+	cs := &checkoutService{}
+	if !cs.isFeatureFlagEnabled(ctx, "paymentServiceUnreachable") {
+		t.Fatalf("isFeatureFlagEnabled = false, want true")
+	}
+
+	want := map[string]interface{}{
+		openfeature.TargetingKey: "user-1",
+		"userId":                 "user-1",
+		"currency":               "EUR",
+		"cartSize":               int64(5),
+	}
+	for k, v := range want {
+		if got := p.lastCtx[k]; got != v {
+			t.Errorf("evaluation context[%q] = %v, want %v", k, got, v)
+		}
+	}
+
+	p.ints = map[string]int64{"kafkaQueueProblems": 7}
+	if got := cs.getIntFeatureFlag(ctx, "kafkaQueueProblems"); got != 7 {
+		t.Errorf("getIntFeatureFlag = %d, want 7", got)
+	}
+	if got := p.lastCtx[openfeature.TargetingKey]; got != "user-1" {
+		t.Errorf("evaluation context targetingKey = %v, want user-1", got)
+	}
+}
+
+func TestConnectParamsFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		got := connectParamsFromEnv()
+		if got.Backoff != backoff.DefaultConfig {
+			t.Errorf("Backoff = %+v, want %+v", got.Backoff, backoff.DefaultConfig)
+		}
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_BASE_DELAY_MS", "250")
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_MAX_DELAY_MS", "5000")
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_MULTIPLIER", "2.5")
+
+		got := connectParamsFromEnv()
+		if got.Backoff.BaseDelay != 250*time.Millisecond {
+			t.Errorf("BaseDelay = %v, want 250ms", got.Backoff.BaseDelay)
+		}
+		if got.Backoff.MaxDelay != 5*time.Second {
+			t.Errorf("MaxDelay = %v, want 5s", got.Backoff.MaxDelay)
+		}
+		if got.Backoff.Multiplier != 2.5 {
+			t.Errorf("Multiplier = %v, want 2.5", got.Backoff.Multiplier)
+		}
+		if got.Backoff.Jitter != backoff.DefaultConfig.Jitter {
+			t.Errorf("Jitter = %v, want the default %v", got.Backoff.Jitter, backoff.DefaultConfig.Jitter)
+		}
+	})
+
+	t.Run("invalid values fall back to defaults", func(t *testing.T) {
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_BASE_DELAY_MS", "soon")
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_MULTIPLIER", "-1")
+
+		got := connectParamsFromEnv()
+		if got.Backoff.BaseDelay != backoff.DefaultConfig.BaseDelay {
+			t.Errorf("BaseDelay = %v, want %v", got.Backoff.BaseDelay, backoff.DefaultConfig.BaseDelay)
+		}
+		if got.Backoff.Multiplier != backoff.DefaultConfig.Multiplier {
+			t.Errorf("Multiplier = %v, want %v", got.Backoff.Multiplier, backoff.DefaultConfig.Multiplier)
+		}
+	})
+
+	t.Run("max delay is raised to the base delay", func(t *testing.T) {
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_BASE_DELAY_MS", "3000")
+		t.Setenv("CHECKOUT_CLIENT_BACKOFF_MAX_DELAY_MS", "1000")
+
+		got := connectParamsFromEnv()
+		if got.Backoff.MaxDelay != 3*time.Second {
+			t.Errorf("MaxDelay = %v, want 3s", got.Backoff.MaxDelay)
+		}
+	})
+}
+
+func TestPlaceOrderMaxLineQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int32
+		wantCode codes.Code
+	}{
+		{"below limit", 4, codes.OK},
+		{"at limit", 5, codes.OK},
+		{"above limit", 6, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCheckout(t)
+			tc.svc.maxLineQuantity = 5
+			tc.cart.items = []*pb.CartItem{
+				{ProductId: "66VCHSJNUP", Quantity: 1},
+				{ProductId: "OLJCESPC7Z", Quantity: tt.quantity},
+			}
+
+			_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("PlaceOrder code = %v, want %v (err: %v)", got, tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK && len(tc.payment.charges) != 0 {
+				t.Errorf("card was charged for a rejected order")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maintenanceMode reports whether checkout is in maintenance mode, either
+// because CHECKOUT_MAINTENANCE_MODE was true at startup (enabled) or because
+// the checkoutMaintenanceMode flag is on. Only new orders are rejected while in
+// maintenance; health checks and the other RPCs keep being served.
+type maintenanceMode struct {
+	enabled bool
+	active  atomic.Bool
+}
+
+// check returns an Unavailable error if checkout is in maintenance mode,
+// logging whenever the mode changes.
+func (m *maintenanceMode) check(ctx context.Context, cs *checkoutService) error {
+	on := m.enabled
+	if !on {
+		on = cs.isFeatureFlagEnabled(ctx, "checkoutMaintenanceMode")
+	}
+
+	if was := m.active.Swap(on); was != on {
+		if on {
+			logger.WarnContext(ctx, "checkout entered maintenance mode, rejecting new orders")
+		} else {
+			logger.InfoContext(ctx, "checkout left maintenance mode, accepting orders")
+		}
+	}
+	if on {
+		return status.Error(codes.Unavailable, "checkout is temporarily unavailable for maintenance, please retry later")
+	}
+	return nil
+}
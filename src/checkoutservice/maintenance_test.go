@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestMaintenanceModeFlag(t *testing.T) {
+	p := &recordingProvider{bools: map[string]bool{"checkoutMaintenanceMode": true}}
+	useFlagProvider(t, p)
+	tc := newTestCheckout(t)
+	tc.currency.supported = []string{"USD"}
+
+	_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("PlaceOrder in maintenance mode = %v, want Unavailable", err)
+	}
+	if len(tc.payment.charges) != 0 {
+		t.Errorf("card charged %d times in maintenance mode", len(tc.payment.charges))
+	}
+
+	if _, err := tc.svc.GetSupportedCurrencies(context.Background(), &pb.Empty{}); err != nil {
+		t.Errorf("GetSupportedCurrencies in maintenance mode: %v", err)
+	}
+	if resp, err := tc.svc.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check in maintenance mode = %v, %v, want SERVING", resp, err)
+	}
+
+	p.bools["checkoutMaintenanceMode"] = false
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Errorf("PlaceOrder after leaving maintenance mode: %v", err)
+	}
+}
+
+func TestMaintenanceModeEnabled(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.maintenance.enabled = true
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); status.Code(err) != codes.Unavailable {
+		t.Errorf("PlaceOrder with maintenance mode enabled = %v, want Unavailable", err)
+	}
+}
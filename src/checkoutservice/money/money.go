@@ -4,6 +4,10 @@ package money
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
 )
@@ -19,11 +23,29 @@ var (
 	ErrMismatchingCurrency = errors.New("mismatching currency codes")
 )
 
-// IsValid checks if specified value has a valid units/nanos signs and ranges.
+// IsValid checks if specified value has a valid units/nanos signs and ranges,
+// and a currency code that is either unspecified or valid.
 func IsValid(m *pb.Money) bool {
-	return signMatches(m) && validNanos(m.GetNanos())
+	return signMatches(m) && validNanos(m.GetNanos()) && validOrEmptyCurrencyCode(m.GetCurrencyCode())
 }
 
+// IsValidCurrencyCode reports whether code is shaped like an ISO 4217 code:
+// three uppercase ASCII letters, such as "USD". It does not check that the
+// currency exists, so "usd" and "US" are invalid but "XYZ" is valid.
+func IsValidCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func validOrEmptyCurrencyCode(code string) bool { return code == "" || IsValidCurrencyCode(code) }
+
 func signMatches(m *pb.Money) bool {
 	return m.GetNanos() == 0 || m.GetUnits() == 0 || (m.GetNanos() < 0) == (m.GetUnits() < 0)
 }
@@ -108,6 +130,35 @@ func Sum(l, r *pb.Money) (*pb.Money, error) {
 		CurrencyCode: l.GetCurrencyCode()}, nil
 }
 
+// SumAll adds amounts, which must all be valid values in currency, itself a
+// valid or unspecified currency code. Unlike
+// chaining Sum with Must, it does not panic: every invalid or mismatching
+// amount is reported in the returned error, which wraps ErrInvalidValue or
+// ErrMismatchingCurrency accordingly. The sum of no amounts is zero.
+func SumAll(currency string, amounts ...*pb.Money) (*pb.Money, error) {
+	if !validOrEmptyCurrencyCode(currency) {
+		return &pb.Money{}, fmt.Errorf("currency %q: %w", currency, ErrInvalidValue)
+	}
+	var errs []error
+	for i, m := range amounts {
+		switch {
+		case !IsValid(m):
+			errs = append(errs, fmt.Errorf("amount %d: %w", i, ErrInvalidValue))
+		case m.GetCurrencyCode() != currency:
+			errs = append(errs, fmt.Errorf("amount %d is in %q, want %q: %w", i, m.GetCurrencyCode(), currency, ErrMismatchingCurrency))
+		}
+	}
+	if len(errs) > 0 {
+		return &pb.Money{}, errors.Join(errs...)
+	}
+
+	total := &pb.Money{CurrencyCode: currency}
+	for _, m := range amounts {
+		total = Must(Sum(total, m))
+	}
+	return total, nil
+}
+
 // MultiplySlow is a slow multiplication operation done through adding the value
 // to itself n-1 times.
 func MultiplySlow(m *pb.Money, n uint32) *pb.Money {
@@ -118,3 +169,132 @@ func MultiplySlow(m *pb.Money, n uint32) *pb.Money {
 	}
 	return out
 }
+
+// Format renders m for people as its currency code and its amount rounded to
+// the cent, with halves rounded away from zero, such as "USD 562.86" or
+// "EUR -0.50".
+func Format(m *pb.Money) string {
+	sign, cents := roundCents(m)
+	return withCurrencyCode(m, fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100))
+}
+
+// localeSeparators are the decimal and digit grouping separators of the
+// locales FormatLocale knows, by BCP 47 tag or language.
+var localeSeparators = map[string][2]string{
+	"en": {".", ","}, "ja": {".", ","}, "ko": {".", ","}, "zh": {".", ","},
+	"da": {",", "."}, "de": {",", "."}, "es": {",", "."}, "id": {",", "."},
+	"it": {",", "."}, "nl": {",", "."}, "pt": {",", "."}, "tr": {",", "."},
+	"cs": {",", "\u00a0"}, "fi": {",", "\u00a0"}, "fr": {",", "\u202f"}, "nb": {",", "\u00a0"},
+	"pl": {",", "\u00a0"}, "ru": {",", "\u00a0"}, "sv": {",", "\u00a0"}, "uk": {",", "\u00a0"},
+	"de-ch": {".", "\u2019"},
+}
+
+// FormatLocale renders m like Format, with the decimal and digit grouping
+// separators of locale, such as "EUR 1.234,50" for "de-DE". It falls back to
+// Format for an empty or unknown locale.
+func FormatLocale(m *pb.Money, locale string) string {
+	locale = strings.ToLower(locale)
+	separators, ok := localeSeparators[locale]
+	if !ok {
+		language, _, _ := strings.Cut(locale, "-")
+		if separators, ok = localeSeparators[language]; !ok {
+			return Format(m)
+		}
+	}
+
+	sign, cents := roundCents(m)
+	whole := strconv.FormatInt(cents/100, 10)
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(separators[1])
+		}
+		grouped.WriteRune(digit)
+	}
+	return withCurrencyCode(m, fmt.Sprintf("%s%s%s%02d", sign, grouped.String(), separators[0], cents%100))
+}
+
+// roundCents returns the sign of m and its absolute amount in cents, with
+// halves rounded away from zero.
+func roundCents(m *pb.Money) (string, int64) {
+	units, nanos := m.GetUnits(), int64(m.GetNanos())
+	sign := ""
+	if units < 0 || nanos < 0 {
+		sign, units, nanos = "-", -units, -nanos
+	}
+	return sign, units*100 + (nanos+nanosMod/200)/(nanosMod/100)
+}
+
+func withCurrencyCode(m *pb.Money, amount string) string {
+	if m.GetCurrencyCode() == "" {
+		return amount
+	}
+	return m.GetCurrencyCode() + " " + amount
+}
+
+// Convert applies an exchange rate to m, returning the amount in currency
+// rounded to the nano.
+func Convert(m *pb.Money, rate float64, currency string) (*pb.Money, error) {
+	if !IsValid(m) || !IsValidCurrencyCode(currency) || rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return &pb.Money{}, ErrInvalidValue
+	}
+	units := float64(m.GetUnits()) * rate
+	whole, frac := math.Modf(units)
+	if math.Abs(whole) >= math.MaxInt64 {
+		return &pb.Money{}, ErrInvalidValue
+	}
+	nanos := int64(math.Round(frac*nanosMod + float64(m.GetNanos())*rate))
+	u, n := int64(whole)+nanos/nanosMod, nanos%nanosMod
+	// units and nanos must have the same sign
+	if u > 0 && n < 0 {
+		u, n = u-1, n+nanosMod
+	} else if u < 0 && n > 0 {
+		u, n = u+1, n-nanosMod
+	}
+	return &pb.Money{Units: u, Nanos: int32(n), CurrencyCode: currency}, nil
+}
+
+// minorUnitDigits are the ISO 4217 exponents of the currencies without two
+// decimal places.
+var minorUnitDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnitDigits returns the number of decimal places of currency: 0 for
+// JPY, 3 for KWD and 2 for most others.
+func MinorUnitDigits(currency string) int {
+	if d, ok := minorUnitDigits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// ToMinorUnits returns m as an integer number of the minor units of currency,
+// such as cents for USD or yen for JPY, rounding halves away from zero. It
+// fails if m is invalid, is in another currency, or does not fit.
+func ToMinorUnits(m *pb.Money, currency string) (int64, error) {
+	if !IsValid(m) || !IsValidCurrencyCode(currency) {
+		return 0, ErrInvalidValue
+	}
+	if m.GetCurrencyCode() != "" && m.GetCurrencyCode() != currency {
+		return 0, ErrMismatchingCurrency
+	}
+
+	scale := int64(1)
+	for range MinorUnitDigits(currency) {
+		scale *= 10
+	}
+	units, nanos := m.GetUnits(), int64(m.GetNanos())
+	if units > math.MaxInt64/scale-1 || units < math.MinInt64/scale+1 {
+		return 0, ErrInvalidValue
+	}
+	step := nanosMod / scale
+	half := step / 2
+	if nanos < 0 {
+		half = -half
+	}
+	return units*scale + (nanos+half)/step, nil
+}
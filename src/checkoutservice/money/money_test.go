@@ -3,7 +3,9 @@
 package money
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -231,3 +233,179 @@ func TestSum(t *testing.T) {
 		})
 	}
 }
+
+func TestSumAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		in       []*pb.Money
+		want     *pb.Money
+		wantErrs []error
+	}{
+		{"no amounts", "USD", nil, mmc(0, 0, "USD"), nil},
+		{"single amount", "USD", []*pb.Money{mmc(3, 500000000, "USD")}, mmc(3, 500000000, "USD"), nil},
+		{"carry across amounts", "USD", []*pb.Money{mmc(1, 600000000, "USD"), mmc(2, 600000000, "USD"), mmc(0, 900000000, "USD")}, mmc(5, 100000000, "USD"), nil},
+		{"mixed signs", "EUR", []*pb.Money{mmc(10, 0, "EUR"), mmc(-2, -500000000, "EUR")}, mmc(7, 500000000, "EUR"), nil},
+		{"Error: mismatching currency", "USD", []*pb.Money{mmc(1, 0, "USD"), mmc(1, 0, "EUR")}, mm(0, 0), []error{ErrMismatchingCurrency}},
+		{"Error: invalid value", "USD", []*pb.Money{mmc(1, -1, "USD")}, mm(0, 0), []error{ErrInvalidValue}},
+		{"Error: every problem reported", "USD", []*pb.Money{mmc(1, 0, "EUR"), mmc(1, 0, "USD"), mmc(0, 1000000000, "USD")}, mm(0, 0), []error{ErrMismatchingCurrency, ErrInvalidValue}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SumAll(tt.currency, tt.in...)
+			if tt.wantErrs == nil && err != nil {
+				t.Errorf("SumAll(%q, %v): unexpected err=\"%v\"", tt.currency, tt.in, err)
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("SumAll(%q, %v): expected err to wrap \"%v\" got=\"%v\"", tt.currency, tt.in, want, err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SumAll(%q, %v) = %v, want %v", tt.currency, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   *pb.Money
+		want string
+	}{
+		{mmc(562, 860000000, "USD"), "USD 562.86"},
+		{mmc(8, 990000000, "USD"), "USD 8.99"},
+		{mmc(175, 0, "USD"), "USD 175.00"},
+		{mmc(0, 0, "EUR"), "EUR 0.00"},
+		{mmc(0, -500000000, "EUR"), "EUR -0.50"},
+		{mmc(-3, -5000000, "EUR"), "EUR -3.01"},
+		{mmc(90, 123456789, "JPY"), "JPY 90.12"},
+		{mmc(1, 995000000, "CAD"), "CAD 2.00"},
+		{mmc(1, 994999999, "CAD"), "CAD 1.99"},
+		{mm(4, 100000000), "4.10"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("Format(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		in   *pb.Money
+		rate float64
+		want *pb.Money
+	}{
+		{mmc(100, 0, "USD"), 0.9, mmc(90, 0, "EUR")},
+		{mmc(101, 960000000, "USD"), 0.5, mmc(50, 980000000, "EUR")},
+		{mmc(8, 990000000, "USD"), 1.5, mmc(13, 485000000, "EUR")},
+		{mmc(1, 0, "USD"), 1.0 / 3, mmc(0, 333333333, "EUR")},
+		{mmc(-2, -500000000, "USD"), 2, mmc(-5, 0, "EUR")},
+		{mmc(0, 0, "USD"), 150, mmc(0, 0, "EUR")},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.in, tt.rate, "EUR")
+		if err != nil {
+			t.Errorf("Convert(%v, %v) error = %v", tt.in, tt.rate, err)
+			continue
+		}
+		if !AreEquals(got, tt.want) {
+			t.Errorf("Convert(%v, %v) = %v, want %v", tt.in, tt.rate, got, tt.want)
+		}
+	}
+
+	for _, rate := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := Convert(mmc(1, 0, "USD"), rate, "EUR"); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Convert() at rate %v error = %v, want ErrInvalidValue", rate, err)
+		}
+	}
+}
+
+func TestToMinorUnits(t *testing.T) {
+	tests := []struct {
+		in       *pb.Money
+		currency string
+		want     int64
+	}{
+		{mmc(562, 860000000, "USD"), "USD", 56286},
+		{mmc(0, 5000000, "USD"), "USD", 1},
+		{mmc(0, 4999999, "USD"), "USD", 0},
+		{mmc(-1, -995000000, "USD"), "USD", -200},
+		{mm(12, 340000000), "USD", 1234},
+		{mmc(1500, 0, "JPY"), "JPY", 1500},
+		{mmc(1500, 500000000, "JPY"), "JPY", 1501},
+		{mmc(1500, 499999999, "JPY"), "JPY", 1500},
+		{mmc(3, 141500000, "KWD"), "KWD", 3142},
+		{mmc(3, 141400000, "KWD"), "KWD", 3141},
+	}
+	for _, tt := range tests {
+		got, err := ToMinorUnits(tt.in, tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("ToMinorUnits(%v, %s) = %d, %v, want %d", tt.in, tt.currency, got, err, tt.want)
+		}
+	}
+
+	if _, err := ToMinorUnits(mmc(1, 0, "EUR"), "USD"); !errors.Is(err, ErrMismatchingCurrency) {
+		t.Errorf("ToMinorUnits() of EUR in USD error = %v, want ErrMismatchingCurrency", err)
+	}
+	if _, err := ToMinorUnits(mmc(1, -1, "USD"), "USD"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ToMinorUnits() of an invalid value error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := ToMinorUnits(mmc(math.MaxInt64/100, 0, "USD"), "USD"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ToMinorUnits() of an amount too large for cents error = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestIsValidCurrencyCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"USD": true, "JPY": true, "XYZ": true,
+		"usd": false, "Usd": false, "US": false, "USDT": false, "": false, "U$D": false, "ÜSD": false,
+	} {
+		if got := IsValidCurrencyCode(code); got != want {
+			t.Errorf("IsValidCurrencyCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestInvalidCurrencyCodes(t *testing.T) {
+	if IsValid(mmc(1, 0, "usd")) {
+		t.Error("IsValid() accepted a lowercase currency code")
+	}
+	if _, err := Sum(mmc(1, 0, "usd"), mmc(1, 0, "usd")); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Sum() in \"usd\" error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := Sum(mmc(1, 0, "US"), mmc(1, 0, "USD")); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Sum() of \"US\" and \"USD\" error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := SumAll("usd", mmc(1, 0, "usd")); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("SumAll() in \"usd\" error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := Convert(mmc(1, 0, "USD"), 0.9, "eur"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Convert() to \"eur\" error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := ToMinorUnits(mm(1, 0), "US"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ToMinorUnits() in \"US\" error = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestFormatLocale(t *testing.T) {
+	tests := []struct {
+		in     *pb.Money
+		locale string
+		want   string
+	}{
+		{mmc(1234, 500000000, "EUR"), "de-DE", "EUR 1.234,50"},
+		{mmc(1234567, 0, "USD"), "en-US", "USD 1,234,567.00"},
+		{mmc(-1234, -5000000, "EUR"), "fr", "EUR -1\u202f234,01"},
+		{mmc(12345, 0, "CHF"), "de-CH", "CHF 12\u2019345.00"},
+		{mmc(999, 990000000, "USD"), "en", "USD 999.99"},
+		{mmc(1234, 500000000, "USD"), "", "USD 1234.50"},
+		{mmc(1234, 500000000, "USD"), "xx-YY", "USD 1234.50"},
+	}
+	for _, tt := range tests {
+		if got := FormatLocale(tt.in, tt.locale); got != tt.want {
+			t.Errorf("FormatLocale(%v, %q) = %q, want %q", tt.in, tt.locale, got, tt.want)
+		}
+	}
+}
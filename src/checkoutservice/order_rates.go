@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultOrderRateMaxUsers bounds how many users orderRates tracks when
+// CHECKOUT_ORDER_RATE_MAX_USERS is not set.
+const defaultOrderRateMaxUsers = 10000
+
+// orderRates counts the orders each user placed within a sliding window, as a
+// fraud signal. Orders older than the window no longer count, and at most
+// maxUsers users are tracked at a time, the least recently active user making
+// room for a new one. A nil *orderRates tracks nothing.
+type orderRates struct {
+	window   time.Duration
+	maxUsers int
+	now      func() time.Time
+
+	mu     sync.Mutex
+	orders map[string][]time.Time // placement times by user, oldest first
+}
+
+// newOrderRates returns a tracker over window, or nil when window is not
+// positive.
+func newOrderRates(window time.Duration, maxUsers int) *orderRates {
+	if window <= 0 {
+		return nil
+	}
+	return &orderRates{
+		window:   window,
+		maxUsers: maxUsers,
+		now:      time.Now,
+		orders:   make(map[string][]time.Time),
+	}
+}
+
+// record counts an order placed by userID and records the number of orders the
+// user placed within the window, this one included, in checkout.orders_per_user.
+func (r *orderRates) record(ctx context.Context, userID string) {
+	if r == nil {
+		return
+	}
+	ordersPerUser.Record(ctx, int64(r.add(userID)))
+}
+
+// add counts an order placed by userID and returns the number of orders the
+// user placed within the window.
+func (r *orderRates) add(userID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	times := r.recent(userID, now)
+	if len(times) == 0 && len(r.orders) >= r.maxUsers {
+		r.evict(now)
+	}
+	times = append(times, now)
+	r.orders[userID] = times
+	return len(times)
+}
+
+// count returns the number of orders userID placed within the window.
+func (r *orderRates) count(userID string) int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.recent(userID, r.now()))
+}
+
+// recent drops the orders of userID that fell out of the window and returns
+// the rest. r.mu must be held.
+func (r *orderRates) recent(userID string, now time.Time) []time.Time {
+	times := r.orders[userID]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= r.window {
+		i++
+	}
+	if i == len(times) {
+		delete(r.orders, userID)
+		return nil
+	}
+	times = times[i:]
+	r.orders[userID] = times
+	return times
+}
+
+// evict makes room for a new user, dropping every user without an order in
+// the window, or the least recently active user if all of them have one.
+// r.mu must be held.
+func (r *orderRates) evict(now time.Time) {
+	var oldest string
+	var oldestAt time.Time
+	found := false
+	for userID, times := range r.orders {
+		last := times[len(times)-1]
+		if now.Sub(last) >= r.window {
+			delete(r.orders, userID)
+			continue
+		}
+		if !found || last.Before(oldestAt) {
+			oldest, oldestAt, found = userID, last, true
+		}
+	}
+	if len(r.orders) >= r.maxUsers {
+		delete(r.orders, oldest)
+	}
+}
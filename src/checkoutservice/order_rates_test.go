@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOrderRatesWindow(t *testing.T) {
+	now := time.Now()
+	r := newOrderRates(time.Minute, 2)
+	r.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if got := r.add("user-1"); got != i+1 {
+			t.Errorf("add() = %d, want %d", got, i+1)
+		}
+		now = now.Add(20 * time.Second)
+	}
+	// the first order is now a minute old and no longer counts
+	if got := r.count("user-1"); got != 2 {
+		t.Errorf("count() after the first order left the window = %d, want 2", got)
+	}
+	now = now.Add(time.Minute)
+	if got := r.count("user-1"); got != 0 {
+		t.Errorf("count() once every order left the window = %d, want 0", got)
+	}
+	if len(r.orders) != 0 {
+		t.Errorf("tracked users = %v, want none once their orders decayed", r.orders)
+	}
+
+	// a full tracker makes room by dropping the least recently active user
+	r.add("user-1")
+	now = now.Add(time.Second)
+	r.add("user-2")
+	now = now.Add(time.Second)
+	r.add("user-1")
+	r.add("user-3")
+	if got := r.count("user-2"); got != 0 {
+		t.Errorf("count() of the evicted user = %d, want 0", got)
+	}
+	if r.count("user-1") != 2 || r.count("user-3") != 1 || len(r.orders) != 2 {
+		t.Errorf("tracked orders = %v, want user-1 twice and user-3 once", r.orders)
+	}
+
+	if newOrderRates(0, 2) != nil {
+		t.Error("newOrderRates() without a window tracks orders")
+	}
+}
+
+func TestPlaceOrderRecordsOrdersPerUser(t *testing.T) {
+	before := ordersPerUserSum(t)
+	tc := newTestCheckout(t)
+	tc.svc.orderRates = newOrderRates(time.Minute, 10)
+
+	for range 2 {
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+	}
+	// the first order counts one order for the user, the second two
+	if got := ordersPerUserSum(t) - before; got != 3 {
+		t.Errorf("checkout.orders_per_user sum grew by %d, want 3", got)
+	}
+}
+
+func ordersPerUserSum(t *testing.T) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := metricReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var sum int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "checkout.orders_per_user" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[int64]).DataPoints {
+				sum += dp.Sum
+			}
+		}
+	}
+	return sum
+}
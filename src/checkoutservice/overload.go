@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Bounds of the kafkaQueueProblems overload simulation when
+// CHECKOUT_KAFKA_OVERLOAD_MAX and CHECKOUT_KAFKA_OVERLOAD_TIMEOUT_MS are not
+// set.
+const (
+	defaultOverloadMaxMessages = 1000
+	defaultOverloadTimeout     = 10 * time.Second
+)
+
+// overloadReport is the outcome of an overload simulation.
+type overloadReport struct {
+	requested int
+	sent      int
+	succeeded int
+	failed    int
+}
+
+// simulateQueueOverload publishes n copies of msg to overload the queue, as
+// requested by the kafkaQueueProblems flag. It sends at most
+// overloadMaxMessages copies and gives up after overloadTimeout. The copies
+// go through kafkaAcks, which hands their outcome back to the simulation, so
+// it never takes the acknowledgement of an order; it does not run when the
+// order event batcher reads the acknowledgements instead.
+func (cs *checkoutService) simulateQueueOverload(ctx context.Context, msg *sarama.ProducerMessage, n int) overloadReport {
+	r := overloadReport{requested: n}
+	if cs.kafkaAcks == nil {
+		logger.WarnContext(ctx, "Skipping overload simulation, order events are batched")
+		return r
+	}
+	limit, timeout := cs.overloadMaxMessages, cs.overloadTimeout
+	if limit <= 0 {
+		limit = defaultOverloadMaxMessages
+	}
+	if timeout <= 0 {
+		timeout = defaultOverloadTimeout
+	}
+	n = min(n, limit)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// buffered for every copy, so the reader never blocks on a simulation
+	// that gave up
+	results := make(chan error, n)
+	pending := 0
+loop:
+	for r.sent < n || pending > 0 {
+		var input chan<- *sarama.ProducerMessage
+		var next *sarama.ProducerMessage
+		if r.sent < n {
+			input = cs.kafkaAcks.producer.Input()
+			next = &sarama.ProducerMessage{Topic: msg.Topic, Value: msg.Value, Headers: msg.Headers, Metadata: &pendingAck{
+				span:   trace.SpanFromContext(context.Background()),
+				start:  time.Now(),
+				result: results,
+			}}
+		}
+		select {
+		case input <- next:
+			r.sent++
+			pending++
+		case err := <-results:
+			if err == nil {
+				r.succeeded++
+			} else {
+				r.failed++
+			}
+			pending--
+		case <-timer.C:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	logger.InfoContext(ctx, "Done with overload simulation",
+		"requested", r.requested, "sent", r.sent, "succeeded", r.succeeded, "failed", r.failed, "unacknowledged", pending)
+	return r
+}
@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+)
+
+func TestSimulateQueueOverloadIsBounded(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(errors.New("queue full"))
+	producer.ExpectInputAndSucceed()
+
+	cs := &checkoutService{overloadMaxMessages: 3}
+	useKafkaProducer(cs, producer)
+	r := cs.simulateQueueOverload(context.Background(), &sarama.ProducerMessage{Topic: "orders"}, 10)
+	if r.requested != 10 || r.sent != 3 {
+		t.Errorf("sent %d of %d requested messages, want 3 of 10", r.sent, r.requested)
+	}
+	if r.succeeded != 2 || r.failed != 1 {
+		t.Errorf("%d succeeded and %d failed, want 2 and 1", r.succeeded, r.failed)
+	}
+	if flush, err := cs.kafkaAcks.close(context.Background()); flush != kafkaFlushClean || err != nil {
+		t.Errorf("close() = %q, %v, want clean", flush, err)
+	}
+}
+
+func TestSimulateQueueOverloadLeavesOrderAcks(t *testing.T) {
+	producer := newHeldProducer()
+	dlq := kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	cs := &checkoutService{deadLetters: dlq}
+	useKafkaProducer(cs, producer)
+
+	simulated := make(chan overloadReport, 1)
+	go func() {
+		simulated <- cs.simulateQueueOverload(context.Background(), &sarama.ProducerMessage{Topic: kafka.Topic}, 1)
+	}()
+	overload := <-producer.input
+
+	published := make(chan bool, 1)
+	go func() {
+		published <- cs.kafkaAcks.publish(context.Background(), &sarama.ProducerMessage{Topic: kafka.Topic, Value: sarama.StringEncoder("order-1")})
+	}()
+	order := <-producer.input
+
+	// the order is acknowledged first, while the simulation is still waiting
+	producer.successes <- order
+	if !<-published {
+		t.Error("publish() = false, want the order handed to the producer")
+	}
+	producer.errors <- &sarama.ProducerError{Msg: overload, Err: errors.New("queue full")}
+	if r := <-simulated; r.succeeded != 0 || r.failed != 1 {
+		t.Errorf("simulation saw %d successes and %d failures, want only its own failure", r.succeeded, r.failed)
+	}
+	if n, err := dlq.Len(); err != nil || n != 0 {
+		t.Errorf("dead letters = %d, %v, want the acknowledged order and the overload copy kept out", n, err)
+	}
+}
+
+// stuckProducer never accepts nor acknowledges messages.
+type stuckProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func (p *stuckProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *stuckProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *stuckProducer) Errors() <-chan *sarama.ProducerError      { return p.errors }
+
+func TestSimulateQueueOverloadTimesOut(t *testing.T) {
+	producer := &stuckProducer{input: make(chan *sarama.ProducerMessage)}
+	cs := &checkoutService{
+		KafkaProducerClient: producer,
+		kafkaAcks:           newKafkaAcks(producer, nil),
+		overloadTimeout:     50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	r := cs.simulateQueueOverload(context.Background(), &sarama.ProducerMessage{Topic: "orders"}, 5)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("simulation took %v, want it bounded by its timeout", elapsed)
+	}
+	if r.sent != 0 {
+		t.Errorf("sent %d messages to a stuck producer", r.sent)
+	}
+}
+
+func TestSimulateQueueOverloadSkipsBatching(t *testing.T) {
+	producer := newHeldProducer()
+	cs := &checkoutService{KafkaProducerClient: producer, orderEvents: newOrderEventBatcher(producer, 1, time.Hour, 1, nil)}
+	if r := cs.simulateQueueOverload(context.Background(), &sarama.ProducerMessage{Topic: "orders"}, 5); r.sent != 0 {
+		t.Errorf("sent %d overload messages while the batcher reads the acknowledgements", r.sent)
+	}
+	if len(producer.input) != 0 {
+		t.Errorf("overload message handed to the producer")
+	}
+}
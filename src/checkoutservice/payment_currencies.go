@@ -0,0 +1,60 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// paymentCurrencies are the currencies the payment backend accepts. A nil
+// paymentCurrencies accepts every currency.
+type paymentCurrencies map[string]bool
+
+// paymentCurrenciesFromEnv reads the comma-separated currency codes in
+// CHECKOUT_ALLOWED_PAYMENT_CURRENCIES, such as "USD,EUR". Orders in any
+// currency are accepted when it is not set.
+func paymentCurrenciesFromEnv() (paymentCurrencies, error) {
+	list := os.Getenv("CHECKOUT_ALLOWED_PAYMENT_CURRENCIES")
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	allowed := make(paymentCurrencies)
+	for _, code := range strings.Split(list, ",") {
+		code = strings.TrimSpace(code)
+		if !money.IsValidCurrencyCode(code) {
+			return nil, fmt.Errorf("invalid currency code %q in CHECKOUT_ALLOWED_PAYMENT_CURRENCIES", code)
+		}
+		allowed[code] = true
+	}
+	return allowed, nil
+}
+
+// check returns a FailedPrecondition status when currency is not one the
+// payment backend accepts, and adds an event to the span in ctx recording the
+// restriction. Unlike a currency the currency service cannot convert to, the
+// order is well-formed; it just cannot be paid for in that currency.
+func (c paymentCurrencies) check(ctx context.Context, currency string) error {
+	if c == nil || c[currency] {
+		return nil
+	}
+	allowed := make([]string, 0, len(c))
+	for code := range c {
+		allowed = append(allowed, code)
+	}
+	slices.Sort(allowed)
+	trace.SpanFromContext(ctx).AddEvent("payment currency not allowed", trace.WithAttributes(
+		attribute.String("app.payment.currency", currency),
+		attribute.StringSlice("app.payment.allowed_currencies", allowed),
+	))
+	return status.Errorf(codes.FailedPrecondition, "payments in %s are not accepted, use one of %s", currency, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPaymentCurrenciesFromEnv(t *testing.T) {
+	if allowed, err := paymentCurrenciesFromEnv(); err != nil || allowed != nil {
+		t.Errorf("paymentCurrenciesFromEnv() = %v, %v by default, want every currency allowed", allowed, err)
+	}
+	t.Setenv("CHECKOUT_ALLOWED_PAYMENT_CURRENCIES", "USD, EUR")
+	allowed, err := paymentCurrenciesFromEnv()
+	if err != nil || len(allowed) != 2 || !allowed["USD"] || !allowed["EUR"] {
+		t.Errorf("paymentCurrenciesFromEnv() = %v, %v, want USD and EUR", allowed, err)
+	}
+	t.Setenv("CHECKOUT_ALLOWED_PAYMENT_CURRENCIES", "USD,usd")
+	if _, err := paymentCurrenciesFromEnv(); err == nil {
+		t.Error("paymentCurrenciesFromEnv() accepted an invalid currency code")
+	}
+}
+
+func TestPlaceOrderAllowedPaymentCurrency(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.paymentCurrencies = paymentCurrencies{"USD": true, "EUR": true}
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() in an allowed currency: %v", err)
+	}
+	if len(tc.payment.charges) != 1 {
+		t.Errorf("card charged %d times, want once", len(tc.payment.charges))
+	}
+}
+
+func TestPlaceOrderDisallowedPaymentCurrency(t *testing.T) {
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	tc.svc.paymentCurrencies = paymentCurrencies{"USD": true, "EUR": true}
+
+	req := testPlaceOrderRequest()
+	req.UserCurrency = "JPY"
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	if _, err := tc.svc.PlaceOrder(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("PlaceOrder() in JPY = %v, want FailedPrecondition", err)
+	}
+	if len(tc.payment.charges) != 0 {
+		t.Errorf("card charged %d times in a disallowed currency", len(tc.payment.charges))
+	}
+
+	i := slices.IndexFunc(recorder.Ended(), func(s sdktrace.ReadOnlySpan) bool { return s.Name() == "PlaceOrder" })
+	if i < 0 {
+		t.Fatal("PlaceOrder span not recorded")
+	}
+	events := recorder.Ended()[i].Events()
+	j := slices.IndexFunc(events, func(e sdktrace.Event) bool { return e.Name == "payment currency not allowed" })
+	if j < 0 {
+		t.Fatal("no span event recording the currency restriction")
+	}
+	for _, kv := range events[j].Attributes {
+		if kv.Key == "app.payment.currency" && kv.Value.AsString() != "JPY" {
+			t.Errorf("app.payment.currency = %q, want JPY", kv.Value.AsString())
+		}
+	}
+}
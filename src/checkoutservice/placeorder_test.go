@@ -0,0 +1,281 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama/mocks"
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPlaceOrder(t *testing.T) {
+	tc := newTestCheckout(t)
+
+	resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	order := resp.GetOrder()
+	if order.GetOrderId() == "" || order.GetShippingTrackingId() != "TRACK-1" {
+		t.Errorf("order = %v, want an ID and the shipping tracking ID", order)
+	}
+	if len(order.GetItems()) != 2 {
+		t.Errorf("order has %d items, want 2", len(order.GetItems()))
+	}
+	if got := order.GetShippingCost(); got.GetUnits() != 8 || got.GetNanos() != 990000000 {
+		t.Errorf("shipping cost = %v, want 8.99", got)
+	}
+
+	// 2 x 101.96 + 349.95 + 8.99 shipping
+	if len(tc.payment.charges) != 1 {
+		t.Fatalf("card charged %d times, want 1", len(tc.payment.charges))
+	}
+	if got := tc.payment.charges[0].GetAmount(); got.GetCurrencyCode() != "USD" || got.GetUnits() != 562 || got.GetNanos() != 860000000 {
+		t.Errorf("charged %v, want USD 562.86", got)
+	}
+	if tc.cart.emptied != 1 {
+		t.Errorf("cart emptied %d times, want 1", tc.cart.emptied)
+	}
+	if got := tc.email.count(); got != 1 {
+		t.Errorf("order confirmations sent = %d, want 1", got)
+	}
+}
+
+func TestPlaceOrderChargesMinorUnits(t *testing.T) {
+	tc := newTestCheckout(t)
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if got := tc.payment.charges[0].GetAmountMinorUnits(); got != 0 {
+		t.Errorf("charged %d minor units by default, want the field unset", got)
+	}
+
+	tc = newTestCheckout(t)
+	tc.svc.chargeMinorUnits = true
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	charge := tc.payment.charges[0]
+	amount := charge.GetAmount()
+	if want := amount.GetUnits()*100 + int64(amount.GetNanos()+5000000)/10000000; charge.GetAmountMinorUnits() != want || want == 0 {
+		t.Errorf("charged %d minor units for %v, want %d", charge.GetAmountMinorUnits(), amount, want)
+	}
+}
+
+func TestPlaceOrderActiveOrders(t *testing.T) {
+	before := counterValue(t, "checkout.active_orders", "", "")
+	tc := newTestCheckout(t)
+	tc.payment.delay = 200 * time.Millisecond
+
+	const orders = 3
+	errs := make(chan error, orders)
+	for range orders {
+		go func() {
+			_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			errs <- err
+		}()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for counterValue(t, "checkout.active_orders", "", "") != before+orders {
+		if time.Now().After(deadline) {
+			t.Fatalf("checkout.active_orders never reached %d", before+orders)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for range orders {
+		if err := <-errs; err != nil {
+			t.Errorf("PlaceOrder() error = %v", err)
+		}
+	}
+	if got := counterValue(t, "checkout.active_orders", "", ""); got != before {
+		t.Errorf("checkout.active_orders = %d once the orders finished, want %d", got, before)
+	}
+
+	// failed orders leave too
+	tc.cart.err = status.Error(codes.Unavailable, "connection refused")
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+		t.Fatal("PlaceOrder() succeeded during a cart outage")
+	}
+	if got := counterValue(t, "checkout.active_orders", "", ""); got != before {
+		t.Errorf("checkout.active_orders = %d after a failed order, want %d", got, before)
+	}
+}
+
+func TestPlaceOrderRejectsInvalidCurrencyCode(t *testing.T) {
+	for _, currency := range []string{"usd", "US", ""} {
+		tc := newTestCheckout(t)
+		req := testPlaceOrderRequest()
+		req.UserCurrency = currency
+		if _, err := tc.svc.PlaceOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("PlaceOrder() in %q error = %v, want InvalidArgument", currency, err)
+		}
+		if len(tc.payment.charges) != 0 {
+			t.Errorf("PlaceOrder() in %q charged the card", currency)
+		}
+	}
+}
+
+func TestPlaceOrderFailures(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	tests := []struct {
+		name    string
+		setup   func(tc *testCheckout)
+		want    codes.Code
+		charged bool
+	}{
+		{"cart service fails", func(tc *testCheckout) { tc.cart.err = unavailable }, codes.Internal, false},
+		{"product is not in the catalog", func(tc *testCheckout) { delete(tc.catalog.products, "66VCHSJNUP") }, codes.NotFound, false},
+		{"catalog service fails", func(tc *testCheckout) { tc.catalog.err = unavailable }, codes.Unavailable, false},
+		{"currency service fails", func(tc *testCheckout) { tc.currency.err = unavailable }, codes.Internal, false},
+		{"shipping quote fails", func(tc *testCheckout) { tc.shipping.quoteErr = unavailable }, codes.Internal, false},
+		{"card is declined", func(tc *testCheckout) { tc.payment.err = status.Error(codes.InvalidArgument, "card declined") }, codes.Internal, true},
+		{"shipment fails", func(tc *testCheckout) { tc.shipping.shipErr = unavailable }, codes.Unavailable, true},
+		{"line quantity over the limit", func(tc *testCheckout) { tc.svc.maxLineQuantity = 1 }, codes.InvalidArgument, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCheckout(t)
+			tt.setup(tc)
+
+			_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("PlaceOrder() error = %v, want %v", err, tt.want)
+			}
+			if charged := len(tc.payment.charges) > 0; charged != tt.charged {
+				t.Errorf("card charged = %v, want %v", charged, tt.charged)
+			}
+			if tc.cart.emptied != 0 {
+				t.Errorf("cart emptied after a failed order")
+			}
+			if got := tc.email.count(); got != 0 {
+				t.Errorf("order confirmations sent = %d after a failed order", got)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderCartOutage(t *testing.T) {
+	for _, tt := range []struct {
+		emptyCartOnError bool
+		want             codes.Code
+	}{
+		{false, codes.Internal},
+		{true, codes.FailedPrecondition},
+	} {
+		recorder := recordSpans(t)
+		tc := newTestCheckout(t)
+		tc.cart.err = status.Error(codes.Unavailable, "connection refused")
+		tc.svc.emptyCartOnError = tt.emptyCartOnError
+
+		_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("with emptyCartOnError=%v, PlaceOrder() error = %v, want %v", tt.emptyCartOnError, err, tt.want)
+		}
+		if got := hasSpanEvent(recorder, "cart unavailable, treated as empty"); got != tt.emptyCartOnError {
+			t.Errorf("with emptyCartOnError=%v, cart unavailable event = %v", tt.emptyCartOnError, got)
+		}
+		if len(tc.payment.charges) != 0 {
+			t.Errorf("with emptyCartOnError=%v, card charged during a cart outage", tt.emptyCartOnError)
+		}
+	}
+}
+
+func TestPlaceOrderSucceedsWhenConfirmationFails(t *testing.T) {
+	tc := newTestCheckout(t)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	tc.svc.emailSvcAddr = failing.URL
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v, want the order placed without a confirmation", err)
+	}
+}
+
+func TestPlaceOrderPublishesOrder(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+
+	var published pb.OrderResult
+	producer.ExpectInputWithCheckerFunctionAndSucceed(func(v []byte) error {
+		return proto.Unmarshal(v, &published)
+	})
+
+	tc := newTestCheckout(t)
+	tc.svc = newCheckoutService(checkoutDeps{
+		cart:            tc.cart,
+		productCatalog:  tc.catalog,
+		currency:        tc.currency,
+		shipping:        tc.shipping,
+		payment:         tc.payment,
+		emailAddr:       tc.email.URL,
+		kafkaProducer:   producer,
+		kafkaBrokerAddr: "kafka:9092",
+	})
+	useKafkaProducer(tc.svc, producer)
+
+	resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if published.GetOrderId() != resp.GetOrder().GetOrderId() {
+		t.Errorf("published order %q, want %q", published.GetOrderId(), resp.GetOrder().GetOrderId())
+	}
+}
+
+func TestPlaceOrderDeadLettersFailedPublish(t *testing.T) {
+	config := mocks.NewTestConfig()
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+	producer.ExpectInputAndFail(errors.New("broker down"))
+
+	tc := newTestCheckout(t)
+	useKafkaProducer(tc.svc, producer)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	tc.svc.deadLetters = kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v, want the order placed despite the failed publish", err)
+	}
+	if n, err := tc.svc.deadLetters.Len(); err != nil || n != 1 {
+		t.Errorf("dead letters = %d, %v, want the order dead-lettered", n, err)
+	}
+}
+
+func TestPlaceOrderWithoutKafkaProducer(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	tc.svc.deadLetters = kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	before := counterValue(t, "checkout.kafka.producer_unavailable", "", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v, want the order placed without publishing it", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("PlaceOrder() blocked on the missing Kafka producer")
+	}
+	if got := counterValue(t, "checkout.kafka.producer_unavailable", "", ""); got != before+1 {
+		t.Errorf("checkout.kafka.producer_unavailable = %d, want %d", got, before+1)
+	}
+	if n, err := tc.svc.deadLetters.Len(); err != nil || n != 1 {
+		t.Errorf("dead letters = %d, %v, want the order dead-lettered", n, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans routes spans started with tracer to a recorder for the duration
+// of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	orig := tracer
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("checkoutservice")
+	t.Cleanup(func() { tracer = orig })
+	return recorder
+}
+
+func TestPrepareOrderStepSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	req := testPlaceOrderRequest()
+
+	if _, err := tc.svc.prepareOrderItemsAndShippingQuoteFromCart(context.Background(), req.UserId, req.UserCurrency, req.Address); err != nil {
+		t.Fatalf("prepareOrderItemsAndShippingQuoteFromCart: %v", err)
+	}
+
+	spans := recorder.Ended()
+	var parent sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if s.Name() == "prepareOrderItemsAndShippingQuoteFromCart" {
+			parent = s
+		}
+	}
+	if parent == nil {
+		t.Fatal("no prepareOrderItemsAndShippingQuoteFromCart span")
+	}
+
+	var children []string
+	for _, s := range spans {
+		if s.Parent().SpanID() == parent.SpanContext().SpanID() {
+			children = append(children, s.Name())
+			if s.EndTime().Before(s.StartTime()) {
+				t.Errorf("span %s ends before it starts", s.Name())
+			}
+		}
+	}
+	want := []string{"getUserCart", "prepOrderItems", "quoteShipping", "convertShippingCost"}
+	if len(children) != len(want) {
+		t.Fatalf("child spans = %v, want %v", children, want)
+	}
+	for i := range want {
+		if children[i] != want[i] {
+			t.Errorf("child spans = %v, want %v", children, want)
+			break
+		}
+	}
+}
+
+func TestPrepareOrderFailedStepSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	tc.shipping.quoteErr = errors.New("no quote")
+	req := testPlaceOrderRequest()
+
+	if _, err := tc.svc.prepareOrderItemsAndShippingQuoteFromCart(context.Background(), req.UserId, req.UserCurrency, req.Address); err == nil {
+		t.Fatal("prepareOrderItemsAndShippingQuoteFromCart succeeded without a shipping quote")
+	}
+
+	for _, s := range recorder.Ended() {
+		switch s.Name() {
+		case "quoteShipping":
+			if s.Status().Code != otelcodes.Error {
+				t.Errorf("quoteShipping span status = %v, want Error", s.Status())
+			}
+		case "convertShippingCost":
+			t.Error("convertShippingCost ran after the shipping quote failed")
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// defaultPrewarmTimeout bounds the rate warm-up when
+// CHECKOUT_PREWARM_TIMEOUT_MS is not set.
+const defaultPrewarmTimeout = 10 * time.Second
+
+// prewarmCurrenciesFromEnv reads the comma-separated currency codes in
+// CHECKOUT_PREWARM_CURRENCIES, such as "EUR,GBP,JPY".
+func prewarmCurrenciesFromEnv() ([]string, error) {
+	var currencies []string
+	for _, code := range strings.Split(os.Getenv("CHECKOUT_PREWARM_CURRENCIES"), ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if !money.IsValidCurrencyCode(code) {
+			return nil, fmt.Errorf("invalid currency code %q in CHECKOUT_PREWARM_CURRENCIES", code)
+		}
+		currencies = append(currencies, code)
+	}
+	return currencies, nil
+}
+
+// prewarmRates fills the rate cache with the rates from USD, the currency of
+// the catalog, to each of currencies, so that the first orders after a cold
+// start do not all hit the currency service. The service reports itself as
+// not serving until it returns. Currencies that fail to convert are logged and
+// left to be fetched on demand.
+func (cs *checkoutService) prewarmRates(ctx context.Context, currencies []string) {
+	defer cs.warming.Store(false)
+	if cs.rates == nil {
+		if len(currencies) > 0 {
+			logger.Warn("CHECKOUT_PREWARM_CURRENCIES is set but the rate cache is disabled, skipping warm-up")
+		}
+		return
+	}
+
+	warmed := 0
+	for _, code := range currencies {
+		if code == "USD" {
+			continue
+		}
+		if _, err := cs.convertCurrency(ctx, &pb.Money{CurrencyCode: "USD", Units: 1}, code); err != nil {
+			logger.Warn("failed to prewarm exchange rate", "currency", code, "error", err.Error())
+			continue
+		}
+		warmed++
+	}
+	logger.Info("prewarmed exchange rates", "requested", len(currencies), "warmed", warmed)
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestPrewarmCurrenciesFromEnv(t *testing.T) {
+	if got, err := prewarmCurrenciesFromEnv(); err != nil || got != nil {
+		t.Errorf("prewarmCurrenciesFromEnv() = %v, %v by default, want none", got, err)
+	}
+	t.Setenv("CHECKOUT_PREWARM_CURRENCIES", "EUR, JPY,")
+	if got, err := prewarmCurrenciesFromEnv(); err != nil || !slices.Equal(got, []string{"EUR", "JPY"}) {
+		t.Errorf("prewarmCurrenciesFromEnv() = %v, %v, want EUR and JPY", got, err)
+	}
+	t.Setenv("CHECKOUT_PREWARM_CURRENCIES", "EUR,euro")
+	if _, err := prewarmCurrenciesFromEnv(); err == nil {
+		t.Error("prewarmCurrenciesFromEnv() accepted an invalid currency code")
+	}
+}
+
+func servingStatus(t *testing.T, cs *checkoutService) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := cs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	return resp.Status
+}
+
+func TestPrewarmRatesBeforeServing(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.rates = newRateCache(time.Minute)
+	tc.currency.rate = 0.9
+
+	tc.svc.warming.Store(true)
+	if got := servingStatus(t, tc.svc); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status while warming = %v, want NOT_SERVING", got)
+	}
+	tc.svc.prewarmRates(context.Background(), []string{"EUR", "USD", "JPY"})
+	if got := servingStatus(t, tc.svc); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status after warming = %v, want SERVING", got)
+	}
+
+	if n := tc.currency.convertCalls.Load(); n != 2 {
+		t.Errorf("currency service called %d times, want once for each of EUR and JPY", n)
+	}
+	for _, code := range []string{"EUR", "JPY"} {
+		if rate, ok := tc.svc.rates.get("USD", code); !ok || rate != 0.9 {
+			t.Errorf("cached USD to %s rate = %v, %v, want 0.9", code, rate, ok)
+		}
+	}
+
+	// the first order in a prewarmed currency converts locally
+	req := testPlaceOrderRequest()
+	req.UserCurrency = "EUR"
+	if _, err := tc.svc.PlaceOrder(context.Background(), req); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	if n := tc.currency.convertCalls.Load(); n != 2 {
+		t.Errorf("currency service called %d times after the first EUR order, want no more calls", n)
+	}
+}
+
+func TestPrewarmRatesServesDespiteFailures(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.rates = newRateCache(time.Minute)
+	tc.currency.err = status.Error(codes.Unavailable, "connection refused")
+
+	tc.svc.warming.Store(true)
+	tc.svc.prewarmRates(context.Background(), []string{"EUR"})
+	if got := servingStatus(t, tc.svc); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status after a failed warm-up = %v, want SERVING", got)
+	}
+	if _, ok := tc.svc.rates.get("USD", "EUR"); ok {
+		t.Error("a rate was cached although the currency service failed")
+	}
+}
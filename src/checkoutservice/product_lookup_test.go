@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyCatalogClient fails lookups of the products in unavailable as if the
+// catalog could not be reached.
+type flakyCatalogClient struct {
+	*fakeCatalogClient
+	unavailable map[string]bool
+}
+
+func (f *flakyCatalogClient) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.Product, error) {
+	if f.unavailable[in.GetId()] {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return f.fakeCatalogClient.GetProduct(ctx, in, opts...)
+}
+
+func TestProductLookupFailures(t *testing.T) {
+	t.Run("not found fails the order", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.skipUnavailableProducts = true
+		delete(tc.catalog.products, "66VCHSJNUP")
+
+		_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if status.Code(err) != codes.NotFound || !strings.Contains(err.Error(), "66VCHSJNUP") {
+			t.Errorf("PlaceOrder() error = %v, want NotFound naming the product", err)
+		}
+		if len(tc.payment.charges) != 0 {
+			t.Errorf("card charged %d times, want no charge", len(tc.payment.charges))
+		}
+	})
+
+	t.Run("unavailable fails the order by default", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.productCatalogSvcClient = &flakyCatalogClient{tc.catalog, map[string]bool{"66VCHSJNUP": true}}
+
+		_, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("PlaceOrder() error = %v, want Unavailable with the catalog's error", err)
+		}
+	})
+
+	t.Run("unavailable product is skipped", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.skipUnavailableProducts = true
+		tc.svc.productCatalogSvcClient = &flakyCatalogClient{tc.catalog, map[string]bool{"66VCHSJNUP": true}}
+
+		resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+		if err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+		items := resp.GetOrder().GetItems()
+		if len(items) != 1 || items[0].GetItem().GetProductId() != "OLJCESPC7Z" {
+			t.Errorf("order items = %v, want only OLJCESPC7Z", items)
+		}
+	})
+
+	t.Run("skipped product stays in the cart", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.skipUnavailableProducts = true
+		tc.svc.productCatalogSvcClient = &flakyCatalogClient{tc.catalog, map[string]bool{"66VCHSJNUP": true}}
+		before := len(tc.cart.items)
+
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+			t.Fatalf("PlaceOrder() error = %v", err)
+		}
+		// the fake cart keeps its items when emptied, so only the lines added
+		// back after emptying it are new
+		kept := tc.cart.items[before:]
+		if tc.cart.emptied != 1 || len(kept) != 1 || kept[0].GetProductId() != "66VCHSJNUP" {
+			t.Errorf("cart emptied %d times and given back %v, want emptied once and 66VCHSJNUP kept", tc.cart.emptied, kept)
+		}
+	})
+
+	t.Run("every product unavailable", func(t *testing.T) {
+		tc := newTestCheckout(t)
+		tc.svc.skipUnavailableProducts = true
+		tc.svc.productCatalogSvcClient = &flakyCatalogClient{tc.catalog, map[string]bool{"66VCHSJNUP": true, "OLJCESPC7Z": true}}
+
+		if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); status.Code(err) != codes.Unavailable {
+			t.Errorf("PlaceOrder() error = %v, want Unavailable", err)
+		}
+	})
+}
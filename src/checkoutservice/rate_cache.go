@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// rateCache remembers the exchange rates seen in conversions for ttl, shared
+// by every request, so popular currency pairs are converted locally instead of
+// calling the currency service each time. A nil *rateCache never caches.
+type rateCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	rates map[[2]string]cachedRate
+}
+
+type cachedRate struct {
+	rate      float64
+	fetchedAt time.Time
+}
+
+// newRateCache returns a cache keeping rates for ttl, or nil when ttl is not
+// positive.
+func newRateCache(ttl time.Duration) *rateCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &rateCache{ttl: ttl, now: time.Now, rates: make(map[[2]string]cachedRate)}
+}
+
+// get returns the rate from one currency to another, or false if it is not
+// cached or has expired.
+func (c *rateCache) get(from, to string) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.rates[[2]string{from, to}]
+	if !ok || c.now().Sub(r.fetchedAt) >= c.ttl {
+		return 0, false
+	}
+	return r.rate, true
+}
+
+// put records the rate implied by converting from into to. Conversions of zero
+// imply no rate and are ignored.
+func (c *rateCache) put(from, to *pb.Money) {
+	if c == nil {
+		return
+	}
+	amount := float64(from.GetUnits()) + float64(from.GetNanos())/1e9
+	if amount == 0 {
+		return
+	}
+	rate := (float64(to.GetUnits()) + float64(to.GetNanos())/1e9) / amount
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rates[[2]string{from.GetCurrencyCode(), to.GetCurrencyCode()}] = cachedRate{rate: rate, fetchedAt: c.now()}
+}
@@ -0,0 +1,78 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+)
+
+func TestConvertCurrencyCachesRates(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.currency.rate = 0.9
+	now := time.Now()
+	tc.svc.rates = newRateCache(time.Minute)
+	tc.svc.rates.now = func() time.Time { return now }
+
+	convert := func(units int64) *pb.Money {
+		t.Helper()
+		got, err := tc.svc.convertCurrency(context.Background(), &pb.Money{CurrencyCode: "USD", Units: units}, "EUR")
+		if err != nil {
+			t.Fatalf("convertCurrency() error = %v", err)
+		}
+		return got
+	}
+
+	convert(100)
+	// a later request for the same pair is converted locally
+	if got, want := convert(200), (&pb.Money{CurrencyCode: "EUR", Units: 180}); !money.AreEquals(got, want) {
+		t.Errorf("cached conversion = %v, want %v", got, want)
+	}
+	if got := tc.currency.convertCalls.Load(); got != 1 {
+		t.Errorf("currency service called %d times, want 1", got)
+	}
+
+	// other pairs are not served from the cache
+	if _, err := tc.svc.convertCurrency(context.Background(), &pb.Money{CurrencyCode: "USD", Units: 1}, "JPY"); err != nil {
+		t.Fatalf("convertCurrency() error = %v", err)
+	}
+	if got := tc.currency.convertCalls.Load(); got != 2 {
+		t.Errorf("currency service called %d times, want 2", got)
+	}
+
+	now = now.Add(time.Minute)
+	convert(100)
+	if got := tc.currency.convertCalls.Load(); got != 3 {
+		t.Errorf("currency service called %d times after the rate expired, want 3", got)
+	}
+}
+
+func TestConvertCurrencyRateCacheBypass(t *testing.T) {
+	useFlagProvider(t, &recordingProvider{bools: map[string]bool{"currencyRateCacheBypass": true}})
+	tc := newTestCheckout(t)
+	tc.svc.rates = newRateCache(time.Minute)
+
+	for range 2 {
+		if _, err := tc.svc.convertCurrency(context.Background(), &pb.Money{CurrencyCode: "USD", Units: 100}, "EUR"); err != nil {
+			t.Fatalf("convertCurrency() error = %v", err)
+		}
+	}
+	if got := tc.currency.convertCalls.Load(); got != 2 {
+		t.Errorf("currency service called %d times with the cache bypassed, want 2", got)
+	}
+}
+
+func TestRateCacheIgnoresZeroAmounts(t *testing.T) {
+	c := newRateCache(time.Minute)
+	c.put(&pb.Money{CurrencyCode: "USD"}, &pb.Money{CurrencyCode: "EUR"})
+	if _, ok := c.get("USD", "EUR"); ok {
+		t.Error("rate cached from a conversion of zero")
+	}
+	if newRateCache(0) != nil {
+		t.Error("newRateCache(0) != nil, want caching disabled")
+	}
+}
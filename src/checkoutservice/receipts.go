@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// orderStore keeps the most recently placed orders in memory, so their
+// receipts can be rendered again after checkout. Once size orders are held,
+// each new order replaces the oldest one. A nil *orderStore keeps nothing.
+type orderStore struct {
+	size int
+
+	mu     sync.Mutex
+	orders map[string]*pb.OrderResult
+	ids    []string // order IDs, oldest first
+}
+
+// newOrderStore returns a store of the last size orders, or nil when size is
+// not positive.
+func newOrderStore(size int) *orderStore {
+	if size <= 0 {
+		return nil
+	}
+	return &orderStore{size: size, orders: make(map[string]*pb.OrderResult, size)}
+}
+
+// put stores order.
+func (s *orderStore) put(order *pb.OrderResult) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.GetOrderId()]; !ok {
+		if len(s.ids) == s.size {
+			delete(s.orders, s.ids[0])
+			s.ids = s.ids[1:]
+		}
+		s.ids = append(s.ids, order.GetOrderId())
+	}
+	s.orders[order.GetOrderId()] = order
+}
+
+// get returns the order with the given ID.
+func (s *orderStore) get(orderID string) (*pb.OrderResult, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	return order, ok
+}
+
+// GetReceipt renders the receipt of a stored order.
+func (cs *checkoutService) GetReceipt(ctx context.Context, req *pb.GetReceiptRequest) (*pb.Receipt, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("app.order.id", req.GetOrderId()))
+
+	if cs.orders == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no order store is configured")
+	}
+	order, ok := cs.orders.get(req.GetOrderId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "order %q not found", req.GetOrderId())
+	}
+	summary, err := newOrderSummary(order, "")
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to render receipt", "order_id", req.GetOrderId(), "error", err.Error())
+		return nil, status.Errorf(codes.Internal, "failed to render receipt: %v", err)
+	}
+
+	zero := money.Format(&pb.Money{CurrencyCode: summary.Currency})
+	receipt := &pb.Receipt{
+		OrderId:            summary.OrderID,
+		Currency:           summary.Currency,
+		Items:              make([]*pb.ReceiptLine, 0, len(summary.Items)),
+		Subtotal:           summary.Subtotal,
+		Shipping:           summary.ShippingCost,
+		Tax:                zero,
+		Discount:           zero,
+		Total:              summary.Total,
+		ShippingTrackingId: summary.ShippingTrackingID,
+		ShippingAddress:    summary.ShippingAddress,
+	}
+	for _, line := range summary.Items {
+		receipt.Items = append(receipt.Items, &pb.ReceiptLine{
+			ProductId: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			LineTotal: line.LineTotal,
+		})
+	}
+	return receipt, nil
+}
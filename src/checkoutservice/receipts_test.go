@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetReceipt(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.orders = newOrderStore(10)
+
+	resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+	if err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	orderID := resp.GetOrder().GetOrderId()
+
+	receipt, err := tc.svc.GetReceipt(context.Background(), &pb.GetReceiptRequest{OrderId: orderID})
+	if err != nil {
+		t.Fatalf("GetReceipt() error = %v", err)
+	}
+	want := &pb.Receipt{
+		OrderId:  orderID,
+		Currency: "USD",
+		Items: []*pb.ReceiptLine{
+			{ProductId: "OLJCESPC7Z", Quantity: 2, UnitPrice: "USD 101.96", LineTotal: "USD 203.92"},
+			{ProductId: "66VCHSJNUP", Quantity: 1, UnitPrice: "USD 349.95", LineTotal: "USD 349.95"},
+		},
+		Subtotal:           "USD 553.87",
+		Shipping:           "USD 8.99",
+		Tax:                "USD 0.00",
+		Discount:           "USD 0.00",
+		Total:              "USD 562.86",
+		ShippingTrackingId: "TRACK-1",
+		ShippingAddress:    "1600 Amphitheatre Parkway, Mountain View, CA, US 94043",
+	}
+	if !proto.Equal(receipt, want) {
+		t.Errorf("GetReceipt() = %v, want %v", receipt, want)
+	}
+
+	if _, err := tc.svc.GetReceipt(context.Background(), &pb.GetReceiptRequest{OrderId: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetReceipt() of an unknown order error = %v, want NotFound", err)
+	}
+
+	tc.svc.orders = nil
+	if _, err := tc.svc.GetReceipt(context.Background(), &pb.GetReceiptRequest{OrderId: orderID}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("GetReceipt() without an order store error = %v, want FailedPrecondition", err)
+	}
+}
+
+func TestOrderStoreKeepsLatestOrders(t *testing.T) {
+	s := newOrderStore(2)
+	for _, id := range []string{"A", "B", "A", "C"} {
+		s.put(&pb.OrderResult{OrderId: id})
+	}
+	if _, ok := s.get("A"); ok {
+		t.Error("the oldest order is still stored")
+	}
+	for _, id := range []string{"B", "C"} {
+		if _, ok := s.get(id); !ok {
+			t.Errorf("order %s is not stored", id)
+		}
+	}
+	if newOrderStore(0) != nil {
+		t.Error("newOrderStore(0) stores orders")
+	}
+}
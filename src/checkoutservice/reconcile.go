@@ -0,0 +1,184 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+const (
+	defaultReconcileBaseDelay   = time.Second
+	defaultReconcileMaxDelay    = time.Minute
+	defaultReconcileMaxAttempts = 10
+)
+
+// compensation undoes a step of an order that could not be completed, such as
+// refunding the charge of an order that could not be shipped.
+type compensation struct {
+	kind    string
+	orderID string
+	run     func(context.Context) error
+
+	attempts int
+	next     time.Time
+}
+
+// reconciler retries failed compensations in the background with exponential
+// backoff, so that orders are not left half-completed when a compensation
+// fails. Pending compensations live in memory only. A nil *reconciler drops
+// every compensation it is given.
+type reconciler struct {
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxAttempts int
+	now         func() time.Time
+
+	mu      sync.Mutex
+	pending []*compensation
+	wake    chan struct{}
+}
+
+func newReconciler(baseDelay, maxDelay time.Duration, maxAttempts int) *reconciler {
+	return &reconciler{
+		baseDelay:   baseDelay,
+		maxDelay:    max(maxDelay, baseDelay),
+		maxAttempts: maxAttempts,
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// enqueue schedules c to be retried after the base delay. c has already
+// failed once.
+func (r *reconciler) enqueue(ctx context.Context, c *compensation) {
+	if r == nil {
+		logger.ErrorContext(ctx, "compensation failed and will not be retried", "kind", c.kind, "order_id", c.orderID)
+		return
+	}
+	c.attempts = 1
+	c.next = r.now().Add(r.baseDelay)
+
+	r.mu.Lock()
+	r.pending = append(r.pending, c)
+	r.mu.Unlock()
+
+	reconciliationPending.Add(ctx, 1)
+	logger.WarnContext(ctx, "compensation failed, queued for reconciliation", "kind", c.kind, "order_id", c.orderID)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pendingCount returns the number of compensations waiting to be retried.
+func (r *reconciler) pendingCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// run retries due compensations until ctx is done.
+func (r *reconciler) run(ctx context.Context) {
+	for {
+		r.retry(ctx, false)
+
+		timer := time.NewTimer(r.untilNextDue())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-r.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// drain makes a last attempt at every pending compensation, ignoring their
+// backoff, and returns how many are still pending. It is called on shutdown.
+func (r *reconciler) drain(ctx context.Context) int {
+	r.retry(ctx, true)
+	return r.pendingCount()
+}
+
+// retry attempts the compensations that are due, or all of them when all is
+// set, requeueing those that fail again.
+func (r *reconciler) retry(ctx context.Context, all bool) {
+	now := r.now()
+
+	r.mu.Lock()
+	var due, waiting []*compensation
+	for _, c := range r.pending {
+		if all || !now.Before(c.next) {
+			due = append(due, c)
+		} else {
+			waiting = append(waiting, c)
+		}
+	}
+	r.pending = waiting
+	r.mu.Unlock()
+
+	for _, c := range due {
+		err := c.run(ctx)
+		switch {
+		case err == nil:
+			reconciliationPending.Add(ctx, -1)
+			logger.InfoContext(ctx, "compensation reconciled", "kind", c.kind, "order_id", c.orderID, "attempts", c.attempts+1)
+			continue
+		case c.attempts+1 >= r.maxAttempts:
+			reconciliationPending.Add(ctx, -1)
+			logger.ErrorContext(ctx, "giving up on compensation, manual reconciliation required",
+				"kind", c.kind, "order_id", c.orderID, "attempts", c.attempts+1, "error", err.Error())
+			continue
+		}
+
+		c.attempts++
+		c.next = r.now().Add(r.backoff(c.attempts))
+		r.mu.Lock()
+		r.pending = append(r.pending, c)
+		r.mu.Unlock()
+	}
+}
+
+// backoff returns the delay before the next attempt of a compensation that
+// failed attempts times.
+func (r *reconciler) backoff(attempts int) time.Duration {
+	d := r.baseDelay
+	for i := 1; i < attempts && d < r.maxDelay; i++ {
+		d *= 2
+	}
+	return min(d, r.maxDelay)
+}
+
+// untilNextDue returns how long until the next compensation is due.
+func (r *reconciler) untilNextDue() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wait := r.maxDelay
+	now := r.now()
+	for _, c := range r.pending {
+		wait = min(wait, c.next.Sub(now))
+	}
+	return max(wait, 0)
+}
+
+// refundCharge refunds the charge txID of an order that could not be
+// completed. When the refund fails it is handed to the reconciler to retry.
+func (cs *checkoutService) refundCharge(ctx context.Context, orderID, txID string, amount *pb.Money) {
+	refund := func(ctx context.Context) error {
+		done := cs.observeDependency(ctx, dependencyPayment)
+		_, err := cs.paymentSvcClient.Refund(ctx, &pb.RefundRequest{TransactionId: txID, Amount: amount})
+		done()
+		return err
+	}
+	if err := refund(ctx); err != nil {
+		cs.reconciler.enqueue(ctx, &compensation{kind: "refund", orderID: orderID, run: refund})
+		return
+	}
+	logger.InfoContext(ctx, "refunded charge of incomplete order", "order_id", orderID, "transaction_id", txID)
+}
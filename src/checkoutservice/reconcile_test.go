@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconcilerRetriesFailedCompensation(t *testing.T) {
+	r := newReconciler(time.Millisecond, 5*time.Millisecond, 10)
+
+	var attempts atomic.Int32
+	r.enqueue(context.Background(), &compensation{kind: "refund", orderID: "order-1", run: func(context.Context) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("payment service unavailable")
+		}
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.pendingCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("compensation still pending after %d retries", attempts.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("compensation retried %d times, want 3", got)
+	}
+}
+
+func TestReconcilerGivesUp(t *testing.T) {
+	r := newReconciler(time.Hour, time.Hour, 3)
+	var attempts int
+	r.enqueue(context.Background(), &compensation{kind: "refund", run: func(context.Context) error {
+		attempts++
+		return errors.New("still failing")
+	}})
+
+	if pending := r.drain(context.Background()); pending != 1 {
+		t.Fatalf("pending after the second attempt = %d, want 1", pending)
+	}
+	if pending := r.drain(context.Background()); pending != 0 || attempts != 2 {
+		t.Errorf("pending after the last attempt = %d after %d retries, want 0 after 2", pending, attempts)
+	}
+}
+
+func TestReconcilerBackoff(t *testing.T) {
+	r := newReconciler(time.Second, 5*time.Second, 10)
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 9: 5 * time.Second} {
+		if got := r.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestPlaceOrderRefundsUnshippedOrder(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.reconciler = newReconciler(time.Hour, time.Hour, 10)
+	tc.shipping.shipErr = errors.New("no carrier")
+	tc.payment.refundErrs = 1
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+		t.Fatal("PlaceOrder succeeded without shipping")
+	}
+	if tc.payment.refundCount() != 0 || tc.svc.reconciler.pendingCount() != 1 {
+		t.Fatalf("failed refund was not queued for reconciliation")
+	}
+
+	if pending := tc.svc.reconciler.drain(context.Background()); pending != 0 {
+		t.Errorf("pending after drain = %d, want 0", pending)
+	}
+	if tc.payment.refundCount() != 1 || tc.payment.refunds[0].TransactionId != "tx-1" {
+		t.Errorf("refunds = %v, want the charge tx-1 refunded", tc.payment.refunds)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// registerReflection registers the gRPC reflection service on srv, so tools
+// like grpcurl can introspect checkout. GRPC_REFLECTION turns it on or off;
+// when it is not set, reflection is on everywhere but in production, as in
+// productcatalogservice.
+func registerReflection(srv *grpc.Server) bool {
+	enabled, err := strconv.ParseBool(os.Getenv("GRPC_REFLECTION"))
+	if err != nil {
+		enabled = os.Getenv("ENVIRONMENT") != "production"
+	}
+	if enabled {
+		reflection.Register(srv)
+		logger.Info("gRPC reflection enabled")
+	}
+	return enabled
+}
@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// rejectionPublishTimeout bounds publishing a rejected order, which happens
+// even when the PlaceOrder call itself was cancelled.
+const rejectionPublishTimeout = 5 * time.Second
+
+// rejectedTopicFromEnv returns the topic failed orders are published to when
+// CHECKOUT_PUBLISH_REJECTED is true: KAFKA_REJECTED_TOPIC, or order-rejected
+// by default. Failed orders are not published when it returns "".
+func rejectedTopicFromEnv() string {
+	if enabled, _ := strconv.ParseBool(os.Getenv("CHECKOUT_PUBLISH_REJECTED")); !enabled {
+		return ""
+	}
+	if topic := os.Getenv("KAFKA_REJECTED_TOPIC"); topic != "" {
+		return topic
+	}
+	return kafka.RejectedTopic
+}
+
+// publishRejection publishes an OrderRejected event for an order that failed
+// with err at stage, when Kafka is configured and publishing failed orders is
+// enabled.
+func (cs *checkoutService) publishRejection(ctx context.Context, req *pb.PlaceOrderRequest, orderID string, stage checkoutStep, err error) {
+	if cs.rejectedTopic == "" || cs.kafkaBrokerSvcAddr == "" {
+		return
+	}
+	value, merr := proto.Marshal(&pb.OrderRejected{
+		OrderId:      orderID,
+		UserId:       req.GetUserId(),
+		UserCurrency: req.GetUserCurrency(),
+		ReasonCode:   status.Code(err).String(),
+		Stage:        stage.String(),
+	})
+	if merr != nil {
+		logger.ErrorContext(ctx, "Failed to marshal rejected order to protobuf", "error", merr.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rejectionPublishTimeout)
+	defer cancel()
+	cs.publish(ctx, &sarama.ProducerMessage{Topic: cs.rejectedTopic, Value: sarama.ByteEncoder(value)})
+}
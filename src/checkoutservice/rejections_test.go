@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRejectedTopicFromEnv(t *testing.T) {
+	if got := rejectedTopicFromEnv(); got != "" {
+		t.Errorf("rejectedTopicFromEnv() = %q by default, want publishing disabled", got)
+	}
+	t.Setenv("CHECKOUT_PUBLISH_REJECTED", "true")
+	if got := rejectedTopicFromEnv(); got != kafka.RejectedTopic {
+		t.Errorf("rejectedTopicFromEnv() = %q, want %q", got, kafka.RejectedTopic)
+	}
+	t.Setenv("KAFKA_REJECTED_TOPIC", "checkout-failures")
+	if got := rejectedTopicFromEnv(); got != "checkout-failures" {
+		t.Errorf("rejectedTopicFromEnv() = %q, want checkout-failures", got)
+	}
+}
+
+func TestPlaceOrderPublishesRejection(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+
+	var rejected pb.OrderRejected
+	producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "order-rejected" {
+			t.Errorf("rejection published to %q, want order-rejected", msg.Topic)
+		}
+		v, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(v, &rejected)
+	})
+
+	tc := newTestCheckout(t)
+	useKafkaProducer(tc.svc, producer)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	tc.svc.rejectedTopic = "order-rejected"
+	tc.payment.err = status.Error(codes.InvalidArgument, "card declined")
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); status.Code(err) != codes.Internal {
+		t.Fatalf("PlaceOrder() error = %v, want Internal", err)
+	}
+	if rejected.GetOrderId() == "" || rejected.GetUserId() != "user-1" || rejected.GetUserCurrency() != "USD" {
+		t.Errorf("rejected order = %v, want the order ID, user and currency", &rejected)
+	}
+	if rejected.GetReasonCode() != "Internal" || rejected.GetStage() != "CHARGE" {
+		t.Errorf("rejected order reason = %q at %q, want Internal at CHARGE", rejected.GetReasonCode(), rejected.GetStage())
+	}
+}
+
+func TestPlaceOrderDoesNotPublishRejectionByDefault(t *testing.T) {
+	config := mocks.NewTestConfig()
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+
+	tc := newTestCheckout(t)
+	useKafkaProducer(tc.svc, producer)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+	tc.cart.err = status.Error(codes.Unavailable, "connection refused")
+
+	// the mock producer fails the test on any message it does not expect
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+		t.Fatal("PlaceOrder() succeeded during a cart outage")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// replayPublishTimeout bounds how long a single dead letter may take to be
+// acknowledged by Kafka during a replay.
+const replayPublishTimeout = 5 * time.Second
+
+// ReplayDeadLetters publishes the order events queued in the dead letter queue
+// again. Events that are published are removed from the queue, so the RPC can
+// safely be called repeatedly.
+func (cs *checkoutService) ReplayDeadLetters(ctx context.Context, _ *pb.Empty) (*pb.ReplayDeadLettersResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if cs.deadLetters == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no dead letter queue is configured")
+	}
+	if cs.KafkaProducerClient == nil || cs.orderEvents == nil && cs.kafkaAcks == nil {
+		// without a reader of the producer's acknowledgements, the replay
+		// could not tell its own from those of the orders being placed
+		return nil, status.Error(codes.Unavailable, "kafka is not configured")
+	}
+
+	replayed, failed, err := cs.deadLetters.Replay(func(msg *sarama.ProducerMessage) error {
+		return cs.publishSync(ctx, msg)
+	})
+	span.SetAttributes(
+		attribute.Int("app.dead_letters.replayed", replayed),
+		attribute.Int("app.dead_letters.failed", failed),
+	)
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+		return nil, status.Errorf(codes.Internal, "failed to replay dead letters: %v", err)
+	}
+	logger.InfoContext(ctx, "replayed dead letters", "replayed", replayed, "failed", failed)
+
+	return &pb.ReplayDeadLettersResponse{Replayed: int32(replayed), Failed: int32(failed)}, nil
+}
+
+// publishSync sends msg to Kafka through whichever of the batcher or
+// kafkaAcks reads the producer's acknowledgements, and waits for it to be
+// acknowledged. The producer span continues the trace of the order msg
+// belongs to, which its headers carry.
+func (cs *checkoutService) publishSync(ctx context.Context, msg *sarama.ProducerMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, replayPublishTimeout)
+	defer cancel()
+	carrier := propagation.MapCarrier{}
+	for _, h := range msg.Headers {
+		carrier[string(h.Key)] = string(h.Value)
+	}
+	if order := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), carrier)); order.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, order)
+	}
+
+	if cs.orderEvents != nil {
+		return cs.orderEvents.sendSync(ctx, msg)
+	}
+	return cs.kafkaAcks.sendSync(ctx, msg)
+}
+
+// deadLetter queues msg for a later replay, if a dead letter queue is
+// configured.
+func (cs *checkoutService) deadLetter(ctx context.Context, msg *sarama.ProducerMessage, cause error) {
+	if cs.deadLetters == nil {
+		return
+	}
+	if err := cs.deadLetters.Add(msg, cause); err != nil {
+		logger.ErrorContext(ctx, "Failed to write message to the dead letter queue", "error", err.Error())
+		return
+	}
+	trace.SpanFromContext(ctx).AddEvent("message dead lettered")
+}
+
+// authorizeAdmin checks that the caller presents CHECKOUT_ADMIN_TOKEN as a
+// bearer token. Admin RPCs are disabled when no token is configured.
+func authorizeAdmin(ctx context.Context) error {
+	want := os.Getenv("CHECKOUT_ADMIN_TOKEN")
+	if want == "" {
+		return status.Error(codes.PermissionDenied, "checkout administration is disabled")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+}
@@ -0,0 +1,166 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/kafka"
+)
+
+func adminContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	t.Setenv("CHECKOUT_ADMIN_TOKEN", "secret")
+
+	dlq := kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	for _, id := range []string{"order-1", "order-2"} {
+		msg := &sarama.ProducerMessage{Topic: kafka.Topic, Value: sarama.StringEncoder(id)}
+		if err := dlq.Add(msg, errors.New("broker down")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+
+	var received []string
+	for range 2 {
+		producer.ExpectInputWithCheckerFunctionAndSucceed(func(v []byte) error {
+			received = append(received, string(v))
+			return nil
+		})
+	}
+
+	cs := &checkoutService{deadLetters: dlq}
+	useKafkaProducer(cs, producer)
+	resp, err := cs.ReplayDeadLetters(adminContext("secret"), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("ReplayDeadLetters: %v", err)
+	}
+	if resp.Replayed != 2 || resp.Failed != 0 {
+		t.Errorf("response = %v, want 2 replayed", resp)
+	}
+	if len(received) != 2 || received[0] != "order-1" || received[1] != "order-2" {
+		t.Errorf("producer received %v, want [order-1 order-2]", received)
+	}
+
+	// Replaying again must not publish anything twice.
+	resp, err = cs.ReplayDeadLetters(adminContext("secret"), &pb.Empty{})
+	if err != nil || resp.Replayed != 0 || resp.Failed != 0 {
+		t.Errorf("second ReplayDeadLetters = %v, %v, want nothing replayed", resp, err)
+	}
+
+	if _, err := cs.ReplayDeadLetters(adminContext("wrong"), &pb.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ReplayDeadLetters with a wrong token = %v, want Unauthenticated", err)
+	}
+}
+
+func TestReplayDeadLettersWithBatching(t *testing.T) {
+	t.Setenv("CHECKOUT_ADMIN_TOKEN", "secret")
+
+	dlq := kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	for _, id := range []string{"order-1", "order-2"} {
+		if err := dlq.Add(&sarama.ProducerMessage{Topic: kafka.Topic, Value: sarama.StringEncoder(id)}, errors.New("broker down")); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(errors.New("broker down"))
+
+	cs := &checkoutService{KafkaProducerClient: producer, deadLetters: dlq}
+	cs.orderEvents = newOrderEventBatcher(producer, 10, 10*time.Millisecond, 10, cs.deadLetter)
+	ctx, stop := context.WithCancel(context.Background())
+	go cs.orderEvents.run(ctx)
+	defer func() {
+		stop()
+		cs.orderEvents.wait(context.Background())
+		producer.Close()
+	}()
+
+	// the flusher reads every acknowledgement, and hands the replayed ones
+	// back instead of dead-lettering the failed one a second time
+	resp, err := cs.ReplayDeadLetters(adminContext("secret"), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("ReplayDeadLetters: %v", err)
+	}
+	if resp.Replayed != 1 || resp.Failed != 1 {
+		t.Errorf("response = %v, want 1 replayed and 1 failed", resp)
+	}
+	if n, err := dlq.Len(); err != nil || n != 1 {
+		t.Errorf("dead letter queue holds %d messages, %v, want the failed one only", n, err)
+	}
+}
+
+func TestReplayDeadLettersContinuesOrderTrace(t *testing.T) {
+	t.Setenv("CHECKOUT_ADMIN_TOKEN", "secret")
+	orig := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(orig) })
+	recorder := recordSpans(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	dlq := kafka.NewDeadLetterQueue(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	msg := &sarama.ProducerMessage{Topic: kafka.Topic, Value: sarama.StringEncoder("order-1"), Headers: []sarama.RecordHeader{
+		{Key: []byte("traceparent"), Value: []byte("00-" + traceID + "-00f067aa0ba902b7-01")},
+	}}
+	if err := dlq.Add(msg, errors.New("broker down")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	producer := newHeldProducer()
+	cs := &checkoutService{deadLetters: dlq}
+	useKafkaProducer(cs, producer)
+	published := make(chan *sarama.ProducerMessage, 1)
+	go func() {
+		msg := <-producer.input
+		published <- msg
+		producer.successes <- msg
+	}()
+
+	if resp, err := cs.ReplayDeadLetters(adminContext("secret"), &pb.Empty{}); err != nil || resp.Replayed != 1 {
+		t.Fatalf("ReplayDeadLetters = %v, %v, want 1 replayed", resp, err)
+	}
+	var traceparents int
+	for _, h := range (<-published).Headers {
+		if string(h.Key) == "traceparent" {
+			traceparents++
+		}
+	}
+	if traceparents != 1 {
+		t.Errorf("replayed message carries %d traceparent headers, want 1", traceparents)
+	}
+	producer.AsyncClose()
+	<-cs.kafkaAcks.done
+
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindProducer && span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("replay producer span is in trace %s, want the order's trace %s", span.SpanContext().TraceID(), traceID)
+		}
+	}
+	if len(recorder.Ended()) == 0 {
+		t.Error("no producer span recorded for the replay")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	defaultRequestIDHeader = "x-request-id"
+	maxRequestIDLen        = 128
+)
+
+type requestIDKey struct{}
+
+// requestIDs correlates client logs with server traces: every call gets the
+// request ID its caller sent in header, or a generated one, recorded on the
+// span and echoed back in a trailer.
+type requestIDs struct {
+	header string
+}
+
+// requestIDsFromEnv reads the request ID header name from
+// CHECKOUT_REQUEST_ID_HEADER.
+func requestIDsFromEnv() requestIDs {
+	header := strings.ToLower(os.Getenv("CHECKOUT_REQUEST_ID_HEADER"))
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	return requestIDs{header: header}
+}
+
+// unaryInterceptor attaches the request ID to the context of the call.
+func (r requestIDs) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := r.fromMetadata(ctx)
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("app.request.id", id))
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(r.header, id)); err != nil {
+		logger.WarnContext(ctx, "failed to echo request id", "request_id", id, "error", err.Error())
+	}
+	return handler(ctx, req)
+}
+
+// fromMetadata returns the request ID sent by the caller, ignoring IDs that
+// are too long to be an identifier.
+func (r requestIDs) fromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(r.header) {
+		if v = strings.TrimSpace(v); v != "" && len(v) <= maxRequestIDLen {
+			return v
+		}
+	}
+	return ""
+}
+
+// requestIDFromContext returns the ID of the request being served, or "".
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
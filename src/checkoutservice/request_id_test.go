@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeTransportStream captures the trailer set by a handler.
+type fakeTransportStream struct {
+	trailer metadata.MD
+}
+
+func (s *fakeTransportStream) Method() string                  { return "/oteldemo.CheckoutService/PlaceOrder" }
+func (s *fakeTransportStream) SetHeader(md metadata.MD) error  { return nil }
+func (s *fakeTransportStream) SendHeader(md metadata.MD) error { return nil }
+func (s *fakeTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestRequestIDInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming metadata.MD
+		header   string
+		want     string
+	}{
+		{"propagated", metadata.Pairs("x-request-id", "req-123"), "", "req-123"},
+		{"custom header", metadata.Pairs("x-correlation-id", "corr-9"), "X-Correlation-Id", "corr-9"},
+		{"generated when absent", metadata.MD{}, "", ""},
+		{"generated when too long", metadata.Pairs("x-request-id", strings.Repeat("x", 200)), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHECKOUT_REQUEST_ID_HEADER", tt.header)
+			r := requestIDsFromEnv()
+
+			stream := &fakeTransportStream{}
+			ctx := metadata.NewIncomingContext(context.Background(), tt.incoming)
+			ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+
+			var seen string
+			_, err := r.unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				seen = requestIDFromContext(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("unaryInterceptor: %v", err)
+			}
+
+			if tt.want != "" && seen != tt.want {
+				t.Errorf("request id seen by the handler = %q, want %q", seen, tt.want)
+			}
+			if tt.want == "" {
+				if _, err := uuid.Parse(seen); err != nil {
+					t.Errorf("generated request id %q is not a UUID", seen)
+				}
+			}
+			if echoed := stream.trailer.Get(r.header); len(echoed) != 1 || echoed[0] != seen {
+				t.Errorf("trailer %s = %v, want [%s]", r.header, echoed, seen)
+			}
+		})
+	}
+}
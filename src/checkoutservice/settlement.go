@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// settlementCurrencyFromEnv reads CHECKOUT_SETTLEMENT_CURRENCY, the currency
+// the merchant settles in. Customers are charged in their own currency when
+// it is not set.
+func settlementCurrencyFromEnv() (string, error) {
+	code := strings.TrimSpace(os.Getenv("CHECKOUT_SETTLEMENT_CURRENCY"))
+	if code != "" && !money.IsValidCurrencyCode(code) {
+		return "", fmt.Errorf("invalid currency code %q in CHECKOUT_SETTLEMENT_CURRENCY", code)
+	}
+	return code, nil
+}
+
+// settlementAmount returns total converted to the settlement currency, the
+// amount the card is charged, and records both amounts on the span in ctx.
+// total is returned as is when there is no settlement currency or it is
+// already in it.
+func (cs *checkoutService) settlementAmount(ctx context.Context, total *pb.Money) (*pb.Money, error) {
+	if cs.settlementCurrency == "" || total.GetCurrencyCode() == cs.settlementCurrency {
+		return total, nil
+	}
+	settled, err := cs.convertCurrency(ctx, total, cs.settlementCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to settlement currency %s: %w", money.Format(total), cs.settlementCurrency, err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("app.payment.user_amount", money.Format(total)),
+		attribute.String("app.payment.settlement_amount", money.Format(settled)),
+	)
+	return settled, nil
+}
@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/money"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSettlementCurrency(t *testing.T) {
+	plain := newTestCheckout(t)
+	plain.currency.rate = 2
+	if _, err := plain.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	userTotal := plain.payment.charges[0].GetAmount()
+	if userTotal.GetCurrencyCode() != "USD" {
+		t.Fatalf("charged in %s without a settlement currency, want USD", userTotal.GetCurrencyCode())
+	}
+
+	tc := newTestCheckout(t)
+	tc.currency.rate = 2
+	tc.svc.settlementCurrency = "EUR"
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+	want, err := money.Convert(userTotal, 2, "EUR")
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if got := tc.payment.charges[0].GetAmount(); !proto.Equal(got, want) {
+		t.Errorf("charged %s, want %s", money.Format(got), money.Format(want))
+	}
+}
+
+func TestSettlementCurrencyFromEnv(t *testing.T) {
+	t.Setenv("CHECKOUT_SETTLEMENT_CURRENCY", "")
+	if code, err := settlementCurrencyFromEnv(); code != "" || err != nil {
+		t.Errorf("settlementCurrencyFromEnv() unset = %q, %v, want no settlement currency", code, err)
+	}
+	t.Setenv("CHECKOUT_SETTLEMENT_CURRENCY", "usd")
+	if _, err := settlementCurrencyFromEnv(); err == nil {
+		t.Errorf("settlementCurrencyFromEnv() accepted an invalid currency code")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestShippingServiceUnreachable(t *testing.T) {
+	useFlagProvider(t, &recordingProvider{bools: map[string]bool{"shippingServiceUnreachable": true}})
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	if _, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest()); status.Code(err) != codes.Unavailable {
+		t.Fatalf("PlaceOrder() error = %v, want Unavailable", err)
+	}
+	if len(tc.payment.charges) != 1 {
+		t.Fatalf("card charged %d times, want 1", len(tc.payment.charges))
+	}
+	if got := tc.payment.refundCount(); got != 1 {
+		t.Errorf("refunds attempted = %d, want 1", got)
+	}
+
+	unreachable := false
+	for _, span := range recorder.Ended() {
+		for _, event := range span.Events() {
+			unreachable = unreachable || event.Name == "shipping service unreachable"
+		}
+	}
+	if !unreachable {
+		t.Error("no span event recorded for the unreachable shipping service")
+	}
+}
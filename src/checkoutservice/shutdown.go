@@ -0,0 +1,120 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// defaultMetricsFlushTimeout bounds the final metric export on shutdown when
+// CHECKOUT_METRICS_FLUSH_TIMEOUT_MS is not set.
+const defaultMetricsFlushTimeout = 5 * time.Second
+
+// Kafka flush outcomes reported on shutdown.
+const (
+	kafkaFlushDisabled = "disabled"
+	kafkaFlushClean    = "clean"
+	kafkaFlushFailed   = "failed"
+)
+
+// runStats accumulates counters over the lifetime of the process, to be
+// summarized in a single log line on shutdown. A nil *runStats records nothing.
+type runStats struct {
+	started       time.Time
+	ordersPlaced  atomic.Int64
+	orderFailures atomic.Int64
+}
+
+func newRunStats() *runStats {
+	return &runStats{started: time.Now()}
+}
+
+func (s *runStats) orderPlaced() {
+	if s != nil {
+		s.ordersPlaced.Add(1)
+	}
+}
+
+func (s *runStats) orderFailed() {
+	if s != nil {
+		s.orderFailures.Add(1)
+	}
+}
+
+// shutdownReport summarizes a run of the service.
+type shutdownReport struct {
+	ordersPlaced  int64
+	orderFailures int64
+	uptime        time.Duration
+	kafkaFlush    string
+	kafkaError    string
+}
+
+// report summarizes the run as of now, given the outcome of flushing Kafka.
+func (s *runStats) report(now time.Time, kafkaFlush string, kafkaErr error) shutdownReport {
+	r := shutdownReport{
+		ordersPlaced:  s.ordersPlaced.Load(),
+		orderFailures: s.orderFailures.Load(),
+		uptime:        now.Sub(s.started).Round(time.Second),
+		kafkaFlush:    kafkaFlush,
+	}
+	if kafkaErr != nil {
+		r.kafkaError = kafkaErr.Error()
+	}
+	return r
+}
+
+// logArgs returns the report as key-value pairs for a structured log line.
+func (r shutdownReport) logArgs() []any {
+	args := []any{
+		"orders_placed", r.ordersPlaced,
+		"order_failures", r.orderFailures,
+		"uptime", r.uptime.String(),
+		"kafka_flush", r.kafkaFlush,
+	}
+	if r.kafkaError != "" {
+		args = append(args, "kafka_error", r.kafkaError)
+	}
+	return args
+}
+
+// flushKafka closes producer, waiting for buffered messages to be sent.
+func flushKafka(producer sarama.AsyncProducer) (string, error) {
+	if producer == nil {
+		return kafkaFlushDisabled, nil
+	}
+	if err := producer.Close(); err != nil {
+		return kafkaFlushFailed, err
+	}
+	return kafkaFlushClean, nil
+}
+
+// meterProvider is the part of *sdkmetric.MeterProvider used on shutdown.
+type meterProvider interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownMeterProvider collects and exports the metrics recorded since the
+// last periodic export before shutting mp down, so that the counters of the
+// final interval, such as the last orders placed, are not lost. The flush is
+// bounded by timeout; mp is shut down even if it fails.
+func shutdownMeterProvider(mp meterProvider, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := mp.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush metrics: %w", err))
+	}
+	if err := mp.Shutdown(context.Background()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shut down meter provider: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,179 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama/mocks"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRunStatsAccumulate(t *testing.T) {
+	stats := newRunStats()
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%5 == 0 {
+				stats.orderFailed()
+			} else {
+				stats.orderPlaced()
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := stats.report(stats.started.Add(90*time.Minute+400*time.Millisecond), kafkaFlushClean, nil)
+	if r.ordersPlaced != 40 || r.orderFailures != 10 {
+		t.Errorf("report = %+v, want 40 placed and 10 failures", r)
+	}
+	if r.uptime != 90*time.Minute {
+		t.Errorf("uptime = %v, want 1h30m0s", r.uptime)
+	}
+}
+
+func TestPlaceOrderUpdatesRunStats(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.stats = newRunStats()
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	tc.payment.err = errors.New("card declined")
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err == nil {
+		t.Fatal("PlaceOrder with a declined card succeeded")
+	}
+
+	if placed, failed := tc.svc.stats.ordersPlaced.Load(), tc.svc.stats.orderFailures.Load(); placed != 1 || failed != 1 {
+		t.Errorf("stats = %d placed, %d failed, want 1 and 1", placed, failed)
+	}
+}
+
+func TestShutdownReportLogArgs(t *testing.T) {
+	r := shutdownReport{ordersPlaced: 3, orderFailures: 1, uptime: 2 * time.Minute, kafkaFlush: kafkaFlushFailed, kafkaError: "broker gone"}
+	want := []any{"orders_placed", int64(3), "order_failures", int64(1), "uptime", "2m0s", "kafka_flush", "failed", "kafka_error", "broker gone"}
+
+	got := r.logArgs()
+	if len(got) != len(want) {
+		t.Fatalf("logArgs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("logArgs()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	r.kafkaError = ""
+	if got := r.logArgs(); len(got) != 8 {
+		t.Errorf("logArgs() without a kafka error = %v, want no kafka_error", got)
+	}
+}
+
+func TestFlushKafka(t *testing.T) {
+	if got, err := flushKafka(nil); got != kafkaFlushDisabled || err != nil {
+		t.Errorf("flushKafka(nil) = %q, %v, want disabled", got, err)
+	}
+
+	config := mocks.NewTestConfig()
+	if got, err := flushKafka(mocks.NewAsyncProducer(t, config)); got != kafkaFlushClean || err != nil {
+		t.Errorf("flushKafka() = %q, %v, want clean", got, err)
+	}
+}
+
+// recordingExporter records the calls made to it and the counters it exports.
+type recordingExporter struct {
+	mu       sync.Mutex
+	calls    []string
+	counters map[string]int64
+}
+
+func (e *recordingExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *recordingExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *recordingExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, "export")
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					e.counters[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *recordingExporter) ForceFlush(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, "flush")
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, "shutdown")
+	return nil
+}
+
+func TestShutdownMeterProviderFlushesFinalInterval(t *testing.T) {
+	exporter := &recordingExporter{counters: make(map[string]int64)}
+	// the interval is long enough that nothing is exported periodically
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))))
+	counter, err := mp.Meter("test").Int64Counter("app.orders.placed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 3)
+
+	if err := shutdownMeterProvider(mp, time.Second); err != nil {
+		t.Fatalf("shutdownMeterProvider() = %v", err)
+	}
+	if len(exporter.calls) < 2 || exporter.calls[0] != "export" || exporter.calls[1] != "flush" {
+		t.Errorf("exporter calls = %v, want a final export and flush before shutdown", exporter.calls)
+	}
+	if got := exporter.calls[len(exporter.calls)-1]; got != "shutdown" {
+		t.Errorf("last exporter call = %q, want shutdown", got)
+	}
+	if got := exporter.counters["app.orders.placed"]; got < 3 {
+		t.Errorf("exported app.orders.placed = %d, want the final interval's 3", got)
+	}
+}
+
+type failingMeterProvider struct{ shutdown bool }
+
+func (p *failingMeterProvider) ForceFlush(context.Context) error {
+	return errors.New("collector unreachable")
+}
+
+func (p *failingMeterProvider) Shutdown(context.Context) error {
+	p.shutdown = true
+	return nil
+}
+
+func TestShutdownMeterProviderShutsDownAfterFailedFlush(t *testing.T) {
+	mp := &failingMeterProvider{}
+	if err := shutdownMeterProvider(mp, time.Second); err == nil {
+		t.Error("shutdownMeterProvider() ignored the failed flush")
+	}
+	if !mp.shutdown {
+		t.Error("meter provider not shut down after a failed flush")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+// Bounds of the synthetic carts of SimulateOrder.
+const (
+	defaultSimulatedLines = 3
+	maxSimulatedLines     = 10
+	maxSimulatedQuantity  = 3
+)
+
+// simulatedAddresses are the shipping addresses of simulated orders, one of
+// which is picked at random.
+var simulatedAddresses = []*pb.Address{
+	{StreetAddress: "1600 Amphitheatre Parkway", City: "Mountain View", State: "CA", Country: "US", ZipCode: "94043"},
+	{StreetAddress: "10 Downing Street", City: "London", Country: "GB", ZipCode: "SW1A 2AA"},
+	{StreetAddress: "Platz der Republik 1", City: "Berlin", Country: "DE", ZipCode: "11011"},
+	{StreetAddress: "1 Chome-1-2 Oshiage", City: "Tokyo", Country: "JP", ZipCode: "131-0045"},
+}
+
+// SimulateOrder places an order with synthetic data, so load generators can
+// exercise the whole checkout pipeline without crafting requests: a cart of
+// random products is filled for a new user, then the order is placed through
+// PlaceOrder like any other, and the time spent in each step is returned. It
+// is disabled unless CHECKOUT_SIMULATION_ENABLED is set, and always refused
+// when ENVIRONMENT is production, as simulated orders are charged and shipped.
+func (cs *checkoutService) SimulateOrder(ctx context.Context, req *pb.SimulateOrderRequest) (*pb.SimulateOrderResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if os.Getenv("ENVIRONMENT") == "production" {
+		return nil, status.Error(codes.FailedPrecondition, "order simulation is not allowed in production")
+	}
+	if !cs.simulationEnabled {
+		return nil, status.Error(codes.PermissionDenied, "order simulation is disabled")
+	}
+	lines := int(req.GetLineCount())
+	if lines < 0 || lines > maxSimulatedLines {
+		return nil, status.Errorf(codes.InvalidArgument, "line count must be between 1 and %d", maxSimulatedLines)
+	}
+	if lines == 0 {
+		lines = defaultSimulatedLines
+	}
+	currency := req.GetUserCurrency()
+	if currency == "" {
+		currency = "USD"
+	}
+
+	products, err := cs.productCatalogSvcClient.ListProducts(ctx, &pb.ListProductsRequest{})
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list products: %v", err)
+	}
+	if len(products.GetProducts()) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "no products to simulate an order with")
+	}
+
+	userID := "simulated-" + uuid.NewString()
+	for _, i := range rand.Perm(len(products.GetProducts()))[:min(lines, len(products.GetProducts()))] {
+		item := &pb.CartItem{ProductId: products.GetProducts()[i].GetId(), Quantity: 1 + rand.Int32N(maxSimulatedQuantity)}
+		if _, err := cs.cartSvcClient.AddItem(ctx, &pb.AddItemRequest{UserId: userID, Item: item}); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to fill the simulated cart: %v", err)
+		}
+	}
+	span.SetAttributes(
+		attribute.String("app.user.id", userID),
+		attribute.Int("app.simulation.lines", lines),
+	)
+
+	ctx, timings := withStepTimings(ctx)
+	// PlaceOrder ends the span it is given
+	placeCtx, _ := tracer.Start(ctx, "PlaceOrder")
+	start := time.Now()
+	resp, err := cs.PlaceOrder(placeCtx, &pb.PlaceOrderRequest{
+		UserId:       userID,
+		UserCurrency: currency,
+		Address:      simulatedAddresses[rand.IntN(len(simulatedAddresses))],
+		Email:        "simulated@example.com",
+		CreditCard: &pb.CreditCardInfo{
+			CreditCardNumber:          "4432-8015-6152-0454",
+			CreditCardCvv:             672,
+			CreditCardExpirationYear:  int32(time.Now().Year() + 1),
+			CreditCardExpirationMonth: 1,
+		},
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		// the cart is only emptied once the order is placed
+		if emptyErr := cs.emptyUserCart(context.WithoutCancel(ctx), userID, nil); emptyErr != nil {
+			logger.WarnContext(ctx, "failed to empty the simulated cart", "user_id", userID, "error", emptyErr.Error())
+		}
+		return nil, err
+	}
+
+	out := &pb.SimulateOrderResponse{Order: resp.GetOrder(), TotalDurationMs: elapsed.Milliseconds()}
+	for _, st := range timings.list() {
+		out.Stages = append(out.Stages, &pb.StageTiming{Stage: st.step.String(), DurationMs: st.duration.Milliseconds()})
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+)
+
+func TestSimulateOrderDisabled(t *testing.T) {
+	tc := newTestCheckout(t)
+	if _, err := tc.svc.SimulateOrder(context.Background(), &pb.SimulateOrderRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("SimulateOrder() while disabled error = %v, want PermissionDenied", err)
+	}
+	if len(tc.payment.charges) != 0 {
+		t.Errorf("card charged %d times while simulation is disabled", len(tc.payment.charges))
+	}
+}
+
+func TestSimulateOrderRefusedInProduction(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	tc := newTestCheckout(t)
+	tc.svc.simulationEnabled = true
+	if _, err := tc.svc.SimulateOrder(context.Background(), &pb.SimulateOrderRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("SimulateOrder() in production error = %v, want FailedPrecondition", err)
+	}
+	if len(tc.payment.charges) != 0 {
+		t.Errorf("card charged %d times in production", len(tc.payment.charges))
+	}
+}
+
+func TestSimulateOrder(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.simulationEnabled = true
+	tc.cart.items = nil
+
+	resp, err := tc.svc.SimulateOrder(context.Background(), &pb.SimulateOrderRequest{LineCount: 2})
+	if err != nil {
+		t.Fatalf("SimulateOrder() error = %v", err)
+	}
+
+	order := resp.GetOrder()
+	if order.GetOrderId() == "" || order.GetShippingTrackingId() != "TRACK-1" {
+		t.Errorf("simulated order = %v, want a placed and shipped order", order)
+	}
+	if len(order.GetItems()) != 2 {
+		t.Errorf("simulated order has %d lines, want 2", len(order.GetItems()))
+	}
+	for _, item := range order.GetItems() {
+		if q := item.GetItem().GetQuantity(); q < 1 || q > maxSimulatedQuantity {
+			t.Errorf("simulated line %v has quantity %d, want 1 to %d", item.GetItem().GetProductId(), q, maxSimulatedQuantity)
+		}
+	}
+	if len(tc.payment.charges) != 1 || !strings.HasPrefix(tc.payment.charges[0].GetCreditCard().GetCreditCardNumber(), "4432") {
+		t.Errorf("charges = %v, want the simulated card charged once", tc.payment.charges)
+	}
+
+	var stages []string
+	var sum int64
+	for _, st := range resp.GetStages() {
+		stages = append(stages, st.GetStage())
+		sum += st.GetDurationMs()
+	}
+	for _, step := range []checkoutStep{stepValidate, stepPrice, stepQuoteShipping, stepCharge, stepShip, stepEmptyCart, stepEmail} {
+		if !slices.Contains(stages, step.String()) {
+			t.Errorf("stage timings %v lack %s", stages, step)
+		}
+	}
+	if resp.GetTotalDurationMs() < sum {
+		t.Errorf("total duration %dms is shorter than its stages' %dms", resp.GetTotalDurationMs(), sum)
+	}
+}
+
+func TestSimulateOrderLineCount(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.simulationEnabled = true
+	for _, n := range []int32{-1, maxSimulatedLines + 1} {
+		if _, err := tc.svc.SimulateOrder(context.Background(), &pb.SimulateOrderRequest{LineCount: n}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("SimulateOrder() with %d lines error = %v, want InvalidArgument", n, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// orderTimer times a PlaceOrder call and each of its stages.
+type orderTimer struct {
+	start  time.Time
+	stages map[string]time.Duration
+}
+
+func newOrderTimer() *orderTimer {
+	return &orderTimer{start: time.Now(), stages: make(map[string]time.Duration)}
+}
+
+// stage starts timing the named stage. The returned func ends it.
+func (t *orderTimer) stage(name string) func() {
+	start := time.Now()
+	return func() { t.stages[name] += time.Since(start) }
+}
+
+// dominantStage returns the stage that took the longest, or "unknown" if no
+// stage was timed.
+func (t *orderTimer) dominantStage() string {
+	dominant, longest := "unknown", time.Duration(-1)
+	for name, d := range t.stages {
+		if d > longest || (d == longest && name < dominant) {
+			dominant, longest = name, d
+		}
+	}
+	return dominant
+}
+
+// recordOrderDuration records how long PlaceOrder took in the duration
+// histogram and counts an SLA breach when it took longer than slaThreshold.
+func (cs *checkoutService) recordOrderDuration(ctx context.Context, t *orderTimer) {
+	elapsed := time.Since(t.start)
+	placeOrderHistogram.Record(ctx, elapsed.Milliseconds())
+
+	if cs.slaThreshold <= 0 || elapsed <= cs.slaThreshold {
+		return
+	}
+	stage := t.dominantStage()
+	slaBreachCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("app.checkout.stage", stage)))
+	trace.SpanFromContext(ctx).AddEvent("sla breached", trace.WithAttributes(
+		attribute.Int64("app.checkout.duration_ms", elapsed.Milliseconds()),
+		attribute.String("app.checkout.stage", stage),
+	))
+}
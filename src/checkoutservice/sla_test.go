@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlaceOrderSLABreach(t *testing.T) {
+	tc := newTestCheckout(t)
+	tc.svc.slaThreshold = 20 * time.Millisecond
+
+	before := counterValue(t, "checkout.sla_breach_count", "app.checkout.stage", "charge")
+
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if got := counterValue(t, "checkout.sla_breach_count", "app.checkout.stage", "charge"); got != before {
+		t.Errorf("fast order counted %d SLA breaches", got-before)
+	}
+
+	tc.payment.delay = 50 * time.Millisecond
+	if _, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if got := counterValue(t, "checkout.sla_breach_count", "app.checkout.stage", "charge"); got != before+1 {
+		t.Errorf("SLA breaches dominated by charge = %d, want %d", got, before+1)
+	}
+}
+
+func TestOrderTimerDominantStage(t *testing.T) {
+	timer := newOrderTimer()
+	if got := timer.dominantStage(); got != "unknown" {
+		t.Errorf("dominantStage() with no stages = %q, want unknown", got)
+	}
+
+	timer.stages["prepare"] = 10 * time.Millisecond
+	timer.stages["ship"] = 30 * time.Millisecond
+	timer.stages["charge"] = 30 * time.Millisecond
+	if got := timer.dominantStage(); got != "charge" {
+		t.Errorf("dominantStage() = %q, want charge, the first of the tied longest stages", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestStdoutLogs(t *testing.T) {
+	var bridged, stdout bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&bridged, nil))
+
+	t.Setenv("LOG_STDOUT", "")
+	if got := stdoutLogsFromEnv(base, &stdout); got != base {
+		t.Errorf("stdoutLogsFromEnv() without LOG_STDOUT returned a new logger")
+	}
+
+	t.Setenv("LOG_STDOUT", "json")
+	l := stdoutLogsFromEnv(base, &stdout).With("service", "checkout")
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "order")
+	l.InfoContext(ctx, "payment went through", "transaction_id", "tx-1")
+	span.End()
+
+	if bridged.Len() == 0 {
+		t.Errorf("record was not handed to the base logger")
+	}
+	var record map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil {
+		t.Fatalf("stdout log is not JSON: %v: %s", err, stdout.String())
+	}
+	for key, want := range map[string]string{
+		"level":          "INFO",
+		"msg":            "payment went through",
+		"service":        "checkout",
+		"transaction_id": "tx-1",
+		"trace_id":       span.SpanContext().TraceID().String(),
+		"span_id":        span.SpanContext().SpanID().String(),
+	} {
+		if got := record[key]; got != want {
+			t.Errorf("stdout log %s = %v, want %q", key, got, want)
+		}
+	}
+}
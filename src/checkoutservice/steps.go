@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// checkoutStep is one of the steps PlaceOrder goes through, in order.
+type checkoutStep int
+
+const (
+	stepValidate checkoutStep = iota + 1
+	stepPrice
+	stepQuoteShipping
+	stepCharge
+	stepShip
+	stepEmptyCart
+	stepEmail
+	stepPublish
+)
+
+var checkoutStepNames = map[checkoutStep]string{
+	stepValidate:      "VALIDATE",
+	stepPrice:         "PRICE",
+	stepQuoteShipping: "QUOTE_SHIPPING",
+	stepCharge:        "CHARGE",
+	stepShip:          "SHIP",
+	stepEmptyCart:     "EMPTY_CART",
+	stepEmail:         "EMAIL",
+	stepPublish:       "PUBLISH",
+}
+
+func (s checkoutStep) String() string {
+	if name, ok := checkoutStepNames[s]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+type stepTrackerKey struct{}
+
+// withStepTracker returns a context in which startStep stores the step it
+// starts in the returned value, so that a failed order can tell which step it
+// failed at.
+func withStepTracker(ctx context.Context) (context.Context, *atomic.Int32) {
+	current := new(atomic.Int32)
+	return context.WithValue(ctx, stepTrackerKey{}, current), current
+}
+
+type stepTimingsKey struct{}
+
+// stepTimings collects how long each step took, in the order the steps
+// finished.
+type stepTimings struct {
+	mu    sync.Mutex
+	steps []stepTiming
+}
+
+type stepTiming struct {
+	step     checkoutStep
+	duration time.Duration
+}
+
+// withStepTimings returns a context in which the steps record their duration
+// in the returned value.
+func withStepTimings(ctx context.Context) (context.Context, *stepTimings) {
+	timings := new(stepTimings)
+	return context.WithValue(ctx, stepTimingsKey{}, timings), timings
+}
+
+func (t *stepTimings) add(step checkoutStep, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, stepTiming{step, d})
+}
+
+func (t *stepTimings) list() []stepTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.steps
+}
+
+// startStep adds a "step started" event for step to the span in ctx. The
+// returned func adds the matching "step finished" event with the step's
+// outcome, duration and any extra attributes, and records the duration in
+// checkout.step.duration when step metrics are enabled.
+func (cs *checkoutService) startStep(ctx context.Context, step checkoutStep) func(err error, attrs ...attribute.KeyValue) {
+	if current, ok := ctx.Value(stepTrackerKey{}).(*atomic.Int32); ok {
+		current.Store(int32(step))
+	}
+	span := trace.SpanFromContext(ctx)
+	stepAttr := attribute.String("app.checkout.step", step.String())
+	span.AddEvent("step started", trace.WithAttributes(stepAttr))
+
+	start := time.Now()
+	return func(err error, attrs ...attribute.KeyValue) {
+		elapsed := time.Since(start)
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		outcomeAttr := attribute.String("app.checkout.step.outcome", outcome)
+		span.AddEvent("step finished", trace.WithAttributes(append([]attribute.KeyValue{
+			stepAttr,
+			outcomeAttr,
+			attribute.Int64("app.checkout.step.duration_ms", elapsed.Milliseconds()),
+		}, attrs...)...))
+		if cs.stepMetrics {
+			stepDuration.Record(ctx, elapsed.Milliseconds(), metric.WithAttributes(stepAttr, outcomeAttr))
+		}
+		if timings, ok := ctx.Value(stepTimingsKey{}).(*stepTimings); ok {
+			timings.add(step, elapsed)
+		}
+	}
+}
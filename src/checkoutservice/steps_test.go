@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/IBM/sarama/mocks"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stepEvents returns the step events of the span named PlaceOrder as
+// "started STEP" and "finished STEP outcome", in the order they were added.
+func stepEvents(t *testing.T, recorder *tracetest.SpanRecorder) []string {
+	t.Helper()
+	i := slices.IndexFunc(recorder.Ended(), func(s sdktrace.ReadOnlySpan) bool { return s.Name() == "PlaceOrder" })
+	if i < 0 {
+		t.Fatal("PlaceOrder span not recorded")
+	}
+	var events []string
+	for _, e := range recorder.Ended()[i].Events() {
+		attrs := make(map[string]string)
+		for _, kv := range e.Attributes {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		switch e.Name {
+		case "step started":
+			events = append(events, "started "+attrs["app.checkout.step"])
+		case "step finished":
+			events = append(events, fmt.Sprintf("finished %s %s", attrs["app.checkout.step"], attrs["app.checkout.step.outcome"]))
+		}
+	}
+	return events
+}
+
+func TestPlaceOrderStepEvents(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	defer producer.Close()
+	producer.ExpectInputAndSucceed()
+
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	useKafkaProducer(tc.svc, producer)
+	tc.svc.kafkaBrokerSvcAddr = "kafka:9092"
+
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	if _, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest()); err != nil {
+		t.Fatalf("PlaceOrder() error = %v", err)
+	}
+
+	var want []string
+	for _, step := range []checkoutStep{stepValidate, stepPrice, stepQuoteShipping, stepCharge, stepShip, stepEmptyCart, stepEmail, stepPublish} {
+		want = append(want, "started "+step.String(), "finished "+step.String()+" ok")
+	}
+	if got := stepEvents(t, recorder); !slices.Equal(got, want) {
+		t.Errorf("step events = %v, want %v", got, want)
+	}
+}
+
+func TestPlaceOrderStepEventsOnFailure(t *testing.T) {
+	recorder := recordSpans(t)
+	tc := newTestCheckout(t)
+	tc.payment.err = status.Error(codes.InvalidArgument, "card declined")
+
+	ctx, _ := tracer.Start(context.Background(), "PlaceOrder")
+	if _, err := tc.svc.PlaceOrder(ctx, testPlaceOrderRequest()); err == nil {
+		t.Fatal("PlaceOrder() succeeded with a declined card")
+	}
+
+	want := []string{
+		"started VALIDATE", "finished VALIDATE ok",
+		"started PRICE", "finished PRICE ok",
+		"started QUOTE_SHIPPING", "finished QUOTE_SHIPPING ok",
+		"started CHARGE", "finished CHARGE error",
+	}
+	if got := stepEvents(t, recorder); !slices.Equal(got, want) {
+		t.Errorf("step events = %v, want %v", got, want)
+	}
+}
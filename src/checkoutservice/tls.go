@@ -0,0 +1,136 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportSecurity holds the TLS configuration of the gRPC server and of the
+// clients to downstream services. A nil config means plaintext.
+type transportSecurity struct {
+	server *tls.Config
+	client *tls.Config
+}
+
+// transportSecurityFromEnv loads the server certificate from TLS_CERT_FILE and
+// TLS_KEY_FILE, and the CA used to verify downstream services from
+// TLS_CA_FILE. The server accepts the protocol versions and cipher suites set
+// by TLS_MIN_VERSION and TLS_CIPHER_SUITES. When ENVIRONMENT is production,
+// running without TLS is refused rather than warned about.
+func transportSecurityFromEnv() (transportSecurity, error) {
+	var ts transportSecurity
+
+	minVersion, cipherSuites, err := tlsPolicyFromEnv()
+	if err != nil {
+		return ts, err
+	}
+
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return ts, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		ts.server = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+		}
+	}
+
+	if caFile := os.Getenv("TLS_CA_FILE"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return ts, fmt.Errorf("failed to read TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return ts, fmt.Errorf("no certificates found in TLS CA file %q", caFile)
+		}
+		ts.client = &tls.Config{RootCAs: pool}
+	}
+
+	if ts.server == nil || ts.client == nil {
+		if os.Getenv("ENVIRONMENT") == "production" {
+			return ts, errors.New("refusing to run with insecure transport in production: set TLS_CERT_FILE, TLS_KEY_FILE and TLS_CA_FILE")
+		}
+		logger.Warn("TLS is not configured, serving and calling downstream services over insecure transport",
+			"server_tls", ts.server != nil, "client_tls", ts.client != nil)
+	}
+	return ts, nil
+}
+
+// tlsVersions are the protocol versions accepted by TLS_MIN_VERSION. Earlier
+// versions are considered weak and refused.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsPolicyFromEnv parses TLS_MIN_VERSION, 1.2 by default, and
+// TLS_CIPHER_SUITES, a comma-separated list of cipher suite names such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Without a list Go's defaults are
+// used. Cipher suites Go considers insecure are refused, and so is a list
+// combined with a minimum of 1.3, whose cipher suites cannot be configured.
+func tlsPolicyFromEnv() (uint16, []uint16, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if v := os.Getenv("TLS_MIN_VERSION"); v != "" {
+		var ok bool
+		if minVersion, ok = tlsVersions[strings.TrimPrefix(v, "TLS")]; !ok {
+			return 0, nil, fmt.Errorf("invalid TLS_MIN_VERSION %q: must be 1.2 or 1.3", v)
+		}
+	}
+
+	names := os.Getenv("TLS_CIPHER_SUITES")
+	if names == "" {
+		return minVersion, nil, nil
+	}
+	if minVersion == tls.VersionTLS13 {
+		return 0, nil, errors.New("TLS_CIPHER_SUITES cannot be set when TLS_MIN_VERSION is 1.3")
+	}
+	var suites []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(tls.CipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name })
+		if i < 0 {
+			if slices.ContainsFunc(tls.InsecureCipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name }) {
+				return 0, nil, fmt.Errorf("cipher suite %s in TLS_CIPHER_SUITES is insecure", name)
+			}
+			return 0, nil, fmt.Errorf("unknown cipher suite %q in TLS_CIPHER_SUITES", name)
+		}
+		suite := tls.CipherSuites()[i]
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return 0, nil, fmt.Errorf("cipher suite %s in TLS_CIPHER_SUITES is only used by TLS 1.3 and cannot be configured", name)
+		}
+		suites = append(suites, suite.ID)
+	}
+	return minVersion, suites, nil
+}
+
+// serverOptions returns the options securing the gRPC server.
+func (ts transportSecurity) serverOptions() []grpc.ServerOption {
+	if ts.server == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(ts.server))}
+}
+
+// clientCredentials returns the credentials for connections to downstream
+// services.
+func (ts transportSecurity) clientCredentials() credentials.TransportCredentials {
+	if ts.client == nil {
+		return insecure.NewCredentials()
+	}
+	return credentials.NewTLS(ts.client)
+}
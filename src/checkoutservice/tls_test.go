@@ -0,0 +1,181 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate and its key to dir.
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "checkoutservice"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		DNSNames:              []string{"checkoutservice"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTransportSecurityFromEnv(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+
+	t.Run("insecure outside production", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "development")
+		ts, err := transportSecurityFromEnv()
+		if err != nil {
+			t.Fatalf("transportSecurityFromEnv: %v", err)
+		}
+		if ts.serverOptions() != nil || ts.clientCredentials().Info().SecurityProtocol != "insecure" {
+			t.Errorf("transport without TLS settings is not plaintext")
+		}
+	})
+
+	t.Run("insecure refused in production", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "production")
+		if _, err := transportSecurityFromEnv(); err == nil {
+			t.Error("transportSecurityFromEnv allowed insecure transport in production")
+		}
+
+		t.Setenv("TLS_CA_FILE", certFile)
+		if _, err := transportSecurityFromEnv(); err == nil {
+			t.Error("transportSecurityFromEnv allowed an insecure server in production")
+		}
+	})
+
+	t.Run("TLS in production", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "production")
+		t.Setenv("TLS_CERT_FILE", certFile)
+		t.Setenv("TLS_KEY_FILE", keyFile)
+		t.Setenv("TLS_CA_FILE", certFile)
+		ts, err := transportSecurityFromEnv()
+		if err != nil {
+			t.Fatalf("transportSecurityFromEnv: %v", err)
+		}
+		if len(ts.serverOptions()) != 1 || ts.clientCredentials().Info().SecurityProtocol != "tls" {
+			t.Errorf("transport with TLS settings is not secured")
+		}
+	})
+
+	t.Run("unreadable certificate", func(t *testing.T) {
+		t.Setenv("TLS_CERT_FILE", filepath.Join(t.TempDir(), "missing.crt"))
+		t.Setenv("TLS_KEY_FILE", keyFile)
+		if _, err := transportSecurityFromEnv(); err == nil {
+			t.Error("transportSecurityFromEnv accepted a missing certificate")
+		}
+	})
+}
+
+func TestTLSPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		minVersion string
+		ciphers    string
+		ok         bool
+	}{
+		{"defaults", "", "", true},
+		{"TLS 1.3", "1.3", "", true},
+		{"TLS 1.2 with restricted ciphers", "TLS1.2", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", true},
+		{"weak version", "1.1", "", false},
+		{"unknown version", "2", "", false},
+		{"insecure cipher", "", "TLS_RSA_WITH_RC4_128_SHA", false},
+		{"unknown cipher", "", "TLS_NOPE", false},
+		{"TLS 1.3 cipher", "", "TLS_AES_128_GCM_SHA256", false},
+		{"ciphers with TLS 1.3", "1.3", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TLS_MIN_VERSION", tt.minVersion)
+			t.Setenv("TLS_CIPHER_SUITES", tt.ciphers)
+			if _, _, err := tlsPolicyFromEnv(); (err == nil) != tt.ok {
+				t.Errorf("tlsPolicyFromEnv() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersionNegotiated(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+	t.Setenv("TLS_CERT_FILE", certFile)
+	t.Setenv("TLS_KEY_FILE", keyFile)
+	t.Setenv("TLS_CA_FILE", certFile)
+
+	// handshake connects a client accepting versions up to maxVersion to the
+	// server and returns the negotiated version.
+	handshake := func(ts transportSecurity, maxVersion uint16) (uint16, error) {
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+		server := tls.Server(serverConn, ts.server)
+		go server.Handshake()
+
+		client := tls.Client(clientConn, &tls.Config{
+			RootCAs:    ts.client.RootCAs,
+			ServerName: "checkoutservice",
+			MinVersion: tls.VersionTLS10,
+			MaxVersion: maxVersion,
+		})
+		if err := client.Handshake(); err != nil {
+			return 0, err
+		}
+		return client.ConnectionState().Version, nil
+	}
+
+	for _, tt := range []struct {
+		minVersion string
+		want       uint16
+	}{
+		{"1.2", tls.VersionTLS12},
+		{"1.3", tls.VersionTLS13},
+	} {
+		t.Setenv("TLS_MIN_VERSION", tt.minVersion)
+		ts, err := transportSecurityFromEnv()
+		if err != nil {
+			t.Fatalf("transportSecurityFromEnv: %v", err)
+		}
+
+		if got, err := handshake(ts, tls.VersionTLS13); err != nil || got < tt.want {
+			t.Errorf("with TLS_MIN_VERSION=%s, negotiated version %x, %v, want at least %x", tt.minVersion, got, err, tt.want)
+		}
+		if _, err := handshake(ts, tt.want-1); err == nil {
+			t.Errorf("with TLS_MIN_VERSION=%s, a client capped below the minimum connected", tt.minVersion)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trackingIDPatternFromEnv compiles CHECKOUT_TRACKING_ID_PATTERN, a regular
+// expression every tracking ID must match in full, such as
+// "[0-9A-F]{8}-[0-9A-F]{4}". Any non-empty tracking ID is accepted when it is
+// not set.
+func trackingIDPatternFromEnv() (*regexp.Regexp, error) {
+	pattern := os.Getenv("CHECKOUT_TRACKING_ID_PATTERN")
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHECKOUT_TRACKING_ID_PATTERN: %w", err)
+	}
+	return re, nil
+}
+
+// checkTrackingID returns an Internal status when the shipping service
+// shipped an order without a tracking ID, or with one not matching
+// trackingIDPattern. The tracking ID is recorded on the span in ctx either
+// way.
+func (cs *checkoutService) checkTrackingID(ctx context.Context, trackingID string) error {
+	valid := trackingID != "" && (cs.trackingIDPattern == nil || cs.trackingIDPattern.MatchString(trackingID))
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("app.shipping.tracking.id", trackingID),
+		attribute.Bool("app.shipping.tracking.valid", valid),
+	)
+	switch {
+	case trackingID == "":
+		return status.Error(codes.Internal, "shipping service returned no tracking ID")
+	case !valid:
+		return status.Errorf(codes.Internal, "shipping service returned malformed tracking ID %q", trackingID)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	pb "github.com/open-telemetry/opentelemetry-demo/src/checkoutservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTrackingIDValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		trackingID string
+		wantCode   codes.Code
+	}{
+		{name: "valid", trackingID: "TRACK-42", wantCode: codes.OK},
+		{name: "empty", trackingID: "", wantCode: codes.Internal},
+		{name: "malformed", trackingID: "track 42; drop", wantCode: codes.Internal},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestCheckout(t)
+			tc.svc.trackingIDPattern = regexp.MustCompile(`^(?:TRACK-[0-9]+)$`)
+			tc.shipping.shipResp = &pb.ShipOrderResponse{TrackingId: tt.trackingID}
+
+			resp, err := tc.svc.PlaceOrder(context.Background(), testPlaceOrderRequest())
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("PlaceOrder() code = %v, want %v (error %v)", got, tt.wantCode, err)
+			}
+			if tt.wantCode == codes.OK {
+				if got := resp.GetOrder().GetShippingTrackingId(); got != tt.trackingID {
+					t.Errorf("order tracking ID = %q, want %q", got, tt.trackingID)
+				}
+				return
+			}
+			if len(tc.payment.refunds) != 1 {
+				t.Errorf("refunded %d times, want the charge refunded once", len(tc.payment.refunds))
+			}
+		})
+	}
+}
+
+func TestTrackingIDPatternFromEnv(t *testing.T) {
+	t.Setenv("CHECKOUT_TRACKING_ID_PATTERN", "[A-Z]{2}[0-9]+")
+	re, err := trackingIDPatternFromEnv()
+	if err != nil {
+		t.Fatalf("trackingIDPatternFromEnv: %v", err)
+	}
+	if !re.MatchString("UP123") || re.MatchString("xUP123") {
+		t.Errorf("pattern %s does not match tracking IDs in full", re)
+	}
+
+	t.Setenv("CHECKOUT_TRACKING_ID_PATTERN", "[A-Z")
+	if _, err := trackingIDPatternFromEnv(); err == nil {
+		t.Errorf("trackingIDPatternFromEnv() accepted an invalid pattern")
+	}
+}
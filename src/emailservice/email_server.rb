@@ -40,7 +40,7 @@ def send_email(data)
       to:       data.email,
       from:     "noreply@example.com",
       subject:  "Your confirmation email",
-      body:     erb(:confirmation, locals: { order: data.order }),
+      body:     erb(:confirmation, locals: { summary: data.summary }),
       via:      :test
     )
     span.set_attribute("app.email.recipient", data.email)
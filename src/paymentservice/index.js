@@ -31,6 +31,21 @@ async function chargeServiceHandler(call, callback) {
   }
 }
 
+async function refundServiceHandler(call, callback) {
+  const span = opentelemetry.trace.getActiveSpan();
+
+  const transactionId = call.request.transaction_id
+  if (!transactionId) {
+    return callback({ code: grpc.status.INVALID_ARGUMENT, message: 'transaction_id is required' })
+  }
+
+  span.setAttributes({
+    'app.payment.transaction.id': transactionId
+  })
+  logger.info({ request: call.request }, "Refund request received.")
+  callback(null, {})
+}
+
 async function closeGracefully(signal) {
   server.forceShutdown()
   process.kill(process.pid, signal)
@@ -43,7 +58,7 @@ server.addService(health.service, new health.Implementation({
   '': health.servingStatus.SERVING
 }))
 
-server.addService(otelDemoPackage.oteldemo.PaymentService.service, { charge: chargeServiceHandler })
+server.addService(otelDemoPackage.oteldemo.PaymentService.service, { charge: chargeServiceHandler, refund: refundServiceHandler })
 
 server.bindAsync(`0.0.0.0:${process.env['PAYMENT_SERVICE_PORT']}`, grpc.ServerCredentials.createInsecure(), (err, port) => {
   if (err) {
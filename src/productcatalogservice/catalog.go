@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"sync"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+// catalogStore holds the in-memory catalog served by a productCatalog. The
+// product slice is never modified once installed, so readers may keep using a
+// snapshot after releasing the lock.
+type catalogStore struct {
+	mu       sync.RWMutex
+	products []*pb.Product
+	index    map[string]int
+	tags     map[string][]int
+	featured []int
+	version  uint64
+	// imported are the products merged in by ImportProducts, which reload
+	// applies again on top of the product files.
+	imported []*pb.Product
+}
+
+func newCatalogStore(products []*pb.Product) *catalogStore {
+	c := &catalogStore{}
+	c.set(products)
+	return c
+}
+
+// set replaces the catalog, imported products included.
+func (c *catalogStore) set(products []*pb.Product) {
+	index, tags, featured := indexProducts(products), indexTags(products), indexFeatured(products)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products, c.index, c.tags, c.featured = products, index, tags, featured
+	c.imported = nil
+	c.version++
+}
+
+// reload replaces the catalog with products read from the product files,
+// keeping the imported products, which replace file products with the same ID.
+func (c *catalogStore) reload(products []*pb.Product) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	merged, _, _ := mergeProducts(products, indexProducts(products), c.imported)
+	c.products, c.index, c.tags, c.featured = merged, indexProducts(merged), indexTags(merged), indexFeatured(merged)
+	c.version++
+}
+
+// merge adds or replaces products by ID and installs the result as the new
+// catalog, returning how many products were added and updated. The products
+// are remembered as imported, so they survive a reload.
+func (c *catalogStore) merge(products []*pb.Product) (added, updated int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	merged, added, updated := mergeProducts(c.products, c.index, products)
+	c.imported, _, _ = mergeProducts(c.imported, indexProducts(c.imported), products)
+	c.products, c.index, c.tags, c.featured = merged, indexProducts(merged), indexTags(merged), indexFeatured(merged)
+	c.version++
+	return added, updated
+}
+
+// mergeProducts returns a copy of base, indexed by index, with products added
+// or replacing the base product with the same ID. The IDs of products must be
+// unique.
+func mergeProducts(base []*pb.Product, index map[string]int, products []*pb.Product) (merged []*pb.Product, added, updated int32) {
+	merged = make([]*pb.Product, len(base), len(base)+len(products))
+	copy(merged, base)
+	for _, product := range products {
+		if i, ok := index[product.Id]; ok {
+			merged[i] = product
+			updated++
+		} else {
+			merged = append(merged, product)
+			added++
+		}
+	}
+	return merged, added, updated
+}
+
+// current returns a snapshot of the catalog.
+func (c *catalogStore) current() []*pb.Product {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.products
+}
+
+// snapshot returns the catalog with its index and version. The version
+// changes every time the catalog does.
+func (c *catalogStore) snapshot() ([]*pb.Product, map[string]int, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.products, c.index, c.version
+}
+
+// tagged returns the products carrying tag, in catalog order, and the version
+// of the catalog they were taken from. Tags are matched ignoring case.
+func (c *catalogStore) tagged(tag string) ([]*pb.Product, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	positions := c.tags[normalizeQuery(tag)]
+	products := make([]*pb.Product, len(positions))
+	for i, pos := range positions {
+		products[i] = c.products[pos]
+	}
+	return products, c.version
+}
+
+// featuredProducts returns the featured products, in catalog order.
+func (c *catalogStore) featuredProducts() []*pb.Product {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	products := make([]*pb.Product, len(c.featured))
+	for i, pos := range c.featured {
+		products[i] = c.products[pos]
+	}
+	return products
+}
+
+// indexProducts maps each product ID to its position in products.
+func indexProducts(products []*pb.Product) map[string]int {
+	index := make(map[string]int, len(products))
+	for i, p := range products {
+		index[p.Id] = i
+	}
+	return index
+}
+
+// indexTags maps each normalized tag to the positions of the products carrying
+// it, in ascending order.
+func indexTags(products []*pb.Product) map[string][]int {
+	tags := make(map[string][]int)
+	for i, p := range products {
+		for _, tag := range p.Tags {
+			tag = normalizeQuery(tag)
+			if positions := tags[tag]; tag != "" && (len(positions) == 0 || positions[len(positions)-1] != i) {
+				tags[tag] = append(positions, i)
+			}
+		}
+	}
+	return tags
+}
+
+// indexFeatured returns the positions of the featured products, in ascending
+// order.
+func indexFeatured(products []*pb.Product) []int {
+	var featured []int
+	for i, p := range products {
+		if p.Featured {
+			featured = append(featured, i)
+		}
+	}
+	return featured
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sort"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/proto"
+)
+
+// catalogDiff lists the IDs of the products that changed between two
+// versions of the catalog, each sorted.
+type catalogDiff struct {
+	added   []string
+	removed []string
+	updated []string
+}
+
+// diffCatalogs compares two versions of the catalog, each with the index
+// mapping its product IDs to their positions.
+func diffCatalogs(oldProducts []*pb.Product, oldIndex map[string]int, newProducts []*pb.Product, newIndex map[string]int) catalogDiff {
+	var diff catalogDiff
+	for _, product := range newProducts {
+		i, ok := oldIndex[product.Id]
+		switch {
+		case !ok:
+			diff.added = append(diff.added, product.Id)
+		case !proto.Equal(oldProducts[i], product):
+			diff.updated = append(diff.updated, product.Id)
+		}
+	}
+	for _, product := range oldProducts {
+		if _, ok := newIndex[product.Id]; !ok {
+			diff.removed = append(diff.removed, product.Id)
+		}
+	}
+	sort.Strings(diff.added)
+	sort.Strings(diff.removed)
+	sort.Strings(diff.updated)
+	return diff
+}
+
+// reloadMetrics counts the products added, removed and updated by catalog
+// reloads. A nil *reloadMetrics counts nothing.
+type reloadMetrics struct {
+	added   metric.Int64Counter
+	removed metric.Int64Counter
+	updated metric.Int64Counter
+}
+
+func newReloadMetrics(meter metric.Meter) (*reloadMetrics, error) {
+	var m reloadMetrics
+	var err error
+	if m.added, err = meter.Int64Counter("productcatalog.reload.added",
+		metric.WithDescription("The number of products added by catalog reloads"),
+		metric.WithUnit("1")); err != nil {
+		return nil, err
+	}
+	if m.removed, err = meter.Int64Counter("productcatalog.reload.removed",
+		metric.WithDescription("The number of products removed by catalog reloads"),
+		metric.WithUnit("1")); err != nil {
+		return nil, err
+	}
+	if m.updated, err = meter.Int64Counter("productcatalog.reload.updated",
+		metric.WithDescription("The number of products changed by catalog reloads"),
+		metric.WithUnit("1")); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *reloadMetrics) record(ctx context.Context, diff catalogDiff) {
+	if m == nil {
+		return
+	}
+	m.added.Add(ctx, int64(len(diff.added)))
+	m.removed.Add(ctx, int64(len(diff.removed)))
+	m.updated.Add(ctx, int64(len(diff.updated)))
+}
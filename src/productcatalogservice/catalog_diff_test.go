@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestDiffCatalogs(t *testing.T) {
+	before := newCatalogStore([]*pb.Product{
+		{Id: "A", Name: "Telescope"},
+		{Id: "B", Name: "Tripod"},
+		{Id: "C", Name: "Lens"},
+	})
+	after := newCatalogStore([]*pb.Product{
+		{Id: "E", Name: "Eyepiece"},
+		{Id: "A", Name: "Telescope"},
+		{Id: "C", Name: "Lens", PriceUsd: usd(5, 0)},
+		{Id: "D", Name: "Star Chart"},
+	})
+	oldProducts, oldIndex, _ := before.snapshot()
+	newProducts, newIndex, _ := after.snapshot()
+
+	diff := diffCatalogs(oldProducts, oldIndex, newProducts, newIndex)
+	if want := []string{"D", "E"}; !slices.Equal(diff.added, want) {
+		t.Errorf("added = %v, want %v", diff.added, want)
+	}
+	if want := []string{"B"}; !slices.Equal(diff.removed, want) {
+		t.Errorf("removed = %v, want %v", diff.removed, want)
+	}
+	if want := []string{"C"}; !slices.Equal(diff.updated, want) {
+		t.Errorf("updated = %v, want %v", diff.updated, want)
+	}
+}
+
+func TestCatalogReloaderRecordsDiff(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	metrics, err := newReloadMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("productcatalogservice"))
+	if err != nil {
+		t.Fatalf("newReloadMetrics: %v", err)
+	}
+
+	catalog := newCatalogStore([]*pb.Product{{Id: "A"}, {Id: "B"}})
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}, {"id": "B"}]`)
+	r := newCatalogReloader(catalog, dir, time.Hour)
+	r.metrics = metrics
+
+	writeProductFile(t, dir, "products.json", `[{"id": "A", "name": "Telescope"}, {"id": "C"}, {"id": "D"}]`)
+	if swapped, err := r.reloadIfChanged(); !swapped || err != nil {
+		t.Fatalf("reloadIfChanged() = %v, %v, want the catalog swapped", swapped, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				got[m.Name] += dp.Value
+			}
+		}
+	}
+	want := map[string]int64{"productcatalog.reload.added": 2, "productcatalog.reload.removed": 1, "productcatalog.reload.updated": 1}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("%s = %d, want %d", name, got[name], n)
+		}
+	}
+}
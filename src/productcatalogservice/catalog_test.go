@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestCatalogStore(t *testing.T) {
+	c := newCatalogStore([]*pb.Product{{Id: "A", Name: "Old A"}})
+	_, _, v1 := c.snapshot()
+
+	added, updated := c.merge([]*pb.Product{{Id: "A", Name: "New A"}, {Id: "B"}})
+	if added != 1 || updated != 1 {
+		t.Errorf("merge() = %d added, %d updated, want 1 and 1", added, updated)
+	}
+	products, index, v2 := c.snapshot()
+	if v2 <= v1 {
+		t.Errorf("version after merge = %d, want it past %d", v2, v1)
+	}
+	if len(products) != 2 || products[index["A"]].Name != "New A" || products[index["B"]].Id != "B" {
+		t.Errorf("catalog after merge = %v", products)
+	}
+
+	c.set(nil)
+	if got, _, v3 := c.snapshot(); len(got) != 0 || v3 <= v2 {
+		t.Errorf("catalog after set(nil) = %v at version %d", got, v3)
+	}
+}
+
+func TestCatalogStoreTags(t *testing.T) {
+	c := newCatalogStore([]*pb.Product{
+		{Id: "A", Tags: []string{"Solar", "solar"}},
+		{Id: "B", Tags: []string{"lunar"}},
+		{Id: "C", Tags: []string{" solar "}},
+	})
+	ids := func(products []*pb.Product) []string {
+		var ids []string
+		for _, p := range products {
+			ids = append(ids, p.Id)
+		}
+		return ids
+	}
+
+	if got, _ := c.tagged("SOLAR"); !slices.Equal(ids(got), []string{"A", "C"}) {
+		t.Errorf("tagged(SOLAR) = %v, want [A C]", ids(got))
+	}
+	c.merge([]*pb.Product{{Id: "B", Tags: []string{"solar"}}})
+	if got, _ := c.tagged("solar"); !slices.Equal(ids(got), []string{"A", "B", "C"}) {
+		t.Errorf("tagged(solar) after merge = %v, want [A B C]", ids(got))
+	}
+	if got, _ := c.tagged("lunar"); len(got) != 0 {
+		t.Errorf("tagged(lunar) after merge = %v, want none", ids(got))
+	}
+}
+
+func TestHandlersUseInjectedCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog []*pb.Product
+		query   string
+		want    []string
+	}{
+		{"empty catalog", nil, "telescope", nil},
+		{"single match", []*pb.Product{{Id: "1", Name: "Telescope"}, {Id: "2", Name: "Tripod"}}, "telescope", []string{"1"}},
+		{"several matches", []*pb.Product{{Id: "1", Name: "Telescope"}, {Id: "2", Name: "Solar telescope"}}, "telescope", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &productCatalog{catalog: newCatalogStore(tt.catalog)}
+
+			resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: tt.query})
+			if err != nil {
+				t.Fatalf("SearchProducts: %v", err)
+			}
+			var got []string
+			for _, p := range resp.Results {
+				got = append(got, p.Id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchProducts(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// services with their own catalogs do not see each other's products
+	a := &productCatalog{catalog: newCatalogStore([]*pb.Product{{Id: "A", Categories: []string{"x"}}, {Id: "A2", Categories: []string{"x"}}})}
+	b := &productCatalog{catalog: newCatalogStore([]*pb.Product{{Id: "B", Categories: []string{"x"}}, {Id: "B2", Categories: []string{"x"}}})}
+	resp, err := a.GetCartRecommendations(context.Background(), &pb.GetCartRecommendationsRequest{ProductIds: []string{"A"}})
+	if err != nil {
+		t.Fatalf("GetCartRecommendations: %v", err)
+	}
+	if len(resp.Products) != 1 || resp.Products[0].Id != "A2" {
+		t.Errorf("recommendations from catalog a = %v, want [A2]", resp.Products)
+	}
+	if got := b.catalog.current(); len(got) != 2 || got[0].Id != "B" {
+		t.Errorf("catalog b = %v, want it untouched", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/protobuf/proto"
+)
+
+// truncateDescription shortens descriptions longer than maxDescriptionLen
+// characters for list responses, marking the cut with an ellipsis. Callers
+// that need the full text use GetProduct. Descriptions are never truncated
+// when maxDescriptionLen is 0.
+func (p *productCatalog) truncateDescription(description string) string {
+	limit := p.maxDescriptionLen
+	if limit <= 0 || utf8.RuneCountInString(description) <= limit {
+		return description
+	}
+	runes := []rune(description)
+	return strings.TrimRightFunc(string(runes[:limit]), unicode.IsSpace) + "…"
+}
+
+// listed returns products as they appear in list responses, cloning those
+// whose description must be sanitized or truncated so the catalog keeps the
+// full text.
+func (p *productCatalog) listed(products []*pb.Product) []*pb.Product {
+	if p.maxDescriptionLen <= 0 && !p.sanitizeHTML {
+		return products
+	}
+	return withDescriptions(products, p.listedDescription)
+}
+
+// sanitized returns products with their descriptions sanitized, for responses
+// that carry the full text.
+func (p *productCatalog) sanitized(products []*pb.Product) []*pb.Product {
+	if !p.sanitizeHTML {
+		return products
+	}
+	return withDescriptions(products, p.sanitizeDescription)
+}
+
+// withDescriptions returns products with describe applied to their
+// descriptions, cloning the products whose description changes.
+func withDescriptions(products []*pb.Product, describe func(string) string) []*pb.Product {
+	out := make([]*pb.Product, len(products))
+	for i, product := range products {
+		out[i] = product
+		if shown := describe(product.Description); shown != product.Description {
+			out[i] = proto.Clone(product).(*pb.Product)
+			out[i].Description = shown
+		}
+	}
+	return out
+}
+
+// listedDescription returns description as shown in list responses,
+// sanitized before it is truncated so that no tag is cut in half.
+func (p *productCatalog) listedDescription(description string) string {
+	return p.truncateDescription(p.sanitizeDescription(description))
+}
+
+// sanitizeHTMLFromEnv reads CATALOG_SANITIZE_HTML, which strips markup from
+// the descriptions in responses.
+func sanitizeHTMLFromEnv() bool {
+	sanitize, _ := strconv.ParseBool(os.Getenv("CATALOG_SANITIZE_HTML"))
+	return sanitize
+}
+
+var (
+	// scriptElement matches script and style elements along with their
+	// content, which is never meant to be shown.
+	scriptElement = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	// htmlTag matches opening and closing tags and comments.
+	htmlTag = regexp.MustCompile(`(?s)<(?:/?[a-zA-Z][^>]*|!--.*?--)>`)
+)
+
+// sanitizeDescription strips the HTML tags in description and escapes any
+// angle bracket left, when CATALOG_SANITIZE_HTML is set, so a frontend
+// rendering it as HTML shows plain text. The stored catalog is left raw.
+func (p *productCatalog) sanitizeDescription(description string) string {
+	if !p.sanitizeHTML || !strings.ContainsAny(description, "<>") {
+		return description
+	}
+	text := scriptElement.ReplaceAllString(description, "")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(text)
+	return strings.TrimSpace(text)
+}
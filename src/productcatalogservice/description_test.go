@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestTruncateDescription(t *testing.T) {
+	svc := &productCatalog{maxDescriptionLen: 10}
+	tests := map[string]string{
+		"":                           "",
+		"Short one.":                 "Short one.",
+		"A very large telescope":     "A very lar…",
+		"Telescope with a tripod":    "Telescope…",
+		"Téléscope à grand champ !!": "Téléscope…",
+	}
+	for in, want := range tests {
+		if got := svc.truncateDescription(in); got != want {
+			t.Errorf("truncateDescription(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	unlimited := &productCatalog{}
+	if got := unlimited.truncateDescription(tests["A very large telescope"]); got != tests["A very large telescope"] {
+		t.Errorf("truncateDescription without a limit = %q", got)
+	}
+}
+
+func TestSearchProductsTruncatesDescriptions(t *testing.T) {
+	full := "A telescope with a very long description that goes on and on"
+	catalog := newCatalogStore([]*pb.Product{{Id: "1", Name: "Telescope", Description: full}})
+	svc := &productCatalog{catalog: catalog, maxDescriptionLen: 20}
+
+	resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope"})
+	if err != nil {
+		t.Fatalf("SearchProducts: %v", err)
+	}
+	if got := resp.Results[0].Description; got != "A telescope with a v…" {
+		t.Errorf("listed description = %q, want it truncated", got)
+	}
+	if got := catalog.current()[0].Description; got != full {
+		t.Errorf("catalog description = %q, want the full text kept for GetProduct", got)
+	}
+}
+
+func TestSanitizeDescription(t *testing.T) {
+	svc := &productCatalog{sanitizeHTML: true}
+	tests := map[string]string{
+		"Plain telescope.":                                          "Plain telescope.",
+		"A <b>bright</b> lens":                                      "A bright lens",
+		"Sharp<script>alert('x')</script> optics":                   "Sharp optics",
+		"<p onclick=\"steal()\">Tripod</p><!-- hidden --> included": "Tripod included",
+		"Magnification < 100x and > 10x":                            "Magnification &lt; 100x and &gt; 10x",
+	}
+	for in, want := range tests {
+		if got := svc.sanitizeDescription(in); got != want {
+			t.Errorf("sanitizeDescription(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	raw := &productCatalog{}
+	if got := raw.sanitizeDescription("A <b>bright</b> lens"); got != "A <b>bright</b> lens" {
+		t.Errorf("sanitizeDescription without CATALOG_SANITIZE_HTML = %q", got)
+	}
+}
+
+func TestSanitizedResponses(t *testing.T) {
+	products := []*pb.Product{
+		{Id: "A", Name: "Telescope", Description: "A <em>great</em> telescope<script>alert(1)</script>"},
+		{Id: "B", Name: "Lens", Description: "A lens"},
+	}
+
+	for _, sanitize := range []bool{true, false} {
+		svc := &productCatalog{catalog: newCatalogStore(products), sanitizeHTML: sanitize}
+		resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope"})
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		want := products[0].Description
+		if sanitize {
+			want = "A great telescope"
+		}
+		if got := resp.Results[0].Description; got != want {
+			t.Errorf("description with sanitizing %t = %q, want %q", sanitize, got, want)
+		}
+	}
+	if products[0].Description != "A <em>great</em> telescope<script>alert(1)</script>" {
+		t.Errorf("stored description changed to %q", products[0].Description)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// eventSampler decides which informational span events, such as "Product
+// Found", are recorded. They are noise at high request rates, so they are off
+// unless enabled. Error events are always recorded and do not go through the
+// sampler. A nil *eventSampler records nothing.
+type eventSampler struct {
+	rate  float64
+	float func() float64
+}
+
+// productEventsFromEnv reads CATALOG_PRODUCT_EVENTS, which is "on", "off" or
+// the fraction of calls to record events for, such as 0.01. Events are off
+// when it is unset or invalid.
+func productEventsFromEnv() *eventSampler {
+	s := os.Getenv("CATALOG_PRODUCT_EVENTS")
+	var rate float64
+	switch s {
+	case "", "off", "false":
+		return nil
+	case "on", "true":
+		rate = 1
+	default:
+		var err error
+		rate, err = strconv.ParseFloat(s, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			logger.Warn("Ignoring invalid environment variable", "key", "CATALOG_PRODUCT_EVENTS", "value", s)
+			return nil
+		}
+	}
+	return newEventSampler(rate)
+}
+
+func newEventSampler(rate float64) *eventSampler {
+	if rate <= 0 {
+		return nil
+	}
+	return &eventSampler{rate: rate, float: rand.Float64}
+}
+
+// addEvent adds the named event to span if the call is sampled.
+func (s *eventSampler) addEvent(span trace.Span, name string, options ...trace.EventOption) {
+	if s == nil || (s.rate < 1 && s.float() >= s.rate) {
+		return
+	}
+	span.AddEvent(name, options...)
+}
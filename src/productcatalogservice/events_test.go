@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// countEvents records a span on which s adds n events and returns how many
+// the span kept.
+func countEvents(s *eventSampler, n int) int {
+	recorder := tracetest.NewSpanRecorder()
+	_, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "GetProduct")
+	for range n {
+		s.addEvent(span, "Product Found")
+	}
+	span.End()
+	return len(recorder.Ended()[0].Events())
+}
+
+func TestProductEventsFromEnv(t *testing.T) {
+	for value, want := range map[string]int{"": 0, "off": 0, "false": 0, "0": 0, "bogus": 0, "2": 0, "on": 10, "true": 10, "1": 10} {
+		t.Setenv("CATALOG_PRODUCT_EVENTS", value)
+		if got := countEvents(productEventsFromEnv(), 10); got != want {
+			t.Errorf("with CATALOG_PRODUCT_EVENTS=%q, %d of 10 events recorded, want %d", value, got, want)
+		}
+	}
+}
+
+func TestEventSamplerRate(t *testing.T) {
+	draws := []float64{0.05, 0.5, 0.09, 0.95}
+	s := newEventSampler(0.1)
+	s.float = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+	if got := countEvents(s, 4); got != 2 {
+		t.Errorf("%d of 4 events recorded at a rate of 0.1, want the 2 sampled ones", got)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetFeaturedProducts returns the products curated for the homepage, in
+// catalog order or shuffled with the requested seed. The featured subset is
+// indexed with the catalog, so it is only recomputed when the catalog is
+// reloaded.
+func (p *productCatalog) GetFeaturedProducts(ctx context.Context, req *pb.GetFeaturedProductsRequest) (*pb.GetFeaturedProductsResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if req.GetLimit() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid limit %d", req.GetLimit())
+	}
+	pg, err := p.pages.parsePage(req.GetLimit(), "")
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := p.tenantCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		if err := p.checkLoaded(); err != nil {
+			span.AddEvent("catalog not loaded")
+			return nil, err
+		}
+		catalog = p.catalog
+	}
+
+	featured := selectFeatured(catalog.featuredProducts(), req.GetShuffleSeed(), pg.size)
+	span.SetAttributes(
+		attribute.Int("app.products_featured.limit", pg.size),
+		attribute.Bool("app.products_featured.shuffled", req.GetShuffleSeed() != 0),
+		attribute.Int("app.products_featured.count", len(featured)),
+	)
+	return &pb.GetFeaturedProductsResponse{Products: p.listed(featured)}, nil
+}
+
+// selectFeatured returns at most limit of the featured products, shuffled with
+// seed unless it is zero. The same seed always selects the same products in
+// the same order for a given catalog.
+func selectFeatured(featured []*pb.Product, seed int64, limit int) []*pb.Product {
+	if seed != 0 {
+		featured = slices.Clone(featured)
+		rand.New(rand.NewSource(seed)).Shuffle(len(featured), func(i, j int) {
+			featured[i], featured[j] = featured[j], featured[i]
+		})
+	}
+	return featured[:min(limit, len(featured))]
+}
@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func featuredIDs(t *testing.T, svc *productCatalog, req *pb.GetFeaturedProductsRequest) []string {
+	t.Helper()
+	resp, err := svc.GetFeaturedProducts(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetFeaturedProducts(%v): %v", req, err)
+	}
+	ids := make([]string, len(resp.Products))
+	for i, product := range resp.Products {
+		ids[i] = product.Id
+	}
+	return ids
+}
+
+func TestGetFeaturedProducts(t *testing.T) {
+	svc := &productCatalog{catalog: newCatalogStore([]*pb.Product{
+		{Id: "A", Featured: true},
+		{Id: "B"},
+		{Id: "C", Featured: true},
+		{Id: "D", Featured: true},
+		{Id: "E"},
+	})}
+
+	if got := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{}); !slices.Equal(got, []string{"A", "C", "D"}) {
+		t.Errorf("featured products = %v, want A, C and D in catalog order", got)
+	}
+
+	shuffled := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{ShuffleSeed: 42})
+	sorted := slices.Clone(shuffled)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, []string{"A", "C", "D"}) {
+		t.Errorf("shuffled featured products = %v, want A, C and D", shuffled)
+	}
+	if again := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{ShuffleSeed: 42}); !slices.Equal(again, shuffled) {
+		t.Errorf("featured products shuffled with the same seed = %v, then %v", shuffled, again)
+	}
+
+	// a reload replaces the featured subset
+	svc.catalog.set([]*pb.Product{{Id: "A"}, {Id: "B", Featured: true}})
+	if got := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{}); !slices.Equal(got, []string{"B"}) {
+		t.Errorf("featured products after reload = %v, want B", got)
+	}
+}
+
+func TestGetFeaturedProductsLimit(t *testing.T) {
+	var products []*pb.Product
+	for _, id := range []string{"A", "B", "C", "D", "E"} {
+		products = append(products, &pb.Product{Id: id, Featured: true})
+	}
+	svc := &productCatalog{
+		catalog: newCatalogStore(products),
+		pages:   pageLimits{defaultSize: 2, maxSize: 4},
+	}
+
+	for _, tt := range []struct {
+		limit int32
+		want  []string
+	}{
+		{limit: 0, want: []string{"A", "B"}},
+		{limit: 3, want: []string{"A", "B", "C"}},
+		{limit: 10, want: []string{"A", "B", "C", "D"}},
+	} {
+		if got := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{Limit: tt.limit}); !slices.Equal(got, tt.want) {
+			t.Errorf("featured products with limit %d = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+	if got := featuredIDs(t, svc, &pb.GetFeaturedProductsRequest{Limit: 3, ShuffleSeed: 7}); len(got) != 3 {
+		t.Errorf("shuffled featured products with limit 3 = %v, want 3 products", got)
+	}
+
+	if _, err := svc.GetFeaturedProducts(context.Background(), &pb.GetFeaturedProductsRequest{Limit: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetFeaturedProducts() with a negative limit error = %v, want InvalidArgument", err)
+	}
+}
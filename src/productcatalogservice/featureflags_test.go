@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFlagAttributesHook(t *testing.T) {
+	openfeature.AddHooks(flagAttributesHook{})
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("productcatalogservice")
+
+	// without a provider, flags resolve to their default values
+	ctx, span := tracer.Start(context.Background(), "GetProduct")
+	client := openfeature.NewClient("productCatalog")
+	_, _ = client.BooleanValue(ctx, "productCatalogFailure", true, openfeature.EvaluationContext{})
+	_, _ = client.FloatValue(ctx, "productCatalogTimeoutFailure", 0.25, openfeature.EvaluationContext{})
+	_, _ = client.StringValue(ctx, "productCatalogSlowProducts", "OLJCESPC7Z=500ms", openfeature.EvaluationContext{})
+	span.End()
+
+	attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		"app.featureflag.productCatalogFailure":        attribute.BoolValue(true),
+		"app.featureflag.productCatalogTimeoutFailure": attribute.Float64Value(0.25),
+		"app.featureflag.productCatalogSlowProducts":   attribute.StringValue("OLJCESPC7Z=500ms"),
+	} {
+		if got, ok := attrs.Value(key); !ok || got != want {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+}
+
+// failingProvider is a flag provider that fails every evaluation, as flagd
+// does while it is unreachable.
+type failingProvider struct {
+	openfeature.NoopProvider
+}
+
+func (failingProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	return openfeature.BoolResolutionDetail{
+		Value: defaultValue,
+		ProviderResolutionDetail: openfeature.ProviderResolutionDetail{
+			ResolutionError: openfeature.NewGeneralResolutionError("flagd unreachable"),
+		},
+	}
+}
+
+func TestFlagErrorsHook(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	hook, err := newFlagErrorsHook(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("productcatalogservice"), "productcatalogservice")
+	if err != nil {
+		t.Fatalf("newFlagErrorsHook: %v", err)
+	}
+	openfeature.AddHooks(hook)
+	if err := openfeature.SetNamedProviderAndWait("productCatalog", failingProvider{}); err != nil {
+		t.Fatalf("SetNamedProviderAndWait: %v", err)
+	}
+	t.Cleanup(func() { _ = openfeature.SetNamedProviderAndWait("productCatalog", openfeature.NoopProvider{}) })
+
+	p := &productCatalog{}
+	p.checkProductFailure(context.Background(), "OLJCESPC7Z")
+	p.checkProductFailure(context.Background(), "OLJCESPC7Z")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var got int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "featureflag.eval_errors" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				flag, _ := dp.Attributes.Value("feature_flag.key")
+				service, _ := dp.Attributes.Value("service")
+				if flag.AsString() == "productCatalogFailure" && service.AsString() == "productcatalogservice" {
+					got += dp.Value
+				}
+			}
+		}
+	}
+	if got != 2 {
+		t.Errorf("featureflag.eval_errors = %d, want 2", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"os"
+	"strings"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+// imageBaseFromEnv returns CATALOG_IMAGE_BASE_URL, the prefix of relative
+// image paths in responses, with a trailing slash. Paths are returned as
+// stored when it is unset.
+func imageBaseFromEnv() string {
+	base := os.Getenv("CATALOG_IMAGE_BASE_URL")
+	if base == "" || strings.HasSuffix(base, "/") {
+		return base
+	}
+	return base + "/"
+}
+
+// imageURL resolves path against the image base. Absolute URLs and
+// root-relative paths are returned as is.
+func (p *productCatalog) imageURL(path string) string {
+	if p.imageBase == "" || path == "" || strings.HasPrefix(path, "/") || strings.Contains(path, "://") {
+		return path
+	}
+	return p.imageBase + path
+}
+
+// applyImageSize sets the picture of product to its size variant, keeping the
+// default picture when size is empty or the product has no such variant, and
+// resolves every image path of the product the same way.
+func (p *productCatalog) applyImageSize(product *pb.Product, size string) {
+	if variant, ok := product.Pictures[size]; ok && size != "" && variant != "" {
+		product.Picture = variant
+	}
+	product.Picture = p.imageURL(product.Picture)
+	for name, path := range product.Pictures {
+		product.Pictures[name] = p.imageURL(path)
+	}
+}
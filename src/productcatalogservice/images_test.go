@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func telescopeWithVariants() *pb.Product {
+	return &pb.Product{
+		Id:      "OLJCESPC7Z",
+		Picture: "NationalParkFoundationExplorascope.jpg",
+		Pictures: map[string]string{
+			"thumbnail": "thumbnails/NationalParkFoundationExplorascope.jpg",
+			"full":      "https://cdn.example.com/NationalParkFoundationExplorascope.jpg",
+		},
+	}
+}
+
+func TestApplyImageSize(t *testing.T) {
+	p := &productCatalog{}
+	for size, want := range map[string]string{
+		"thumbnail": "thumbnails/NationalParkFoundationExplorascope.jpg",
+		"full":      "https://cdn.example.com/NationalParkFoundationExplorascope.jpg",
+		"":          "NationalParkFoundationExplorascope.jpg",
+		"poster":    "NationalParkFoundationExplorascope.jpg",
+	} {
+		product := telescopeWithVariants()
+		p.applyImageSize(product, size)
+		if product.Picture != want {
+			t.Errorf("picture for size %q = %q, want %q", size, product.Picture, want)
+		}
+	}
+
+	product := &pb.Product{Picture: "RedFlashlight.jpg"}
+	p.applyImageSize(product, "thumbnail")
+	if product.Picture != "RedFlashlight.jpg" {
+		t.Errorf("picture without variants = %q, want the default", product.Picture)
+	}
+}
+
+func TestApplyImageSizeResolvesRelativePaths(t *testing.T) {
+	t.Setenv("CATALOG_IMAGE_BASE_URL", "/images/products")
+	p := &productCatalog{imageBase: imageBaseFromEnv()}
+
+	product := telescopeWithVariants()
+	p.applyImageSize(product, "thumbnail")
+	if want := "/images/products/thumbnails/NationalParkFoundationExplorascope.jpg"; product.Picture != want {
+		t.Errorf("picture = %q, want %q", product.Picture, want)
+	}
+	if want := "/images/products/thumbnails/NationalParkFoundationExplorascope.jpg"; product.Pictures["thumbnail"] != want {
+		t.Errorf("thumbnail variant = %q, want %q", product.Pictures["thumbnail"], want)
+	}
+	if want := "https://cdn.example.com/NationalParkFoundationExplorascope.jpg"; product.Pictures["full"] != want {
+		t.Errorf("absolute variant = %q, want it unchanged", product.Pictures["full"])
+	}
+
+	product = telescopeWithVariants()
+	p.applyImageSize(product, "poster")
+	if want := "/images/products/NationalParkFoundationExplorascope.jpg"; product.Picture != want {
+		t.Errorf("fallback picture = %q, want %q", product.Picture, want)
+	}
+}
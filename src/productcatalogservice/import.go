@@ -0,0 +1,191 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ImportProducts merges a stream of products into the catalog. Every product
+// is validated first; in strict mode (the default, see lenientImportFromEnv) a
+// single rejected product rolls back the whole import, otherwise only the
+// valid products are applied.
+func (p *productCatalog) ImportProducts(stream grpc.ClientStreamingServer[pb.Product, pb.ImportProductsResponse]) error {
+	ctx := stream.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if err := authorizeAdmin(ctx); err != nil {
+		return err
+	}
+	if err := p.checkWritable(); err != nil {
+		span.AddEvent("import rejected, catalog is read-only")
+		return err
+	}
+
+	var (
+		received []*pb.Product
+		resp     = &pb.ImportProductsResponse{}
+		seen     = make(map[string]bool)
+	)
+	for {
+		product, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if reason := validateImportedProduct(product); reason != "" {
+			resp.Rejected = append(resp.Rejected, &pb.RejectedProduct{Id: product.GetId(), Reason: reason})
+			continue
+		}
+		if seen[product.Id] {
+			resp.Rejected = append(resp.Rejected, &pb.RejectedProduct{Id: product.Id, Reason: "duplicate id in import"})
+			continue
+		}
+		seen[product.Id] = true
+		received = append(received, product)
+	}
+
+	if len(resp.Rejected) > 0 && !p.lenientImport {
+		logger.WarnContext(ctx, "Product import rejected", "received", len(received)+len(resp.Rejected), "rejected", len(resp.Rejected))
+	} else if len(received) > 0 {
+		if err := persistProducts(ctx, received); err != nil {
+			logger.ErrorContext(ctx, err.Error(), "event", "ImportProducts failed")
+			return status.Errorf(codes.Internal, "failed to persist products: %v", err)
+		}
+		p.productCache.invalidate()
+		resp.Added, resp.Updated = p.catalog.merge(received)
+		resp.Applied = true
+		logger.InfoContext(ctx, "Imported products", "added", resp.Added, "updated", resp.Updated, "rejected", len(resp.Rejected))
+	}
+
+	span.SetAttributes(
+		attribute.Int("app.products_import.added", int(resp.Added)),
+		attribute.Int("app.products_import.updated", int(resp.Updated)),
+		attribute.Int("app.products_import.rejected", len(resp.Rejected)),
+		attribute.Bool("app.products_import.applied", resp.Applied),
+	)
+	return stream.SendAndClose(resp)
+}
+
+// authorizeAdmin checks the bearer token sent with mutating RPCs against
+// CATALOG_ADMIN_TOKEN. Those RPCs are disabled when no token is configured.
+func authorizeAdmin(ctx context.Context) error {
+	want := os.Getenv("CATALOG_ADMIN_TOKEN")
+	if want == "" {
+		return status.Error(codes.PermissionDenied, "catalog administration is disabled")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+}
+
+// readOnlyFromEnv reads CATALOG_READ_ONLY, which protects the catalog from
+// mutating RPCs during maintenance while reads stay available.
+func readOnlyFromEnv() bool {
+	readOnly, _ := strconv.ParseBool(os.Getenv("CATALOG_READ_ONLY"))
+	return readOnly
+}
+
+// lenientImportFromEnv reads CATALOG_IMPORT_STRICT. Imports are strict unless
+// it is "false", in which case the valid products of an import are applied
+// even when others are rejected.
+func lenientImportFromEnv() bool {
+	return os.Getenv("CATALOG_IMPORT_STRICT") == "false"
+}
+
+// checkWritable returns a FailedPrecondition status in read-only mode. Every
+// mutating RPC calls it once the caller is authorized.
+func (p *productCatalog) checkWritable() error {
+	if p.readOnly {
+		return status.Error(codes.FailedPrecondition, "catalog is read-only")
+	}
+	return nil
+}
+
+// validateImportedProduct returns why product cannot be imported, or "" if it
+// is valid.
+func validateImportedProduct(product *pb.Product) string {
+	switch {
+	case product.GetId() == "":
+		return "missing id"
+	case len(product.GetId()) > 20:
+		return "id longer than 20 characters"
+	case product.GetName() == "":
+		return "missing name"
+	case product.GetPriceUsd() == nil:
+		return "missing price"
+	case product.GetPriceUsd().GetCurrencyCode() != "USD":
+		return "price must be in USD"
+	case !validPrice(product.GetPriceUsd()):
+		return "invalid price"
+	}
+	return ""
+}
+
+// validPrice reports whether m is a non-negative amount with nanos in range.
+func validPrice(m *pb.Money) bool {
+	return m.GetUnits() >= 0 && m.GetNanos() >= 0 && m.GetNanos() <= 999999999
+}
+
+// persistProducts upserts products and their categories in a single
+// transaction, so a failed import leaves the database untouched.
+func persistProducts(ctx context.Context, products []*pb.Product) error {
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, product := range products {
+			row := Product{
+				ID:                product.Id,
+				Name:              product.Name,
+				Description:       product.Description,
+				Picture:           product.Picture,
+				Pictures:          product.Pictures,
+				PriceCurrencyCode: product.PriceUsd.CurrencyCode,
+				PriceUnits:        int(product.PriceUsd.Units),
+				PriceNanos:        int(product.PriceUsd.Nanos),
+				Tags:              product.Tags,
+				Featured:          product.Featured,
+			}
+			categories := make([]*Category, 0, len(product.Categories))
+			for _, name := range product.Categories {
+				category := Category{Name: name}
+				if err := tx.Where(Category{Name: name}).FirstOrCreate(&category).Error; err != nil {
+					return err
+				}
+				categories = append(categories, &category)
+			}
+			if err := tx.Omit("Categories").Save(&row).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&row).Association("Categories").Replace(categories); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeImportStream feeds products to ImportProducts and captures the response.
+type fakeImportStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	products []*pb.Product
+	resp     *pb.ImportProductsResponse
+}
+
+func (s *fakeImportStream) Context() context.Context { return s.ctx }
+
+func (s *fakeImportStream) Recv() (*pb.Product, error) {
+	if len(s.products) == 0 {
+		return nil, io.EOF
+	}
+	p := s.products[0]
+	s.products = s.products[1:]
+	return p, nil
+}
+
+func (s *fakeImportStream) SendAndClose(resp *pb.ImportProductsResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func adminContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func usd(units int64, nanos int32) *pb.Money {
+	return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+}
+
+func TestImportProducts(t *testing.T) {
+	t.Setenv("CATALOG_ADMIN_TOKEN", "secret")
+
+	t.Run("valid stream", func(t *testing.T) {
+		svc := &productCatalog{catalog: newCatalogStore([]*pb.Product{{Id: "A", Name: "Old A", PriceUsd: usd(1, 0)}})}
+
+		stream := &fakeImportStream{ctx: adminContext("secret"), products: []*pb.Product{
+			{Id: "A", Name: "New A", PriceUsd: usd(2, 500000000)},
+			{Id: "B", Name: "B", PriceUsd: usd(3, 0), Categories: []string{"telescopes"}},
+		}}
+		if err := svc.ImportProducts(stream); err != nil {
+			t.Fatalf("ImportProducts: %v", err)
+		}
+		if !stream.resp.Applied || stream.resp.Added != 1 || stream.resp.Updated != 1 || len(stream.resp.Rejected) != 0 {
+			t.Errorf("response = %v, want 1 added, 1 updated, applied", stream.resp)
+		}
+
+		got := svc.catalog.current()
+		if len(got) != 2 || got[0].Name != "New A" || got[1].Id != "B" {
+			t.Errorf("catalog after import = %v", got)
+		}
+	})
+
+	t.Run("strict import with a bad product is rolled back", func(t *testing.T) {
+		svc := &productCatalog{catalog: newCatalogStore([]*pb.Product{{Id: "A", Name: "A", PriceUsd: usd(1, 0)}})}
+
+		stream := &fakeImportStream{ctx: adminContext("secret"), products: []*pb.Product{
+			{Id: "B", Name: "B", PriceUsd: usd(3, 0)},
+			{Id: "C", Name: "C", PriceUsd: usd(-3, 0)},
+		}}
+		if err := svc.ImportProducts(stream); err != nil {
+			t.Fatalf("ImportProducts: %v", err)
+		}
+		if stream.resp.Applied {
+			t.Errorf("strict import with a rejected product was applied")
+		}
+		if len(stream.resp.Rejected) != 1 || stream.resp.Rejected[0].Id != "C" || stream.resp.Rejected[0].Reason != "invalid price" {
+			t.Errorf("rejected = %v, want C with an invalid price", stream.resp.Rejected)
+		}
+		if got := svc.catalog.current(); len(got) != 1 {
+			t.Errorf("catalog has %d products after a rolled back import, want 1", len(got))
+		}
+	})
+
+	t.Run("lenient import applies the valid products", func(t *testing.T) {
+		t.Setenv("CATALOG_IMPORT_STRICT", "false")
+		svc := &productCatalog{catalog: newCatalogStore(nil), lenientImport: lenientImportFromEnv()}
+
+		stream := &fakeImportStream{ctx: adminContext("secret"), products: []*pb.Product{
+			{Id: "B", Name: "B", PriceUsd: usd(3, 0)},
+			{Id: "", Name: "nameless", PriceUsd: usd(3, 0)},
+			{Id: "B", Name: "B again", PriceUsd: usd(3, 0)},
+		}}
+		if err := svc.ImportProducts(stream); err != nil {
+			t.Fatalf("ImportProducts: %v", err)
+		}
+		if !stream.resp.Applied || stream.resp.Added != 1 || len(stream.resp.Rejected) != 2 {
+			t.Errorf("response = %v, want 1 added and 2 rejected", stream.resp)
+		}
+	})
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		stream := &fakeImportStream{ctx: adminContext("wrong")}
+		err := (&productCatalog{}).ImportProducts(stream)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ImportProducts with a wrong token = %v, want Unauthenticated", err)
+		}
+	})
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	t.Setenv("CATALOG_ADMIN_TOKEN", "secret")
+	t.Setenv("CATALOG_READ_ONLY", "true")
+	svc := &productCatalog{
+		catalog:  newCatalogStore([]*pb.Product{{Id: "A", Name: "Telescope", PriceUsd: usd(1, 0), Featured: true}}),
+		readOnly: readOnlyFromEnv(),
+	}
+
+	stream := &fakeImportStream{ctx: adminContext("secret"), products: []*pb.Product{{Id: "B", Name: "B", PriceUsd: usd(3, 0)}}}
+	if err := svc.ImportProducts(stream); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ImportProducts in read-only mode = %v, want FailedPrecondition", err)
+	}
+	if got := svc.catalog.current(); len(got) != 1 || got[0].Name != "Telescope" {
+		t.Errorf("catalog after a rejected import = %v, want it unchanged", got)
+	}
+
+	search, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope"})
+	if err != nil || len(search.Results) != 1 {
+		t.Errorf("SearchProducts in read-only mode = %v, %v, want the telescope", search, err)
+	}
+	featured, err := svc.GetFeaturedProducts(context.Background(), &pb.GetFeaturedProductsRequest{})
+	if err != nil || len(featured.Products) != 1 {
+		t.Errorf("GetFeaturedProducts in read-only mode = %v, %v, want the telescope", featured, err)
+	}
+}
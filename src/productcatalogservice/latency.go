@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// productDelays caches the parsed value of the productCatalogSlowProducts
+// flag, a JSON object mapping product IDs to a delay in milliseconds. The
+// value is only parsed again when the flag changes.
+type productDelays struct {
+	mu     sync.Mutex
+	raw    string
+	delays map[string]time.Duration
+}
+
+// lookup returns the delay configured for id by the flag value raw.
+func (d *productDelays) lookup(raw, id string) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if raw != d.raw || d.delays == nil {
+		d.raw = raw
+		d.delays = parseProductDelays(raw)
+	}
+	return d.delays[id]
+}
+
+func parseProductDelays(raw string) map[string]time.Duration {
+	delays := make(map[string]time.Duration)
+	if raw == "" {
+		return delays
+	}
+
+	var ms map[string]int
+	if err := json.Unmarshal([]byte(raw), &ms); err != nil {
+		logger.Warn("Ignoring invalid productCatalogSlowProducts flag value", "value", raw, "error", err.Error())
+		return delays
+	}
+	for id, v := range ms {
+		if v > 0 {
+			delays[id] = time.Duration(v) * time.Millisecond
+		}
+	}
+	return delays
+}
+
+// sleepContext waits for d, returning early with the context's error if ctx is
+// done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
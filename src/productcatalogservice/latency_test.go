@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProductDelays(t *testing.T) {
+	var d productDelays
+	raw := `{"OLJCESPC7Z":250,"66VCHSJNUP":1000}`
+
+	if got := d.lookup(raw, "OLJCESPC7Z"); got != 250*time.Millisecond {
+		t.Errorf("delay for OLJCESPC7Z = %v, want 250ms", got)
+	}
+	if got := d.lookup(raw, "66VCHSJNUP"); got != time.Second {
+		t.Errorf("delay for 66VCHSJNUP = %v, want 1s", got)
+	}
+	if got := d.lookup(raw, "1YMWWN1N4O"); got != 0 {
+		t.Errorf("delay for an untargeted product = %v, want 0", got)
+	}
+
+	if got := d.lookup(`{"OLJCESPC7Z":50}`, "OLJCESPC7Z"); got != 50*time.Millisecond {
+		t.Errorf("delay after the flag changed = %v, want 50ms", got)
+	}
+	if got := d.lookup(`not json`, "OLJCESPC7Z"); got != 0 {
+		t.Errorf("delay for an invalid flag value = %v, want 0", got)
+	}
+	if got := d.lookup("", "OLJCESPC7Z"); got != 0 {
+		t.Errorf("delay for an unset flag = %v, want 0", got)
+	}
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sleepContext(ctx, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sleepContext = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepContext returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
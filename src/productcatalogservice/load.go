@@ -0,0 +1,78 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultLoadBackoff = 500 * time.Millisecond
+	maxLoadBackoff     = 30 * time.Second
+)
+
+// loadRetry bounds how often the initial catalog load is retried, for product
+// directories that only become readable some time after startup, such as
+// lazily mounted volumes.
+type loadRetry struct {
+	retries int
+	backoff time.Duration
+}
+
+// loadRetryFromEnv reads CATALOG_LOAD_RETRIES, the number of retries after a
+// failed load, none by default, and CATALOG_LOAD_BACKOFF_MS, the wait before
+// the first retry. The wait doubles after every retry, up to maxLoadBackoff.
+func loadRetryFromEnv() loadRetry {
+	return loadRetry{
+		retries: envPositiveInt("CATALOG_LOAD_RETRIES", 0),
+		backoff: time.Duration(envPositiveInt("CATALOG_LOAD_BACKOFF_MS", int(defaultLoadBackoff/time.Millisecond))) * time.Millisecond,
+	}
+}
+
+// load calls read until it succeeds, it has been retried r.retries times or ctx
+// is done, and returns the last result.
+func (r loadRetry) load(ctx context.Context, read func() ([]*pb.Product, error)) ([]*pb.Product, error) {
+	backoff := r.backoff
+	for attempt := 0; ; attempt++ {
+		products, err := read()
+		if err == nil || attempt >= r.retries {
+			return products, err
+		}
+		logger.Warn("Failed to load the product catalog, retrying",
+			"error", err.Error(), "attempt", attempt+1, "backoff", backoff.String())
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxLoadBackoff)
+	}
+}
+
+// requireProductsFromEnv reads CATALOG_REQUIRE_PRODUCTS, which makes an empty
+// catalog an error rather than an empty list.
+func requireProductsFromEnv() bool {
+	required, _ := strconv.ParseBool(os.Getenv("CATALOG_REQUIRE_PRODUCTS"))
+	return required
+}
+
+// checkLoaded returns a FailedPrecondition status when products are required
+// but the catalog is still loading or has none, so that a misconfigured
+// product directory is not mistaken for a shop with nothing to sell.
+func (p *productCatalog) checkLoaded() error {
+	if !p.requireProducts {
+		return nil
+	}
+	if p.loading.Load() || len(p.catalog.current()) == 0 {
+		return status.Error(codes.FailedPrecondition, "catalog not loaded")
+	}
+	return nil
+}
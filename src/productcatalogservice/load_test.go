@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// flakyRead returns a product reader failing its first n calls, and the number
+// of calls made so far.
+func flakyRead(n int) (func() ([]*pb.Product, error), *int) {
+	calls := new(int)
+	return func() ([]*pb.Product, error) {
+		*calls++
+		if *calls <= n {
+			return nil, errors.New("products directory not mounted")
+		}
+		return []*pb.Product{{Id: "A"}}, nil
+	}, calls
+}
+
+func TestLoadRetry(t *testing.T) {
+	r := loadRetry{retries: 3, backoff: time.Millisecond}
+
+	read, calls := flakyRead(3)
+	products, err := r.load(context.Background(), read)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("load() = %v, %v, want the catalog after 3 failures", products, err)
+	}
+	if *calls != 4 {
+		t.Errorf("read %d times, want 4", *calls)
+	}
+
+	read, calls = flakyRead(4)
+	if _, err := r.load(context.Background(), read); err == nil {
+		t.Error("load() succeeded after exhausting its retries")
+	}
+	if *calls != 4 {
+		t.Errorf("read %d times, want 4", *calls)
+	}
+
+	read, calls = flakyRead(1)
+	if _, err := (loadRetry{}).load(context.Background(), read); err == nil || *calls != 1 {
+		t.Errorf("load() without retries = %v after %d reads, want the first failure", err, *calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	read, _ = flakyRead(1)
+	if _, err := (loadRetry{retries: 1, backoff: time.Hour}).load(ctx, read); !errors.Is(err, context.Canceled) {
+		t.Errorf("load() with a cancelled context = %v, want it to stop retrying", err)
+	}
+}
+
+func TestCheckWhileLoading(t *testing.T) {
+	svc := &productCatalog{catalog: newCatalogStore(nil)}
+	svc.loading.Store(true)
+
+	resp, err := svc.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Check() while loading = %v, %v, want NOT_SERVING", resp, err)
+	}
+
+	svc.loading.Store(false)
+	resp, err = svc.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check() once loaded = %v, %v, want SERVING", resp, err)
+	}
+}
+
+func TestListProductsWithEmptyCatalog(t *testing.T) {
+	svc := &productCatalog{catalog: newCatalogStore(nil), requireProducts: true}
+	if _, err := svc.ListProducts(context.Background(), &pb.ListProductsRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ListProducts() on an empty catalog = %v, want FailedPrecondition", err)
+	}
+
+	svc.catalog.set([]*pb.Product{{Id: "A"}})
+	svc.loading.Store(true)
+	if _, err := svc.ListProducts(context.Background(), &pb.ListProductsRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ListProducts() while loading = %v, want FailedPrecondition", err)
+	}
+	svc.loading.Store(false)
+	if err := svc.checkLoaded(); err != nil {
+		t.Errorf("checkLoaded() on a loaded catalog = %v", err)
+	}
+
+	// by default an empty catalog is listed as no products
+	svc = &productCatalog{catalog: newCatalogStore(nil)}
+	svc.loading.Store(true)
+	if err := svc.checkLoaded(); err != nil {
+		t.Errorf("checkLoaded() without CATALOG_REQUIRE_PRODUCTS = %v, want an empty list allowed", err)
+	}
+}
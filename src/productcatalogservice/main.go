@@ -10,15 +10,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"math/rand"
 	"net"
 	"os"
 	"os/signal"
-	"strings"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -49,21 +50,18 @@ import (
 	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
-	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
 	serviceName       string
 	logger            = otelslog.NewLogger(serviceName)
-	catalog           []*pb.Product
 	resource          *sdkresource.Resource
 	initResourcesOnce sync.Once
 	db                *gorm.DB
 	containerId       string
+	transport         transportSecurity
 )
 
 func init() {
@@ -71,12 +69,6 @@ func init() {
 	fmt.Println(serviceName)
 	mustMapEnv(&containerId, "HOSTNAME")
 	fmt.Println(containerId)
-	var err error
-	catalog, err = readProductFiles()
-	if err != nil {
-		fmt.Println("Reading Product Files: %v", err)
-		os.Exit(1)
-	}
 }
 
 func initResource() *sdkresource.Resource {
@@ -159,6 +151,7 @@ func main() {
 		}
 	}()
 	global.SetLoggerProvider(lp)
+	logger = stdoutLogsFromEnv(logger, os.Stdout)
 
 	tp := initTracerProvider()
 	defer func() {
@@ -175,7 +168,13 @@ func main() {
 		}
 		logger.Info("Shutdown meter provider")
 	}()
-	openfeature.AddHooks(otelhooks.NewTracesHook())
+	hooks := []openfeature.Hook{otelhooks.NewTracesHook(), flagAttributesHook{}}
+	if flagErrors, err := newFlagErrorsHook(mp.Meter("productcatalogservice"), "productcatalogservice"); err != nil {
+		logger.Error("failed to create the feature flag error counter", "error", err.Error())
+	} else {
+		hooks = append(hooks, flagErrors)
+	}
+	openfeature.AddHooks(hooks...)
 	err := openfeature.SetProvider(flagd.NewProvider())
 	if err != nil {
 		logger.Error(err.Error())
@@ -186,7 +185,31 @@ func main() {
 		logger.Error(err.Error())
 	}
 
-	svc := &productCatalog{}
+	svc := &productCatalog{
+		catalog:           newCatalogStore(nil),
+		pages:             pageLimitsFromEnv(),
+		searchCache:       searchCacheFromEnv(),
+		maxQueryLen:       envPositiveInt("CATALOG_MAX_QUERY_LEN", defaultMaxQueryLen),
+		maxDescriptionLen: envPositiveInt("CATALOG_MAX_DESCRIPTION_LEN", 0),
+		sanitizeHTML:      sanitizeHTMLFromEnv(),
+		placeholder:       placeholderFromEnv(),
+		imageBase:         imageBaseFromEnv(),
+		searches:          searchLimiterFromEnv(),
+		productEvents:     productEventsFromEnv(),
+		requireProducts:   requireProductsFromEnv(),
+		popularity:        newPopularity(),
+		productCache:      productCacheFromEnv(),
+		tenants:           tenantCatalogsFromEnv(),
+		searchMinScore:    searchMinScoreFromEnv(),
+		readOnly:          readOnlyFromEnv(),
+		lenientImport:     lenientImportFromEnv(),
+		sortByFile:        sortByFileFromEnv(),
+	}
+	// the service reports not serving until the catalog is loaded below
+	svc.loading.Store(true)
+	if err := svc.searches.registerGauge(mp.Meter("productcatalogservice")); err != nil {
+		logger.Error("failed to register the search gauge", "error", err.Error())
+	}
 	var port string
 	mustMapEnv(&port, "PRODUCT_CATALOG_SERVICE_PORT")
 
@@ -224,11 +247,16 @@ func main() {
 		panic(err)
 	}
 
-	srv := grpc.NewServer(
+	transport, err = transportSecurityFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	srv := grpc.NewServer(append(transport.serverOptions(),
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-	)
+	)...)
 
-	reflection.Register(srv)
+	registerReflection(srv)
 
 	pb.RegisterProductCatalogServiceServer(srv, svc)
 	healthpb.RegisterHealthServer(srv, svc)
@@ -242,6 +270,33 @@ func main() {
 		}
 	}()
 
+	products, err := loadRetryFromEnv().load(ctx, readProductFiles)
+	if err != nil {
+		fmt.Printf("Reading Product Files: %v\n", err)
+		os.Exit(1)
+	}
+	svc.catalog.set(products)
+	if svc.tenants != nil {
+		catalogs, err := readTenantDirs("./products")
+		if err != nil {
+			fmt.Printf("Reading Tenant Product Files: %v\n", err)
+			os.Exit(1)
+		}
+		svc.tenants.set(catalogs)
+	}
+	svc.loading.Store(false)
+
+	if interval := reloadIntervalFromEnv(); interval > 0 {
+		reloader := newCatalogReloader(svc.catalog, "./products", interval)
+		if reloader.metrics, err = newReloadMetrics(mp.Meter("productcatalogservice")); err != nil {
+			logger.Error("failed to register the catalog reload counters", "error", err.Error())
+		}
+		if freshnessCheckFromEnv() {
+			svc.reloader.Store(reloader)
+		}
+		go reloader.run(ctx)
+	}
+
 	<-ctx.Done()
 
 	srv.GracefulStop()
@@ -250,43 +305,94 @@ func main() {
 
 type productCatalog struct {
 	pb.UnimplementedProductCatalogServiceServer
+	catalog           *catalogStore
+	pages             pageLimits
+	slowProducts      productDelays
+	searchCache       *searchCache
+	maxQueryLen       int
+	maxDescriptionLen int
+	sanitizeHTML      bool
+	placeholder       *pb.Product
+	imageBase         string
+	searches          *searchLimiter
+	productEvents     *eventSampler
+	loading           atomic.Bool
+	requireProducts   bool
+	popularity        *popularity
+	productCache      *productCache
+	tenants           *tenantCatalogs
+	searchMinScore    float32
+	readOnly          bool
+	lenientImport     bool
+	sortByFile        bool
+	// reloader is set when the health check verifies catalog freshness
+	reloader atomic.Pointer[catalogReloader]
 }
 
 func readProductFiles() ([]*pb.Product, error) {
+	return readProductDir("./products")
+}
 
-	// find all .json files in the products directory
-	entries, err := os.ReadDir("./products")
+// readProductDir reads the products in every product file in dir. See
+// productFileFormat for the supported formats, productLimitFromEnv for how
+// many products are read, and parseLimitsFromEnv for how many files are
+// parsed at once.
+func readProductDir(dir string) ([]*pb.Product, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	jsonFiles := make([]fs.FileInfo, 0, len(entries))
+	var files []productFile
 	for _, entry := range entries {
-		if strings.HasSuffix(entry.Name(), ".json") {
-			info, err := entry.Info()
-			if err != nil {
-				return nil, err
-			}
-			jsonFiles = append(jsonFiles, info)
+		if entry.IsDir() || !isProductFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
 		}
+		files = append(files, productFile{name: entry.Name(), size: info.Size()})
 	}
 
-	// read the contents of each .json file and unmarshal into a ListProductsResponse
-	// then append the products to the catalog
-	var products []*pb.Product
-	for _, f := range jsonFiles {
-		jsonData, err := os.ReadFile("./products/" + f.Name())
+	parsed, err := parseProductFiles(files, parseLimitsFromEnv(), func(file productFile) ([]*pb.Product, error) {
+		jsonData, err := os.ReadFile(filepath.Join(dir, file.name))
 		if err != nil {
 			return nil, err
 		}
 
-		var res pb.ListProductsResponse
-		if err := protojson.Unmarshal(jsonData, &res); err != nil {
+		format, err := productFileFormat(file.name, jsonData)
+		if err != nil {
 			return nil, err
 		}
 
-		products = append(products, res.Products...)
+		res, err := parseProducts(jsonData, format)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.name, err)
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limit := productLimitFromEnv()
+	var products []*pb.Product
+	var truncated []string
+	for i, res := range parsed {
+		if limit.max > 0 && len(products)+len(res) > limit.max {
+			if !limit.truncate {
+				return nil, fmt.Errorf("%s: catalog exceeds CATALOG_MAX_PRODUCTS=%d", files[i].name, limit.max)
+			}
+			res = res[:limit.max-len(products)]
+			truncated = append(truncated, files[i].name)
+		}
+		products = append(products, res...)
+	}
+	if len(truncated) > 0 {
+		logger.Warn("Truncated product catalog to CATALOG_MAX_PRODUCTS", "max", limit.max, "files", truncated)
 	}
+	sortCatalog(products)
 
 	logger.Info("Loaded products", "amount", len(products))
 
@@ -302,6 +408,13 @@ func mustMapEnv(target *string, key string) {
 }
 
 func (p *productCatalog) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if p.loading.Load() {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	if p.reloader.Load().stale() {
+		logger.WarnContext(ctx, "Product catalog is stale, the product files changed and reloading them fails")
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
 	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
 }
 
@@ -309,14 +422,63 @@ func (p *productCatalog) Watch(req *healthpb.HealthCheckRequest, ws healthpb.Hea
 	return status.Errorf(codes.Unimplemented, "health check via Watch not implemented")
 }
 
-func (p *productCatalog) ListProducts(ctx context.Context, req *pb.Empty) (*pb.ListProductsResponse, error) {
+func (p *productCatalog) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
 	span := trace.SpanFromContext(ctx)
 
+	pg, err := p.pages.parseListPage(req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := p.tenantCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenant != nil {
+		pbProducts, hasMore := p.listTenantProducts(tenant, req, pg)
+		applyPriceMultiplier(ctx, priceMultiplier(ctx), pbProducts...)
+		span.SetAttributes(
+			attribute.String("app.products.sort_by", req.GetSortBy().String()),
+			attribute.Int("app.products.count", len(pbProducts)),
+		)
+		return &pb.ListProductsResponse{Products: pbProducts, NextPageToken: pg.nextToken(hasMore)}, nil
+	}
+	if err := p.checkLoaded(); err != nil {
+		span.AddEvent("catalog not loaded")
+		return nil, err
+	}
+
+	// fetch one extra row to learn whether another page follows
 	var products []Product
-	if err := db.WithContext(ctx).Preload("Categories").Find(&products).Error; err != nil {
+	query := db.WithContext(ctx).Preload("Categories").Order("id")
+	popular := req.GetSortBy() == pb.ProductSort_PRODUCT_SORT_POPULARITY
+	if !popular && !p.sortByFile && !pg.all {
+		query = query.Offset(pg.offset).Limit(pg.size + 1)
+	}
+	if err := query.Find(&products).Error; err != nil {
 		logger.ErrorContext(ctx, err.Error(), "event", "ListProducts failed")
 		return nil, err
 	}
+	if popular || p.sortByFile {
+		// neither popularity nor the file order is stored in the database, so
+		// the whole catalog is sorted before paging
+		if popular {
+			sortByPopularity(products, func(p Product) string { return p.ID }, p.popularity.snapshot())
+		} else {
+			_, index, _ := p.catalog.snapshot()
+			sortByCatalogOrder(products, func(p Product) string { return p.ID }, index)
+		}
+		start, end := pg.bounds(len(products))
+		products = products[start:min(end+1, len(products))]
+	}
+	span.SetAttributes(attribute.String("app.products.sort_by", req.GetSortBy().String()))
+	if p.requireProducts && pg.offset == 0 && len(products) == 0 {
+		span.AddEvent("catalog not loaded")
+		return nil, status.Error(codes.FailedPrecondition, "catalog not loaded")
+	}
+	hasMore := !pg.all && len(products) > pg.size
+	if hasMore {
+		products = products[:pg.size]
+	}
 
 	var pbProducts []*pb.Product
 	for _, product := range products {
@@ -328,22 +490,27 @@ func (p *productCatalog) ListProducts(ctx context.Context, req *pb.Empty) (*pb.L
 		pbProduct := &pb.Product{
 			Id:          product.ID,
 			Name:        product.Name,
-			Description: product.Description,
+			Description: p.listedDescription(product.Description),
 			Picture:     product.Picture,
+			Pictures:    product.Pictures,
 			PriceUsd: &pb.Money{
 				CurrencyCode: product.PriceCurrencyCode,
 				Units:        int64(product.PriceUnits),
 				Nanos:        int32(product.PriceNanos),
 			},
 			Categories: categoryNames,
+			Tags:       product.Tags,
+			Featured:   product.Featured,
 		}
+		p.applyImageSize(pbProduct, req.GetImageSize())
 		pbProducts = append(pbProducts, pbProduct)
 	}
+	applyPriceMultiplier(ctx, priceMultiplier(ctx), pbProducts...)
 
 	span.SetAttributes(
 		attribute.Int("app.products.count", len(pbProducts)),
 	)
-	return &pb.ListProductsResponse{Products: pbProducts}, nil
+	return &pb.ListProductsResponse{Products: pbProducts, NextPageToken: pg.nextToken(hasMore)}, nil
 }
 
 func (p *productCatalog) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
@@ -365,6 +532,15 @@ func (p *productCatalog) GetProduct(ctx context.Context, req *pb.GetProductReque
 		time.Sleep(time.Duration(delay) * time.Millisecond)
 	}
 
+	slowProducts, _ := client.StringValue(ctx, "productCatalogSlowProducts", "", openfeature.EvaluationContext{})
+	if delay := p.slowProducts.lookup(slowProducts, req.Id); delay > 0 {
+		span.SetAttributes(attribute.Int64("app.product.delay_ms", delay.Milliseconds()))
+		if err := sleepContext(ctx, delay); err != nil {
+			logger.WarnContext(ctx, "GetProduct cancelled during injected delay", "error", err.Error())
+			return nil, status.FromContextError(err).Err()
+		}
+	}
+
 	timeoutFailureProbability, _ := client.FloatValue(
 		ctx, "productCatalogTimeoutFailure", 0, openfeature.EvaluationContext{},
 	)
@@ -395,14 +571,62 @@ func (p *productCatalog) GetProduct(ctx context.Context, req *pb.GetProductReque
 		return nil, status.Errorf(codes.Internal, msg)
 	}
 
+	tenant, err := p.tenantCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pbProduct *pb.Product
+	if tenant != nil {
+		// tenant catalogs are held in memory and need no caching
+		if pbProduct = p.tenantProduct(tenant, req); pbProduct == nil {
+			return p.productNotFound(ctx, req.Id)
+		}
+	} else {
+		generation := p.productCache.current()
+		key := productCacheKey{id: req.Id, imageSize: req.GetImageSize()}
+		var cached bool
+		pbProduct, cached = p.productCache.get(key)
+		span.SetAttributes(attribute.Bool("app.product.cached", cached))
+		if !cached {
+			pbProduct, err = p.loadProduct(ctx, req)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// the placeholder is served as is, without caching it
+				return p.productNotFound(ctx, req.Id)
+			}
+			if err != nil {
+				return nil, err
+			}
+			p.productCache.put(key, generation, pbProduct)
+		}
+	}
+	applyPriceMultiplier(ctx, priceMultiplier(ctx), pbProduct)
+
+	span.SetAttributes(
+		attribute.String("app.product.name", pbProduct.Name),
+	)
+	p.popularity.recordView(pbProduct.Id)
+	p.productEvents.addEvent(span, "Product Found", trace.WithAttributes(
+		attribute.String("app.product.id", pbProduct.Id),
+	))
+	return pbProduct, nil
+}
+
+// loadProduct reads the product requested from the database, returning
+// gorm.ErrRecordNotFound when there is no such product.
+func (p *productCatalog) loadProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	span := trace.SpanFromContext(ctx)
+
 	var product Product
 	if err := db.WithContext(ctx).Preload("Categories").Where("id = ?", req.Id).First(&product).Error; err != nil {
-		logger.ErrorContext(ctx, err.Error(), "event", "GetProduct failed")
-		span.SetStatus(otelcodes.Error, "GetProduct failed")
-		span.RecordError(err)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			msg := fmt.Sprintf("Product Not Found: %s", req.Id)
-			return nil, status.Errorf(codes.NotFound, msg)
+		notFound := errors.Is(err, gorm.ErrRecordNotFound)
+		if !notFound || p.placeholder == nil {
+			// serving the placeholder is not an error
+			logger.ErrorContext(ctx, err.Error(), "event", "GetProduct failed")
+			span.SetStatus(otelcodes.Error, "GetProduct failed")
+			span.RecordError(err)
+		}
+		if notFound {
+			return nil, err
 		}
 
 		msg := fmt.Sprintf("Database Error: %v", err)
@@ -416,36 +640,95 @@ func (p *productCatalog) GetProduct(ctx context.Context, req *pb.GetProductReque
 	pbProduct := &pb.Product{
 		Id:          product.ID,
 		Name:        product.Name,
-		Description: product.Description,
+		Description: p.sanitizeDescription(product.Description),
 		Picture:     product.Picture,
+		Pictures:    product.Pictures,
 		PriceUsd: &pb.Money{
 			CurrencyCode: product.PriceCurrencyCode,
 			Units:        int64(product.PriceUnits),
 			Nanos:        int32(product.PriceNanos),
 		},
 		Categories: categoryNames,
+		Tags:       product.Tags,
+		Featured:   product.Featured,
 	}
-
-	span.SetAttributes(
-		attribute.String("app.product.name", pbProduct.Name),
-	)
+	p.applyImageSize(pbProduct, req.GetImageSize())
 	return pbProduct, nil
 }
 
 func (p *productCatalog) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
 	span := trace.SpanFromContext(ctx)
 
+	pg, err := p.pages.parseListPage(req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := p.searches.acquire()
+	if err != nil {
+		span.AddEvent("search rejected, concurrency limit reached")
+		return nil, err
+	}
+	defer release()
+
+	query := normalizeQuery(req.Query)
+	span.SetAttributes(
+		attribute.Int("app.products_search.query_length", utf8.RuneCountInString(query)),
+		attribute.String("app.products_search.fields", req.GetFields().String()),
+	)
+	if err := p.checkQueryLength(query); err != nil {
+		return nil, err
+	}
+	minScore, err := p.minScore(req.GetMinScore())
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Float64("app.products_search.min_score", float64(minScore)))
+	key := searchCacheKey(query, req.GetFields())
+
+	products, index, version := p.catalog.snapshot()
+
 	var result []*pb.Product
-	for _, product := range catalog {
-		if strings.Contains(strings.ToLower(product.Name), strings.ToLower(req.Query)) ||
-			strings.Contains(strings.ToLower(product.Description), strings.ToLower(req.Query)) {
-			result = append(result, product)
+	ids, cached := p.searchCache.get(key, version)
+	if cached {
+		result = make([]*pb.Product, 0, len(ids))
+		for _, id := range ids {
+			if i, ok := index[id]; ok {
+				result = append(result, products[i])
+			}
+		}
+	} else {
+		if req.GetFields() == pb.SearchFields_SEARCH_FIELDS_TAGS {
+			result, version = p.catalog.tagged(query)
+		} else {
+			for _, product := range products {
+				if matchesQuery(product, query, req.GetFields()) {
+					result = append(result, product)
+				}
+			}
+		}
+		ids = make([]string, len(result))
+		for i, product := range result {
+			ids[i] = product.Id
+		}
+		p.searchCache.put(key, version, ids)
+	}
+	if minScore > 0 {
+		// the cache holds every match, whatever the threshold
+		relevant := make([]*pb.Product, 0, len(result))
+		for _, product := range result {
+			if matchScore(product, query, req.GetFields()) >= minScore {
+				relevant = append(relevant, product)
+			}
 		}
+		result = relevant
 	}
 	span.SetAttributes(
 		attribute.Int("app.products_search.count", len(result)),
+		attribute.Bool("app.products_search.cached", cached),
 	)
-	return &pb.SearchProductsResponse{Results: result}, nil
+	start, end := pg.bounds(len(result))
+	return &pb.SearchProductsResponse{Results: p.listed(result[start:end]), NextPageToken: pg.nextToken(end < len(result))}, nil
 }
 
 func (p *productCatalog) checkProductFailure(ctx context.Context, id string) bool {
@@ -462,7 +745,7 @@ func (p *productCatalog) checkProductFailure(ctx context.Context, id string) boo
 
 func createClient(ctx context.Context, svcAddr string) (*grpc.ClientConn, error) {
 	return grpc.DialContext(ctx, svcAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(transport.clientCredentials()),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 }
@@ -1,14 +1,17 @@
 package main
 
 type Product struct {
-	ID                string      `gorm:"type:varchar(20);primaryKey" json:"id"`
-	Name              string      `gorm:"type:varchar(255);not null" json:"name"`
-	Description       string      `gorm:"type:text" json:"description"`
-	Picture           string      `gorm:"type:varchar(255)" json:"picture"`
-	PriceCurrencyCode string      `gorm:"type:varchar(10);not null" json:"-"`
-	PriceUnits        int         `gorm:"not null" json:"-"`
-	PriceNanos        int         `gorm:"not null" json:"-"`
-	Categories        []*Category `gorm:"many2many:product_categories" json:"categories,omitempty"`
+	ID                string            `gorm:"type:varchar(20);primaryKey" json:"id"`
+	Name              string            `gorm:"type:varchar(255);not null" json:"name"`
+	Description       string            `gorm:"type:text" json:"description"`
+	Picture           string            `gorm:"type:varchar(255)" json:"picture"`
+	Pictures          map[string]string `gorm:"serializer:json" json:"pictures,omitempty"`
+	PriceCurrencyCode string            `gorm:"type:varchar(10);not null" json:"-"`
+	PriceUnits        int               `gorm:"not null" json:"-"`
+	PriceNanos        int               `gorm:"not null" json:"-"`
+	Categories        []*Category       `gorm:"many2many:product_categories" json:"categories,omitempty"`
+	Tags              []string          `gorm:"serializer:json" json:"tags,omitempty"`
+	Featured          bool              `gorm:"not null;default:false" json:"featured,omitempty"`
 }
 
 type Category struct {
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package money
+
+import (
+	"errors"
+	"math"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+const (
+	nanosMin = -999999999
+	nanosMax = +999999999
+	nanosMod = 1000000000
+
+	// nanosPerCent is the precision prices are rounded to when scaled.
+	nanosPerCent = 10000000
+)
+
+var (
+	ErrInvalidValue  = errors.New("one of the specified money values is invalid")
+	ErrInvalidFactor = errors.New("scale factor must be a finite, non-negative number")
+)
+
+// IsValid checks if specified value has a valid units/nanos signs and ranges.
+func IsValid(m *pb.Money) bool {
+	return signMatches(m) && validNanos(m.GetNanos())
+}
+
+func signMatches(m *pb.Money) bool {
+	return m.GetNanos() == 0 || m.GetUnits() == 0 || (m.GetNanos() < 0) == (m.GetUnits() < 0)
+}
+
+func validNanos(nanos int32) bool { return nanosMin <= nanos && nanos <= nanosMax }
+
+// Scale multiplies m by factor, rounding the result to the nearest cent with
+// halves rounded away from zero. The currency code is preserved.
+func Scale(m *pb.Money, factor float64) (*pb.Money, error) {
+	if !IsValid(m) {
+		return nil, ErrInvalidValue
+	}
+	if factor < 0 || math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return nil, ErrInvalidFactor
+	}
+
+	cents := float64(m.GetUnits())*100 + float64(m.GetNanos())/nanosPerCent
+	scaled := math.Round(cents * factor)
+	if math.Abs(scaled) > math.MaxInt64/nanosPerCent {
+		return nil, ErrInvalidValue
+	}
+	nanos := int64(scaled) * nanosPerCent
+	return &pb.Money{
+		CurrencyCode: m.GetCurrencyCode(),
+		Units:        nanos / nanosMod,
+		Nanos:        int32(nanos % nanosMod),
+	}, nil
+}
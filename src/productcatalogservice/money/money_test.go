@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/protobuf/proto"
+)
+
+func mm(u int64, n int32) *pb.Money { return &pb.Money{Units: u, Nanos: n, CurrencyCode: "USD"} }
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     *pb.Money
+		factor float64
+		want   *pb.Money
+	}{
+		{"identity", mm(101, 960000000), 1, mm(101, 960000000)},
+		{"surge", mm(101, 960000000), 1.5, mm(152, 940000000)},
+		{"markdown", mm(349, 950000000), 0.8, mm(279, 960000000)},
+		{"markdown rounds half up", mm(0, 50000000), 0.9, mm(0, 50000000)},
+		{"surge rounds down", mm(21, 950000000), 1.11, mm(24, 360000000)},
+		{"surge rounds up", mm(19, 990000000), 1.15, mm(22, 990000000)},
+		{"free", mm(0, 0), 2, mm(0, 0)},
+		{"zero factor", mm(3599, 0), 0, mm(0, 0)},
+		{"negative amount", mm(-1, -500000000), 1.1, mm(-1, -650000000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Scale(tt.in, tt.factor)
+			if err != nil {
+				t.Fatalf("Scale() error = %v", err)
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("Scale(%v, %v) = %v, want %v", tt.in, tt.factor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleRejectsInvalidInput(t *testing.T) {
+	if _, err := Scale(mm(1, -1), 2); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Scale() of an invalid amount error = %v, want ErrInvalidValue", err)
+	}
+	for _, factor := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := Scale(mm(1, 0), factor); !errors.Is(err, ErrInvalidFactor) {
+			t.Errorf("Scale() by %v error = %v, want ErrInvalidFactor", factor, err)
+		}
+	}
+	if _, err := Scale(mm(math.MaxInt64/2, 0), 10); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Scale() overflow error = %v, want ErrInvalidValue", err)
+	}
+}
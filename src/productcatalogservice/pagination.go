@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// pageLimits are the page sizes shared by every list RPC.
+type pageLimits struct {
+	defaultSize int
+	maxSize     int
+}
+
+// pageLimitsFromEnv reads CATALOG_DEFAULT_PAGE_SIZE and CATALOG_MAX_PAGE_SIZE.
+// The default page size never exceeds the maximum.
+func pageLimitsFromEnv() pageLimits {
+	l := pageLimits{
+		defaultSize: envPositiveInt("CATALOG_DEFAULT_PAGE_SIZE", defaultPageSize),
+		maxSize:     envPositiveInt("CATALOG_MAX_PAGE_SIZE", maxPageSize),
+	}
+	if l.defaultSize > l.maxSize {
+		l.defaultSize = l.maxSize
+	}
+	return l
+}
+
+// page is a validated position within a list result. A page with all set
+// covers the whole result.
+type page struct {
+	offset int
+	size   int
+	all    bool
+}
+
+// parsePage defaults and clamps the requested page size and decodes the page
+// token, which is the offset of the first item of the page.
+func (l pageLimits) parsePage(pageSize int32, pageToken string) (page, error) {
+	if l.maxSize <= 0 {
+		l = pageLimits{defaultSize: defaultPageSize, maxSize: maxPageSize}
+	}
+	p := page{size: int(pageSize)}
+	if p.size <= 0 {
+		p.size = l.defaultSize
+	}
+	if p.size > l.maxSize {
+		p.size = l.maxSize
+	}
+	if pageToken != "" {
+		offset, err := strconv.Atoi(pageToken)
+		if err != nil || offset < 0 {
+			return page{}, status.Errorf(codes.InvalidArgument, "invalid page token %q", pageToken)
+		}
+		p.offset = offset
+	}
+	return p, nil
+}
+
+// parseListPage is parsePage for ListProducts and SearchProducts, which
+// returned every product before they were paginated: a request with neither a
+// page size nor a page token still gets the whole result, and the default page
+// size only applies to requests that paginate.
+func (l pageLimits) parseListPage(pageSize int32, pageToken string) (page, error) {
+	if pageSize <= 0 && pageToken == "" {
+		return page{all: true}, nil
+	}
+	return l.parsePage(pageSize, pageToken)
+}
+
+// bounds returns the range of a list of n items covered by the page.
+func (p page) bounds(n int) (start, end int) {
+	if p.all {
+		return 0, n
+	}
+	start = min(p.offset, n)
+	end = min(start+p.size, n)
+	return start, end
+}
+
+// nextToken returns the token of the following page, or "" if there is none.
+func (p page) nextToken(hasMore bool) string {
+	if !hasMore || p.all {
+		return ""
+	}
+	return strconv.Itoa(p.offset + p.size)
+}
+
+// envPositiveInt reads key as a positive integer, falling back to def when it
+// is unset or invalid.
+func envPositiveInt(key string, def int) int {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		logger.Warn("Ignoring invalid environment variable", "key", key, "value", s)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParsePage(t *testing.T) {
+	limits := pageLimits{defaultSize: 10, maxSize: 50}
+	tests := []struct {
+		name       string
+		pageSize   int32
+		pageToken  string
+		wantSize   int
+		wantOffset int
+	}{
+		{"default when unset", 0, "", 10, 0},
+		{"default when negative", -5, "", 10, 0},
+		{"requested size", 20, "", 20, 0},
+		{"at max", 50, "", 50, 0},
+		{"clamped to max", 51, "", 50, 0},
+		{"offset from token", 5, "15", 5, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := limits.parsePage(tt.pageSize, tt.pageToken)
+			if err != nil {
+				t.Fatalf("parsePage: %v", err)
+			}
+			if got.size != tt.wantSize || got.offset != tt.wantOffset {
+				t.Errorf("parsePage(%d, %q) = %+v, want size %d offset %d", tt.pageSize, tt.pageToken, got, tt.wantSize, tt.wantOffset)
+			}
+		})
+	}
+
+	for _, token := range []string{"abc", "-1"} {
+		if _, err := limits.parsePage(0, token); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parsePage with token %q = %v, want InvalidArgument", token, err)
+		}
+	}
+}
+
+func TestPageLimitsFromEnv(t *testing.T) {
+	t.Setenv("CATALOG_DEFAULT_PAGE_SIZE", "500")
+	t.Setenv("CATALOG_MAX_PAGE_SIZE", "200")
+
+	got := pageLimitsFromEnv()
+	if got.maxSize != 200 || got.defaultSize != 200 {
+		t.Errorf("pageLimitsFromEnv() = %+v, want the default clamped to the max of 200", got)
+	}
+}
+
+func TestParseListPage(t *testing.T) {
+	limits := pageLimits{defaultSize: 2, maxSize: 50}
+	pg, err := limits.parseListPage(0, "")
+	if err != nil || !pg.all {
+		t.Fatalf("parseListPage(0, \"\") = %+v, %v, want the whole result", pg, err)
+	}
+	if start, end := pg.bounds(500); start != 0 || end != 500 || pg.nextToken(true) != "" {
+		t.Errorf("unpaged bounds = %d..%d, next %q, want every item and no next page", start, end, pg.nextToken(true))
+	}
+	if pg, err := limits.parseListPage(0, "4"); err != nil || pg.all || pg.size != 2 || pg.offset != 4 {
+		t.Errorf("parseListPage(0, \"4\") = %+v, %v, want a default-sized page at 4", pg, err)
+	}
+}
+
+func TestSearchProductsPagination(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "1", Name: "Telescope one"},
+		{Id: "2", Name: "Telescope two"},
+		{Id: "3", Name: "Telescope three"},
+		{Id: "4", Name: "Binoculars"},
+	})
+	svc := &productCatalog{catalog: catalog, pages: pageLimits{defaultSize: 2, maxSize: 2}}
+
+	var ids []string
+	req := &pb.SearchProductsRequest{Query: "telescope", PageSize: 5}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination did not terminate")
+		}
+		resp, err := svc.SearchProducts(context.Background(), req)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		if len(resp.Results) > 2 {
+			t.Errorf("page has %d results, want at most the max page size of 2", len(resp.Results))
+		}
+		for _, p := range resp.Results {
+			ids = append(ids, p.Id)
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+
+	if len(ids) != 3 || ids[0] != "1" || ids[1] != "2" || ids[2] != "3" {
+		t.Errorf("paged results = %v, want [1 2 3]", ids)
+	}
+}
+
+func TestSearchProductsUnpaged(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "1", Name: "Telescope one"},
+		{Id: "2", Name: "Telescope two"},
+		{Id: "3", Name: "Telescope three"},
+	})
+	svc := &productCatalog{catalog: catalog, pages: pageLimits{defaultSize: 2, maxSize: 2}}
+
+	resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope"})
+	if err != nil {
+		t.Fatalf("SearchProducts: %v", err)
+	}
+	if len(resp.Results) != 3 || resp.NextPageToken != "" {
+		t.Errorf("unpaged search = %d results, next %q, want all 3", len(resp.Results), resp.NextPageToken)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"golang.org/x/sync/semaphore"
+)
+
+// defaultParseMemoryMB bounds the size of the product files decoded at once
+// when CATALOG_PARSE_MEMORY_MB is not set.
+const defaultParseMemoryMB = 64
+
+// parseLimits bound how product files are parsed: at most concurrency files
+// at once, adding up to at most memory bytes. Decoding a file takes memory in
+// proportion to its size, so the budget keeps a catalog of huge files from
+// being decoded all at once.
+type parseLimits struct {
+	concurrency int
+	memory      int64
+}
+
+// parseLimitsFromEnv reads CATALOG_PARSE_CONCURRENCY, which defaults to the
+// number of CPUs and parses files one after the other when it is 1, and
+// CATALOG_PARSE_MEMORY_MB.
+func parseLimitsFromEnv() parseLimits {
+	return parseLimits{
+		concurrency: envPositiveInt("CATALOG_PARSE_CONCURRENCY", runtime.GOMAXPROCS(0)),
+		memory:      int64(envPositiveInt("CATALOG_PARSE_MEMORY_MB", defaultParseMemoryMB)) << 20,
+	}
+}
+
+// productFile is a product file to parse, and its size in bytes.
+type productFile struct {
+	name string
+	size int64
+}
+
+// parseProductFiles parses each of files with parse within limits, and
+// returns their products in the order of files. A file larger than the memory
+// budget is parsed alone. When several files fail, the error of the first one
+// is returned.
+func parseProductFiles(files []productFile, limits parseLimits, parse func(productFile) ([]*pb.Product, error)) ([][]*pb.Product, error) {
+	results := make([][]*pb.Product, len(files))
+	if limits.concurrency <= 1 {
+		for i, file := range files {
+			products, err := parse(file)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = products
+		}
+		return results, nil
+	}
+
+	slots := semaphore.NewWeighted(int64(limits.concurrency))
+	memory := semaphore.NewWeighted(limits.memory)
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		weight := min(max(file.size, 1), limits.memory)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// acquiring never fails, the context is never done
+			_ = memory.Acquire(context.Background(), weight)
+			defer memory.Release(weight)
+			_ = slots.Acquire(context.Background(), 1)
+			defer slots.Release(1)
+			results[i], errs[i] = parse(file)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,126 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+// parseTracker records the most files parsed at once.
+type parseTracker struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (p *parseTracker) parse(file productFile) ([]*pb.Product, error) {
+	p.mu.Lock()
+	p.inFlight++
+	p.max = max(p.max, p.inFlight)
+	p.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	return []*pb.Product{{Id: file.name}}, nil
+}
+
+func TestParseProductFilesLimits(t *testing.T) {
+	const mb = 1 << 20
+	files := make([]productFile, 8)
+	for i := range files {
+		files[i] = productFile{name: fmt.Sprintf("products-%d.json", i), size: mb}
+	}
+
+	tests := []struct {
+		name    string
+		files   []productFile
+		limits  parseLimits
+		wantMax int
+	}{
+		{name: "sequential", files: files, limits: parseLimits{concurrency: 1, memory: 100 * mb}, wantMax: 1},
+		{name: "concurrency cap", files: files, limits: parseLimits{concurrency: 3, memory: 100 * mb}, wantMax: 3},
+		{name: "memory cap", files: files, limits: parseLimits{concurrency: 8, memory: 2 * mb}, wantMax: 2},
+		{
+			name:    "file larger than the budget",
+			files:   append([]productFile{{name: "huge.json", size: 10 * mb}}, files[:3]...),
+			limits:  parseLimits{concurrency: 4, memory: 2 * mb},
+			wantMax: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &parseTracker{}
+			results, err := parseProductFiles(tt.files, tt.limits, tracker.parse)
+			if err != nil {
+				t.Fatalf("parseProductFiles: %v", err)
+			}
+			if tracker.max > tt.wantMax {
+				t.Errorf("parsed %d files at once, want at most %d", tracker.max, tt.wantMax)
+			}
+			for i, products := range results {
+				if len(products) != 1 || products[0].Id != tt.files[i].name {
+					t.Errorf("result %d = %v, want the products of %s", i, products, tt.files[i].name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseProductFilesError(t *testing.T) {
+	files := []productFile{{name: "a.json"}, {name: "b.json"}, {name: "c.json"}}
+	_, err := parseProductFiles(files, parseLimits{concurrency: 3, memory: 1 << 20}, func(file productFile) ([]*pb.Product, error) {
+		if file.name == "a.json" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if file.name != "c.json" {
+			return nil, fmt.Errorf("%s: broken", file.name)
+		}
+		return nil, nil
+	})
+	if err == nil || err.Error() != "a.json: broken" {
+		t.Errorf("parseProductFiles() error = %v, want the error of the first file", err)
+	}
+}
+
+func TestReadProductDirConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	for f := 0; f < 6; f++ {
+		var lines []string
+		for i := 0; i < 2000; i++ {
+			lines = append(lines, fmt.Sprintf(`{"id": "P%d-%04d", "name": "Product %d", "description": "%s", "priceUsd": {"currencyCode": "USD", "units": %d}}`,
+				f, i, i, strings.Repeat("large ", 20), i))
+		}
+		writeProductFile(t, dir, fmt.Sprintf("products-%d.ndjson", f), strings.Join(lines, "\n"))
+	}
+
+	t.Setenv("CATALOG_PARSE_CONCURRENCY", "1")
+	sequential, err := readProductDir(dir)
+	if err != nil {
+		t.Fatalf("readProductDir sequentially: %v", err)
+	}
+
+	t.Setenv("CATALOG_PARSE_CONCURRENCY", "4")
+	t.Setenv("CATALOG_PARSE_MEMORY_MB", "1")
+	concurrent, err := readProductDir(dir)
+	if err != nil {
+		t.Fatalf("readProductDir concurrently: %v", err)
+	}
+
+	if len(concurrent) != 6*2000 || len(concurrent) != len(sequential) {
+		t.Fatalf("read %d products concurrently and %d sequentially, want %d", len(concurrent), len(sequential), 6*2000)
+	}
+	for i := range sequential {
+		if concurrent[i].Id != sequential[i].Id {
+			t.Fatalf("product %d is %s concurrently and %s sequentially", i, concurrent[i].Id, sequential[i].Id)
+		}
+	}
+}
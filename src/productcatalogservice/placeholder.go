@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultPlaceholderID   = "PLACEHOLDER"
+	defaultPlaceholderName = "Product unavailable"
+)
+
+// placeholderFromEnv returns the product GetProduct serves for unknown IDs
+// when CATALOG_RETURN_PLACEHOLDER_ON_MISS is true, so the frontend keeps
+// rendering during catalog gaps. Its ID and name come from
+// CATALOG_PLACEHOLDER_ID and CATALOG_PLACEHOLDER_NAME. It returns nil, meaning
+// unknown IDs are NotFound, by default.
+func placeholderFromEnv() *pb.Product {
+	if enabled, _ := strconv.ParseBool(os.Getenv("CATALOG_RETURN_PLACEHOLDER_ON_MISS")); !enabled {
+		return nil
+	}
+	placeholder := &pb.Product{
+		Id:       os.Getenv("CATALOG_PLACEHOLDER_ID"),
+		Name:     os.Getenv("CATALOG_PLACEHOLDER_NAME"),
+		PriceUsd: &pb.Money{CurrencyCode: "USD"},
+	}
+	if placeholder.Id == "" {
+		placeholder.Id = defaultPlaceholderID
+	}
+	if placeholder.Name == "" {
+		placeholder.Name = defaultPlaceholderName
+	}
+	return placeholder
+}
+
+// productNotFound answers a GetProduct for an unknown id, with the
+// placeholder product if there is one.
+func (p *productCatalog) productNotFound(ctx context.Context, id string) (*pb.Product, error) {
+	if p.placeholder == nil {
+		return nil, status.Errorf(codes.NotFound, "Product Not Found: %s", id)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("app.product.placeholder", true))
+	logger.WarnContext(ctx, "Product not found, returning the placeholder", "product_id", id)
+	return proto.Clone(p.placeholder).(*pb.Product), nil
+}
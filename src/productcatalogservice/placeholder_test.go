@@ -0,0 +1,42 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProductNotFound(t *testing.T) {
+	t.Run("not found by default", func(t *testing.T) {
+		t.Setenv("CATALOG_RETURN_PLACEHOLDER_ON_MISS", "")
+		svc := &productCatalog{placeholder: placeholderFromEnv()}
+
+		product, err := svc.productNotFound(context.Background(), "MISSING")
+		if status.Code(err) != codes.NotFound || product != nil {
+			t.Errorf("productNotFound() = %v, %v, want NotFound", product, err)
+		}
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		t.Setenv("CATALOG_RETURN_PLACEHOLDER_ON_MISS", "true")
+		t.Setenv("CATALOG_PLACEHOLDER_NAME", "Coming soon")
+		svc := &productCatalog{placeholder: placeholderFromEnv()}
+
+		product, err := svc.productNotFound(context.Background(), "MISSING")
+		if err != nil {
+			t.Fatalf("productNotFound() error = %v, want the placeholder", err)
+		}
+		if product.Id != defaultPlaceholderID || product.Name != "Coming soon" || product.PriceUsd.GetCurrencyCode() != "USD" {
+			t.Errorf("productNotFound() = %v, want the configured placeholder", product)
+		}
+
+		product.Name = "changed by a caller"
+		if again, _ := svc.productNotFound(context.Background(), "MISSING"); again.Name != "Coming soon" {
+			t.Errorf("placeholder was modified through a returned product: %v", again)
+		}
+	})
+}
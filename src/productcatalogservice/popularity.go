@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// popularity counts how often each product is looked up with GetProduct,
+// which covers both product page views and the lookups checkout makes for the
+// products it sells. A nil *popularity counts nothing.
+type popularity struct {
+	mu    sync.Mutex
+	views map[string]int64
+}
+
+func newPopularity() *popularity {
+	return &popularity{views: make(map[string]int64)}
+}
+
+// recordView counts a lookup of the product with the given ID.
+func (p *popularity) recordView(id string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.views[id]++
+}
+
+// snapshot returns a copy of the counts, so that a listing sorts all of its
+// products by the same counts even while lookups keep coming in.
+func (p *popularity) snapshot() map[string]int64 {
+	counts := make(map[string]int64)
+	if p == nil {
+		return counts
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, n := range p.views {
+		counts[id] = n
+	}
+	return counts
+}
+
+// sortByPopularity orders products, whose IDs are returned by id, from the
+// most to the least viewed in counts, breaking ties by ID.
+func sortByPopularity[T any](products []T, id func(T) string, counts map[string]int64) {
+	sort.SliceStable(products, func(i, j int) bool {
+		idI, idJ := id(products[i]), id(products[j])
+		if ci, cj := counts[idI], counts[idJ]; ci != cj {
+			return ci > cj
+		}
+		return idI < idJ
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortByPopularity(t *testing.T) {
+	pop := newPopularity()
+	for id, views := range map[string]int{"B": 3, "D": 1, "C": 3, "E": 5} {
+		for range views {
+			pop.recordView(id)
+		}
+	}
+	counts := pop.snapshot()
+	// views after the snapshot do not change the order of the listing
+	for range 6 {
+		pop.recordView("A")
+	}
+
+	products := []Product{{ID: "A"}, {ID: "B"}, {ID: "C"}, {ID: "D"}, {ID: "E"}, {ID: "F"}}
+	sortByPopularity(products, func(p Product) string { return p.ID }, counts)
+
+	var got []string
+	for _, p := range products {
+		got = append(got, p.ID)
+	}
+	if want := []string{"E", "B", "C", "D", "A", "F"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by popularity = %v, want %v", got, want)
+	}
+
+	var unset *popularity
+	unset.recordView("A")
+	if len(unset.snapshot()) != 0 {
+		t.Error("a nil popularity counted a view")
+	}
+}
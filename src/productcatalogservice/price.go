@@ -0,0 +1,44 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"math"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/money"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// priceMultiplier returns the catalogPriceMultiplier flag, which scales the
+// prices GetProduct and ListProducts return to demo surge pricing and
+// markdowns. Negative and non-finite values are ignored.
+func priceMultiplier(ctx context.Context) float64 {
+	client := openfeature.NewClient("productCatalog")
+	multiplier, _ := client.FloatValue(ctx, "catalogPriceMultiplier", 1, openfeature.EvaluationContext{})
+	if multiplier < 0 || math.IsNaN(multiplier) || math.IsInf(multiplier, 0) {
+		return 1
+	}
+	return multiplier
+}
+
+// applyPriceMultiplier scales the price of products by multiplier, rounded to
+// the cent, and records the multiplier on the span of ctx. Products whose
+// price cannot be scaled keep it.
+func applyPriceMultiplier(ctx context.Context, multiplier float64, products ...*pb.Product) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("app.product.price_multiplier", multiplier))
+	if multiplier == 1 {
+		return
+	}
+	for _, product := range products {
+		price, err := money.Scale(product.PriceUsd, multiplier)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to adjust product price", "product_id", product.Id, "error", err.Error())
+			continue
+		}
+		product.PriceUsd = price
+	}
+}
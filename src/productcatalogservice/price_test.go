@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestApplyPriceMultiplier(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier float64
+		want       []*pb.Money
+	}{
+		{"unchanged", 1, []*pb.Money{usd(101, 960000000), usd(19, 990000000)}},
+		{"surge", 1.15, []*pb.Money{usd(117, 250000000), usd(22, 990000000)}},
+		{"markdown", 0.85, []*pb.Money{usd(86, 670000000), usd(16, 990000000)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := []*pb.Product{
+				{Id: "OLJCESPC7Z", PriceUsd: usd(101, 960000000)},
+				{Id: "LS4PSXUNUM", PriceUsd: usd(19, 990000000)},
+			}
+			applyPriceMultiplier(context.Background(), tt.multiplier, products...)
+			for i, product := range products {
+				if !proto.Equal(product.PriceUsd, tt.want[i]) {
+					t.Errorf("price of %s = %v, want %v", product.Id, product.PriceUsd, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPriceMultiplierDefaultsToOne(t *testing.T) {
+	if got := priceMultiplier(context.Background()); got != 1 {
+		t.Errorf("priceMultiplier() without a flag provider = %v, want 1", got)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultProductCacheSize = 1024
+
+// productCacheKey identifies a GetProduct response. Requests carry no
+// currency, prices are always in USD, so the image variant is the only field
+// shaping the response besides the ID.
+type productCacheKey struct {
+	id        string
+	imageSize string
+}
+
+// productCache holds the products served by GetProduct for a short time, so
+// repeated lookups of the same product skip the database. The cache is
+// invalidated when ImportProducts writes to the database; changes made to the
+// database by other means show up once the entries expire. Reloading the
+// product files does not touch it, as GetProduct does not read them. A nil
+// *productCache never caches.
+type productCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu         sync.Mutex
+	generation uint64 // incremented by every invalidation
+	entries    map[productCacheKey]productCacheEntry
+	hits       int
+}
+
+type productCacheEntry struct {
+	product *pb.Product
+	expires time.Time
+}
+
+func newProductCache(size int, ttl time.Duration) *productCache {
+	return &productCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[productCacheKey]productCacheEntry),
+	}
+}
+
+// productCacheFromEnv reads CATALOG_PRODUCT_CACHE_TTL_MS and
+// CATALOG_PRODUCT_CACHE_SIZE. Caching is disabled when the TTL is unset.
+func productCacheFromEnv() *productCache {
+	ttl := envPositiveInt("CATALOG_PRODUCT_CACHE_TTL_MS", 0)
+	if ttl == 0 {
+		return nil
+	}
+	return newProductCache(envPositiveInt("CATALOG_PRODUCT_CACHE_SIZE", defaultProductCacheSize), time.Duration(ttl)*time.Millisecond)
+}
+
+// current returns the generation of the cache, to be passed to put along with
+// a product read from the database after the call.
+func (c *productCache) current() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// invalidate drops every entry, once the products in the database changed.
+func (c *productCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// get returns a copy of the product cached for key, which the caller may
+// modify.
+func (c *productCache) get(key productCacheKey) (*pb.Product, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.hits++
+	return proto.Clone(entry.product).(*pb.Product), true
+}
+
+// put caches a copy of product for key, read from the database at
+// generation. Nothing is cached once the cache holds size entries, until they
+// expire or the cache is invalidated.
+func (c *productCache) put(key productCacheKey, generation uint64, product *pb.Product) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		// read before the database last changed
+		return
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.dropExpired()
+		if len(c.entries) >= c.size {
+			return
+		}
+	}
+	c.entries[key] = productCacheEntry{product: proto.Clone(product).(*pb.Product), expires: c.now().Add(c.ttl)}
+}
+
+func (c *productCache) dropExpired() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestProductCache(t *testing.T) {
+	now := time.Now()
+	c := newProductCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+	key := productCacheKey{id: "A", imageSize: "thumbnail"}
+
+	c.put(key, c.current(), &pb.Product{Id: "A", Name: "Telescope"})
+	got, ok := c.get(key)
+	if !ok || got.Name != "Telescope" {
+		t.Fatalf("get() = %v, %v, want the cached product", got, ok)
+	}
+	got.Name = "Modified"
+	if again, _ := c.get(key); again.Name != "Telescope" {
+		t.Errorf("modifying a cached product changed the cache: %v", again)
+	}
+	if _, ok := c.get(productCacheKey{id: "A"}); ok {
+		t.Error("get() for another image size hit the cache")
+	}
+
+	// a full cache stops caching until entries expire
+	c.put(productCacheKey{id: "B"}, c.current(), &pb.Product{Id: "B"})
+	c.put(productCacheKey{id: "C"}, c.current(), &pb.Product{Id: "C"})
+	if _, ok := c.get(productCacheKey{id: "C"}); ok {
+		t.Error("put() cached past the size of the cache")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get(key); ok {
+		t.Error("get() returned an expired product")
+	}
+	c.put(productCacheKey{id: "C"}, c.current(), &pb.Product{Id: "C"})
+	if _, ok := c.get(productCacheKey{id: "C"}); !ok {
+		t.Error("put() did not cache after the other entries expired")
+	}
+
+	// a database change invalidates every entry
+	generation := c.current()
+	c.invalidate()
+	if _, ok := c.get(productCacheKey{id: "C"}); ok {
+		t.Error("get() after invalidate() hit the cache")
+	}
+	c.put(productCacheKey{id: "C"}, generation, &pb.Product{Id: "C"})
+	if _, ok := c.get(productCacheKey{id: "C"}); ok {
+		t.Error("put() cached a product read before the database changed")
+	}
+
+	var disabled *productCache
+	disabled.invalidate()
+	disabled.put(key, disabled.current(), &pb.Product{Id: "A"})
+	if _, ok := disabled.get(key); ok {
+		t.Error("a nil productCache cached a product")
+	}
+}
+
+func TestGetProductFromCache(t *testing.T) {
+	t.Setenv("CATALOG_ADMIN_TOKEN", "secret")
+	catalog := newCatalogStore([]*pb.Product{{Id: "A"}})
+	cache := newProductCache(10, time.Minute)
+	cache.put(productCacheKey{id: "A"}, cache.current(), &pb.Product{Id: "A", Name: "Telescope", PriceUsd: usd(10, 0)})
+	svc := &productCatalog{catalog: catalog, productCache: cache}
+
+	// the database is not set up, so only a cache hit can succeed
+	for range 2 {
+		product, err := svc.GetProduct(context.Background(), &pb.GetProductRequest{Id: "A"})
+		if err != nil || product.Name != "Telescope" || product.PriceUsd.Units != 10 {
+			t.Fatalf("GetProduct() = %v, %v, want the cached product", product, err)
+		}
+	}
+	if cache.hits != 2 {
+		t.Errorf("cache hits = %d, want 2", cache.hits)
+	}
+
+	// GetProduct reads the database, which a reload of the files leaves as is
+	catalog.set([]*pb.Product{{Id: "A"}})
+	if _, ok := cache.get(productCacheKey{id: "A"}); !ok {
+		t.Error("product no longer cached after a catalog reload")
+	}
+
+	stream := &fakeImportStream{ctx: adminContext("secret"), products: []*pb.Product{{Id: "A", Name: "Refractor", PriceUsd: usd(12, 0)}}}
+	if err := svc.ImportProducts(stream); err != nil || !stream.resp.Applied {
+		t.Fatalf("ImportProducts() = %v, %v, want the import applied", stream.resp, err)
+	}
+	if _, ok := cache.get(productCacheKey{id: "A"}); ok {
+		t.Error("product still cached after an import")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// productFormat is the layout of a product file.
+type productFormat string
+
+const (
+	// formatWrapped is a ListProductsResponse: {"products": [...]}.
+	formatWrapped productFormat = "wrapped"
+	// formatArray is a bare JSON array of products.
+	formatArray productFormat = "array"
+	// formatNDJSON is one product per line.
+	formatNDJSON productFormat = "ndjson"
+)
+
+// isProductFile reports whether name has the extension of a product file.
+func isProductFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".json", ".ndjson", ".jsonl":
+		return true
+	}
+	return false
+}
+
+// productFileFormat returns the format of the product file name. The
+// PRODUCT_CATALOG_FORMAT environment variable forces a format for every file;
+// otherwise .ndjson and .jsonl files are newline-delimited, and .json files are
+// a bare array when they start with '[' and a wrapped response when they don't.
+func productFileFormat(name string, data []byte) (productFormat, error) {
+	if f := os.Getenv("PRODUCT_CATALOG_FORMAT"); f != "" {
+		switch format := productFormat(f); format {
+		case formatWrapped, formatArray, formatNDJSON:
+			return format, nil
+		default:
+			return "", fmt.Errorf("unknown PRODUCT_CATALOG_FORMAT %q", f)
+		}
+	}
+
+	if filepath.Ext(name) != ".json" {
+		return formatNDJSON, nil
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return formatArray, nil
+	}
+	return formatWrapped, nil
+}
+
+// productJSONOptions returns how products are decoded. Fields unknown to this
+// version of the Product schema are ignored, so catalog files written for a
+// newer schema still load, unless CATALOG_STRICT_JSON is set.
+func productJSONOptions() protojson.UnmarshalOptions {
+	strict, _ := strconv.ParseBool(os.Getenv("CATALOG_STRICT_JSON"))
+	return protojson.UnmarshalOptions{DiscardUnknown: !strict}
+}
+
+// parseProducts decodes the products in data, which is in the given format.
+func parseProducts(data []byte, format productFormat) ([]*pb.Product, error) {
+	opts := productJSONOptions()
+	switch format {
+	case formatWrapped:
+		var res pb.ListProductsResponse
+		if err := opts.Unmarshal(data, &res); err != nil {
+			return nil, err
+		}
+		return res.Products, nil
+
+	case formatArray:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		products := make([]*pb.Product, 0, len(raw))
+		for i, r := range raw {
+			product := new(pb.Product)
+			if err := opts.Unmarshal(r, product); err != nil {
+				return nil, fmt.Errorf("product %d: %w", i, err)
+			}
+			products = append(products, product)
+		}
+		return products, nil
+
+	case formatNDJSON:
+		var products []*pb.Product
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			product := new(pb.Product)
+			if err := opts.Unmarshal(scanner.Bytes(), product); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			products = append(products, product)
+		}
+		return products, scanner.Err()
+	}
+	return nil, fmt.Errorf("unknown product format %q", format)
+}
+
+// productLimit caps the number of products loaded from the product files.
+type productLimit struct {
+	max      int  // 0 for no limit
+	truncate bool // drop the products past max instead of failing the load
+}
+
+// productLimitFromEnv reads CATALOG_MAX_PRODUCTS and CATALOG_MAX_PRODUCTS_MODE,
+// which is "error" (the default) to fail loading a larger catalog or
+// "truncate" to keep the first products up to the limit.
+func productLimitFromEnv() productLimit {
+	limit := productLimit{max: envPositiveInt("CATALOG_MAX_PRODUCTS", 0)}
+	switch mode := os.Getenv("CATALOG_MAX_PRODUCTS_MODE"); mode {
+	case "truncate":
+		limit.truncate = true
+	case "", "error":
+	default:
+		logger.Warn("Ignoring unknown CATALOG_MAX_PRODUCTS_MODE, failing on larger catalogs", "value", mode)
+	}
+	return limit
+}
+
+// sortCatalog orders products by ID so the catalog does not depend on how
+// products are spread across files. Setting CATALOG_SORT to "file" keeps the
+// order of the files instead.
+func sortCatalog(products []*pb.Product) {
+	if sortByFileFromEnv() {
+		return
+	}
+	sort.SliceStable(products, func(i, j int) bool { return products[i].Id < products[j].Id })
+}
+
+// sortByFileFromEnv reports whether CATALOG_SORT is "file", which keeps the
+// catalog, and the products listed from the database, in the order of the
+// product files.
+func sortByFileFromEnv() bool {
+	switch order := os.Getenv("CATALOG_SORT"); order {
+	case "file":
+		return true
+	case "", "id":
+	default:
+		logger.Warn("Ignoring unknown CATALOG_SORT, sorting by id", "value", order)
+	}
+	return false
+}
+
+// sortByCatalogOrder orders products like the in-memory catalog indexed by
+// index. Products missing from the catalog keep their relative order after
+// the others.
+func sortByCatalogOrder[T any](products []T, id func(T) string, index map[string]int) {
+	position := func(product T) int {
+		if i, ok := index[id(product)]; ok {
+			return i
+		}
+		return len(index)
+	}
+	sort.SliceStable(products, func(i, j int) bool { return position(products[i]) < position(products[j]) })
+}
@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func writeProductFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadProductDirFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name:    "wrapped response",
+			file:    "products.json",
+			content: `{"products": [{"id": "A", "name": "Alpha", "priceUsd": {"currencyCode": "USD", "units": 1}}, {"id": "B", "name": "Beta"}]}`,
+		},
+		{
+			name:    "bare array",
+			file:    "products.json",
+			content: "\n  [{\"id\": \"A\", \"name\": \"Alpha\", \"priceUsd\": {\"currencyCode\": \"USD\", \"units\": 1}}, {\"id\": \"B\", \"name\": \"Beta\"}]",
+		},
+		{
+			name:    "newline-delimited",
+			file:    "products.ndjson",
+			content: "{\"id\": \"A\", \"name\": \"Alpha\", \"priceUsd\": {\"currencyCode\": \"USD\", \"units\": 1}}\n\n{\"id\": \"B\", \"name\": \"Beta\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeProductFile(t, dir, tt.file, tt.content)
+			writeProductFile(t, dir, "README.md", "not a product file")
+
+			products, err := readProductDir(dir)
+			if err != nil {
+				t.Fatalf("readProductDir: %v", err)
+			}
+			if len(products) != 2 || products[0].Id != "A" || products[1].Name != "Beta" {
+				t.Fatalf("products = %v, want A and B", products)
+			}
+			if products[0].PriceUsd.GetUnits() != 1 {
+				t.Errorf("price of A = %v, want 1 USD", products[0].PriceUsd)
+			}
+		})
+	}
+}
+
+func TestReadProductDirFormatOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", "{\"id\": \"A\"}\n{\"id\": \"B\"}\n")
+
+	if _, err := readProductDir(dir); err == nil {
+		t.Error("readProductDir parsed newline-delimited products as a wrapped response")
+	}
+
+	t.Setenv("PRODUCT_CATALOG_FORMAT", "ndjson")
+	products, err := readProductDir(dir)
+	if err != nil || len(products) != 2 {
+		t.Errorf("readProductDir with PRODUCT_CATALOG_FORMAT=ndjson = %v, %v, want 2 products", products, err)
+	}
+
+	t.Setenv("PRODUCT_CATALOG_FORMAT", "yaml")
+	if _, err := readProductDir(dir); err == nil {
+		t.Error("readProductDir accepted an unknown PRODUCT_CATALOG_FORMAT")
+	}
+}
+
+func TestReadProductDirMaxProducts(t *testing.T) {
+	dir := t.TempDir()
+	writeProductFile(t, dir, "a.json", `[{"id": "A"}, {"id": "B"}]`)
+	writeProductFile(t, dir, "b.json", `[{"id": "C"}, {"id": "D"}]`)
+
+	t.Setenv("CATALOG_MAX_PRODUCTS", "4")
+	if products, err := readProductDir(dir); err != nil || len(products) != 4 {
+		t.Errorf("readProductDir at the limit = %v, %v, want 4 products", products, err)
+	}
+
+	t.Setenv("CATALOG_MAX_PRODUCTS", "3")
+	_, err := readProductDir(dir)
+	if err == nil || !strings.HasPrefix(err.Error(), "b.json:") {
+		t.Errorf("readProductDir past the limit error = %v, want it to name b.json", err)
+	}
+
+	t.Setenv("CATALOG_MAX_PRODUCTS_MODE", "truncate")
+	products, err := readProductDir(dir)
+	if err != nil {
+		t.Fatalf("readProductDir: %v", err)
+	}
+	var ids []string
+	for _, p := range products {
+		ids = append(ids, p.Id)
+	}
+	if want := []string{"A", "B", "C"}; !slices.Equal(ids, want) {
+		t.Errorf("truncated catalog = %v, want %v", ids, want)
+	}
+}
+
+func TestParseProductsUnknownFields(t *testing.T) {
+	files := map[productFormat]string{
+		formatWrapped: `{"products": [{"id": "A", "rating": 4.5}], "total": 1}`,
+		formatArray:   `[{"id": "A", "rating": 4.5}]`,
+		formatNDJSON:  `{"id": "A", "rating": 4.5}`,
+	}
+	for format, data := range files {
+		products, err := parseProducts([]byte(data), format)
+		if err != nil || len(products) != 1 || products[0].Id != "A" {
+			t.Errorf("parseProducts(%s) with an unknown field = %v, %v, want product A", format, products, err)
+		}
+	}
+
+	t.Setenv("CATALOG_STRICT_JSON", "true")
+	for format, data := range files {
+		if _, err := parseProducts([]byte(data), format); err == nil {
+			t.Errorf("parseProducts(%s) with CATALOG_STRICT_JSON accepted an unknown field", format)
+		}
+	}
+}
+
+func TestParseProductsReportsBadLine(t *testing.T) {
+	_, err := parseProducts([]byte("{\"id\": \"A\"}\n{\"id\": 5}\n"), formatNDJSON)
+	if err == nil {
+		t.Fatal("parseProducts accepted an invalid product")
+	}
+	if got := err.Error(); !strings.HasPrefix(got, "line 2:") {
+		t.Errorf("error = %q, want it to name line 2", got)
+	}
+}
+
+func TestReadProductDirOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeProductFile(t, dir, "a.json", `[{"id": "ZZZ"}, {"id": "MMM"}]`)
+	writeProductFile(t, dir, "b.ndjson", "{\"id\": \"AAA\"}\n{\"id\": \"QQQ\"}\n")
+
+	ids := func() []string {
+		t.Helper()
+		products, err := readProductDir(dir)
+		if err != nil {
+			t.Fatalf("readProductDir: %v", err)
+		}
+		var ids []string
+		for _, p := range products {
+			ids = append(ids, p.Id)
+		}
+		return ids
+	}
+
+	first, second := ids(), ids()
+	want := []string{"AAA", "MMM", "QQQ", "ZZZ"}
+	if !slices.Equal(first, want) || !slices.Equal(second, want) {
+		t.Errorf("loads = %v and %v, want both %v", first, second, want)
+	}
+
+	t.Setenv("CATALOG_SORT", "file")
+	if got := ids(); !slices.Equal(got, []string{"ZZZ", "MMM", "AAA", "QQQ"}) {
+		t.Errorf("load with CATALOG_SORT=file = %v, want file order", got)
+	}
+}
+
+func TestSortByCatalogOrder(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "ZZZ"}, {Id: "MMM"}, {Id: "AAA"}})
+	_, index, _ := catalog.snapshot()
+
+	// as read from the database, ordered by id
+	rows := []Product{{ID: "AAA"}, {ID: "BBB"}, {ID: "MMM"}, {ID: "ZZZ"}}
+	sortByCatalogOrder(rows, func(p Product) string { return p.ID }, index)
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.ID)
+	}
+	if want := []string{"ZZZ", "MMM", "AAA", "BBB"}; !slices.Equal(got, want) {
+		t.Errorf("sortByCatalogOrder = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sort"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetCartRecommendations returns the products that share at least one
+// category with the products in the cart, excluding the cart's own products.
+// Products sharing more categories come first, ties are broken by ID.
+func (p *productCatalog) GetCartRecommendations(ctx context.Context, req *pb.GetCartRecommendationsRequest) (*pb.GetCartRecommendationsResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	recommendations := recommendForCart(p.catalog.current(), req.GetProductIds())
+	if n := int(req.GetMaxResults()); n > 0 && len(recommendations) > n {
+		recommendations = recommendations[:n]
+	}
+
+	span.SetAttributes(
+		attribute.Int("app.cart.items.count", len(req.GetProductIds())),
+		attribute.Int("app.products_recommendations.count", len(recommendations)),
+	)
+	return &pb.GetCartRecommendationsResponse{Products: p.sanitized(recommendations)}, nil
+}
+
+func recommendForCart(products []*pb.Product, cartIDs []string) []*pb.Product {
+	inCart := make(map[string]bool, len(cartIDs))
+	for _, id := range cartIDs {
+		inCart[id] = true
+	}
+
+	cartCategories := make(map[string]bool)
+	for _, product := range products {
+		if inCart[product.Id] {
+			for _, c := range product.Categories {
+				cartCategories[c] = true
+			}
+		}
+	}
+
+	type candidate struct {
+		product *pb.Product
+		overlap int
+	}
+	var candidates []candidate
+	for _, product := range products {
+		if inCart[product.Id] {
+			continue
+		}
+		overlap := 0
+		seen := make(map[string]bool, len(product.Categories))
+		for _, c := range product.Categories {
+			if cartCategories[c] && !seen[c] {
+				seen[c] = true
+				overlap++
+			}
+		}
+		if overlap > 0 {
+			candidates = append(candidates, candidate{product, overlap})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].overlap != candidates[j].overlap {
+			return candidates[i].overlap > candidates[j].overlap
+		}
+		return candidates[i].product.Id < candidates[j].product.Id
+	})
+
+	result := make([]*pb.Product, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.product
+	}
+	return result
+}
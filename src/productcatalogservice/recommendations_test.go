@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestGetCartRecommendations(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "SCOPE", Categories: []string{"telescopes", "travel"}},
+		{Id: "BINOS", Categories: []string{"binoculars", "travel"}},
+		{Id: "TRIPOD", Categories: []string{"accessories", "telescopes", "travel"}},
+		{Id: "LENS", Categories: []string{"accessories", "telescopes"}},
+		{Id: "BAG", Categories: []string{"travel"}},
+		{Id: "BOOK", Categories: []string{"books"}},
+	})
+	svc := &productCatalog{catalog: catalog}
+
+	ids := func(req *pb.GetCartRecommendationsRequest) []string {
+		t.Helper()
+		resp, err := svc.GetCartRecommendations(context.Background(), req)
+		if err != nil {
+			t.Fatalf("GetCartRecommendations: %v", err)
+		}
+		var got []string
+		for _, p := range resp.Products {
+			got = append(got, p.Id)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.GetCartRecommendationsRequest
+		want []string
+	}{
+		{
+			name: "ranked by overlap then id",
+			req:  &pb.GetCartRecommendationsRequest{ProductIds: []string{"SCOPE"}},
+			want: []string{"TRIPOD", "BAG", "BINOS", "LENS"},
+		},
+		{
+			name: "cart products are excluded",
+			req:  &pb.GetCartRecommendationsRequest{ProductIds: []string{"SCOPE", "TRIPOD"}},
+			want: []string{"LENS", "BAG", "BINOS"},
+		},
+		{
+			name: "limited",
+			req:  &pb.GetCartRecommendationsRequest{ProductIds: []string{"SCOPE"}, MaxResults: 2},
+			want: []string{"TRIPOD", "BAG"},
+		},
+		{
+			name: "disjoint categories",
+			req:  &pb.GetCartRecommendationsRequest{ProductIds: []string{"BOOK"}},
+			want: nil,
+		},
+		{
+			name: "unknown products",
+			req:  &pb.GetCartRecommendationsRequest{ProductIds: []string{"MISSING"}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(tt.req)
+			if len(got) != len(tt.want) {
+				t.Fatalf("recommendations = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("recommendations = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestRegisterReflection(t *testing.T) {
+	for _, tt := range []struct {
+		reflection  string
+		environment string
+		want        bool
+	}{
+		{reflection: "", environment: "development", want: true},
+		{reflection: "", environment: "production", want: false},
+		{reflection: "true", environment: "production", want: true},
+		{reflection: "false", environment: "development", want: false},
+	} {
+		t.Setenv("GRPC_REFLECTION", tt.reflection)
+		t.Setenv("ENVIRONMENT", tt.environment)
+		srv := grpc.NewServer()
+		if got := registerReflection(srv); got != tt.want {
+			t.Errorf("registerReflection() with GRPC_REFLECTION=%q in %s = %v, want %v", tt.reflection, tt.environment, got, tt.want)
+		}
+		_, registered := srv.GetServiceInfo()["grpc.reflection.v1.ServerReflection"]
+		if registered != tt.want {
+			t.Errorf("reflection service registered = %v with GRPC_REFLECTION=%q in %s", registered, tt.reflection, tt.environment)
+		}
+	}
+}
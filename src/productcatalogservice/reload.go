@@ -0,0 +1,192 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// catalogReloader polls a product directory and installs its products in
+// catalog whenever the content of its product files changes. Only the
+// in-memory catalog is swapped, which serves search, featured products and
+// recommendations. ListProducts and GetProduct keep serving the database,
+// whose products are only changed through ImportProducts.
+type catalogReloader struct {
+	catalog  *catalogStore
+	dir      string
+	interval time.Duration
+	hash     string
+	metrics  *reloadMetrics
+
+	mu       sync.Mutex
+	loadedAt time.Time // when the files were last known to match the catalog
+	lastErr  error     // the error of the last reload, nil if it succeeded
+}
+
+// reloadIntervalFromEnv reads PRODUCT_CATALOG_RELOAD_INTERVAL as a Go
+// duration such as "30s". Polling is disabled when it is unset or invalid.
+// Edited product files do not reach the database, see catalogReloader.
+func reloadIntervalFromEnv() time.Duration {
+	s := os.Getenv("PRODUCT_CATALOG_RELOAD_INTERVAL")
+	if s == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		logger.Warn("Ignoring invalid PRODUCT_CATALOG_RELOAD_INTERVAL, catalog polling is disabled", "value", s)
+		return 0
+	}
+	return d
+}
+
+// newCatalogReloader returns a reloader of dir into catalog. The current
+// content of dir is assumed to be installed already.
+func newCatalogReloader(catalog *catalogStore, dir string, interval time.Duration) *catalogReloader {
+	r := &catalogReloader{catalog: catalog, dir: dir, interval: interval, loadedAt: time.Now()}
+	if hash, err := hashProductDir(dir); err == nil {
+		r.hash = hash
+	}
+	return r
+}
+
+// run polls until ctx is done.
+func (r *catalogReloader) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.reloadIfChanged(); err != nil {
+				logger.Error("Failed to reload product catalog, keeping the current one", "error", err.Error())
+			}
+		}
+	}
+}
+
+// reloadIfChanged reads the product directory and swaps the catalog if the
+// product files changed since the last reload, keeping the products imported
+// since startup. It reports whether the catalog was swapped.
+func (r *catalogReloader) reloadIfChanged() (swapped bool, err error) {
+	checked := time.Now()
+	defer func() { r.recordReload(checked, err) }()
+
+	hash, err := hashProductDir(r.dir)
+	if err != nil {
+		return false, err
+	}
+	if hash == r.hash {
+		return false, nil
+	}
+
+	products, err := readProductDir(r.dir)
+	if err != nil {
+		return false, err
+	}
+	oldProducts, oldIndex, _ := r.catalog.snapshot()
+	r.catalog.reload(products)
+	r.hash = hash
+	newProducts, newIndex, _ := r.catalog.snapshot()
+
+	diff := diffCatalogs(oldProducts, oldIndex, newProducts, newIndex)
+	r.metrics.record(context.Background(), diff)
+	logger.Info("Reloaded product catalog", "amount", len(products),
+		"added", diff.added, "removed", diff.removed, "updated", diff.updated)
+	return true, nil
+}
+
+func (r *catalogReloader) recordReload(checked time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	if err == nil {
+		r.loadedAt = checked
+	}
+}
+
+// freshnessCheckFromEnv reads CATALOG_FRESHNESS_CHECK, which makes the health
+// check report a stale catalog. See catalogReloader.stale.
+func freshnessCheckFromEnv() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("CATALOG_FRESHNESS_CHECK"))
+	return enabled
+}
+
+// stale reports whether the product files changed after the catalog was last
+// loaded from them and reloading them is failing, meaning the catalog is
+// stuck on an outdated version. A nil *catalogReloader is never stale.
+func (r *catalogReloader) stale() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	loadedAt, lastErr := r.loadedAt, r.lastErr
+	r.mu.Unlock()
+	if lastErr == nil {
+		return false
+	}
+
+	modified, err := newestProductFile(r.dir)
+	if err != nil {
+		// the directory cannot be read either, which is what the reload failed on
+		return true
+	}
+	return modified.After(loadedAt)
+}
+
+// newestProductFile returns the latest modification time of the product files
+// in dir.
+func newestProductFile(dir string) (time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var newest time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !isProductFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// hashProductDir returns a hash of the names and contents of the product files
+// in dir.
+func hashProductDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, entry := range entries {
+		if entry.IsDir() || !isProductFile(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(entry.Name()))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCatalogReloaderPicksUpChanges(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "A"}})
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}]`)
+
+	r := newCatalogReloader(catalog, dir, 5*time.Millisecond)
+	if swapped, err := r.reloadIfChanged(); swapped || err != nil {
+		t.Fatalf("reloadIfChanged() on unchanged files = %v, %v, want no swap", swapped, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		r.run(ctx)
+		close(done)
+	}()
+
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}, {"id": "B"}]`)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(catalog.current()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("catalog = %v, the changed file was not picked up", catalog.current())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestCatalogReloaderKeepsImportedProducts(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "A", Name: "A"}, {Id: "B", Name: "B"}})
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "A", "name": "A"}, {"id": "B", "name": "B"}]`)
+	r := newCatalogReloader(catalog, dir, time.Hour)
+
+	catalog.merge([]*pb.Product{{Id: "B", Name: "Imported B"}, {Id: "C", Name: "Imported C"}})
+	writeProductFile(t, dir, "products.json", `[{"id": "A", "name": "New A"}, {"id": "B", "name": "New B"}, {"id": "D", "name": "D"}]`)
+	if swapped, err := r.reloadIfChanged(); !swapped || err != nil {
+		t.Fatalf("reloadIfChanged() = %v, %v, want a swap", swapped, err)
+	}
+
+	products, index, _ := catalog.snapshot()
+	names := make(map[string]string)
+	for id, i := range index {
+		names[id] = products[i].Name
+	}
+	want := map[string]string{"A": "New A", "B": "Imported B", "C": "Imported C", "D": "D"}
+	if len(products) != len(want) {
+		t.Errorf("catalog after reload has %d products, want %d", len(products), len(want))
+	}
+	for id, name := range want {
+		if names[id] != name {
+			t.Errorf("product %s after reload is named %q, want %q", id, names[id], name)
+		}
+	}
+}
+
+func TestCatalogReloaderKeepsCatalogOnError(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "A"}})
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}]`)
+
+	r := newCatalogReloader(catalog, dir, time.Hour)
+	writeProductFile(t, dir, "products.json", `[{"id": `)
+	if swapped, err := r.reloadIfChanged(); swapped || err == nil {
+		t.Errorf("reloadIfChanged() on a broken file = %v, %v, want an error", swapped, err)
+	}
+	if got := catalog.current(); len(got) != 1 || got[0].Id != "A" {
+		t.Errorf("catalog after a failed reload = %v, want the previous catalog", got)
+	}
+}
+
+func TestCheckReportsStaleCatalog(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "A"}})
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}]`)
+	r := newCatalogReloader(catalog, dir, time.Hour)
+	svc := &productCatalog{catalog: catalog}
+	svc.reloader.Store(r)
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := svc.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		return resp.Status
+	}
+	if got := check(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check() with a fresh catalog = %v, want SERVING", got)
+	}
+
+	// a newer product file that fails to reload leaves the catalog stale
+	writeProductFile(t, dir, "products.json", `[{"id": `)
+	newer := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "products.json"), newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reloadIfChanged(); err == nil {
+		t.Fatal("reloadIfChanged() on a broken file succeeded")
+	}
+	if got := check(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Check() with a stale catalog = %v, want NOT_SERVING", got)
+	}
+
+	// fixing the file recovers on the next reload
+	writeProductFile(t, dir, "products.json", `[{"id": "A"}, {"id": "B"}]`)
+	if _, err := r.reloadIfChanged(); err != nil {
+		t.Fatalf("reloadIfChanged() error = %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "products.json"), newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	if got := check(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check() after a successful reload = %v, want SERVING", got)
+	}
+}
+
+func TestReloadIntervalFromEnv(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "30s": 30 * time.Second, "soon": 0, "-1s": 0} {
+		t.Setenv("PRODUCT_CATALOG_RELOAD_INTERVAL", value)
+		if got := reloadIntervalFromEnv(); got != want {
+			t.Errorf("reloadIntervalFromEnv() with %q = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMaxQueryLen = 256
+
+// normalizeQuery folds queries that match the same products onto one key.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// matchesQuery reports whether the normalized query appears in the fields of
+// product selected by fields. Tags must equal the query rather than contain it.
+func matchesQuery(product *pb.Product, query string, fields pb.SearchFields) bool {
+	switch fields {
+	case pb.SearchFields_SEARCH_FIELDS_NAME:
+		return strings.Contains(strings.ToLower(product.Name), query)
+	case pb.SearchFields_SEARCH_FIELDS_DESCRIPTION:
+		return strings.Contains(strings.ToLower(product.Description), query)
+	case pb.SearchFields_SEARCH_FIELDS_NAME_AND_DESCRIPTION:
+		return strings.Contains(strings.ToLower(product.Name), query) ||
+			strings.Contains(strings.ToLower(product.Description), query)
+	case pb.SearchFields_SEARCH_FIELDS_TAGS:
+		return hasTag(product, query)
+	default:
+		return strings.Contains(strings.ToLower(product.Name), query) ||
+			strings.Contains(strings.ToLower(product.Description), query) ||
+			hasTag(product, query)
+	}
+}
+
+// Relevance scores of the ways a product can match a query, from the
+// strongest to the weakest.
+const (
+	scoreExact           = 1.0 // the whole name, or a tag
+	scoreNameWord        = 0.8
+	scoreNameText        = 0.6
+	scoreDescriptionWord = 0.4
+	scoreDescriptionText = 0.2
+)
+
+// matchScore returns the relevance of product to the normalized query over
+// fields, from 0 for no match to 1 for a match of its whole name or a tag. It
+// is positive exactly when matchesQuery reports a match.
+func matchScore(product *pb.Product, query string, fields pb.SearchFields) float32 {
+	var score float32
+	if fields != pb.SearchFields_SEARCH_FIELDS_DESCRIPTION && fields != pb.SearchFields_SEARCH_FIELDS_TAGS {
+		score = max(score, textScore(product.Name, query, scoreNameWord, scoreNameText))
+		if normalizeQuery(product.Name) == query {
+			score = scoreExact
+		}
+	}
+	if fields != pb.SearchFields_SEARCH_FIELDS_NAME && fields != pb.SearchFields_SEARCH_FIELDS_TAGS {
+		score = max(score, textScore(product.Description, query, scoreDescriptionWord, scoreDescriptionText))
+	}
+	if fields == pb.SearchFields_SEARCH_FIELDS_UNSPECIFIED || fields == pb.SearchFields_SEARCH_FIELDS_TAGS {
+		if hasTag(product, query) {
+			score = scoreExact
+		}
+	}
+	return score
+}
+
+// textScore scores a match of query in text: word when it matches whole
+// words, partial when it matches only part of one, and 0 otherwise.
+func textScore(text, query string, word, partial float32) float32 {
+	if !strings.Contains(strings.ToLower(text), query) {
+		return 0
+	}
+	if q := words(query); q != "" && strings.Contains(" "+words(text)+" ", " "+q+" ") {
+		return word
+	}
+	return partial
+}
+
+// words returns the words of s, lowercased and separated by single spaces,
+// ignoring punctuation.
+func words(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// searchMinScoreFromEnv reads CATALOG_SEARCH_MIN_SCORE, the minimum score of
+// the results of searches that do not set one. It defaults to 0, which keeps
+// every match.
+func searchMinScoreFromEnv() float32 {
+	s := os.Getenv("CATALOG_SEARCH_MIN_SCORE")
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil || !validMinScore(float32(v)) {
+		logger.Warn("Ignoring invalid environment variable", "key", "CATALOG_SEARCH_MIN_SCORE", "value", s)
+		return 0
+	}
+	return float32(v)
+}
+
+func validMinScore(score float32) bool {
+	return !math.IsNaN(float64(score)) && score >= 0 && score <= 1
+}
+
+// minScore returns the minimum score applied to a search requesting
+// requested, which is the server default when unset.
+func (p *productCatalog) minScore(requested float32) (float32, error) {
+	if !validMinScore(requested) {
+		return 0, status.Errorf(codes.InvalidArgument, "min_score %v is not between 0 and 1", requested)
+	}
+	if requested == 0 {
+		return p.searchMinScore, nil
+	}
+	return requested, nil
+}
+
+// hasTag reports whether product is tagged with the normalized tag.
+func hasTag(product *pb.Product, tag string) bool {
+	for _, t := range product.Tags {
+		if normalizeQuery(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// searchCacheKey identifies the results of a search for the normalized query
+// over fields.
+func searchCacheKey(query string, fields pb.SearchFields) string {
+	switch fields {
+	case pb.SearchFields_SEARCH_FIELDS_UNSPECIFIED:
+		return query
+	default:
+		return fields.String() + ":" + query
+	}
+}
+
+// checkQueryLength rejects normalized queries longer than maxQueryLen
+// characters, which would make matching them against every product expensive.
+func (p *productCatalog) checkQueryLength(query string) error {
+	limit := p.maxQueryLen
+	if limit <= 0 {
+		limit = defaultMaxQueryLen
+	}
+	if n := utf8.RuneCountInString(query); n > limit {
+		return status.Errorf(codes.InvalidArgument, "query is %d characters long, the maximum is %d", n, limit)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSearchCacheSize  = 256
+	defaultSearchCacheTTLMs = 60000
+)
+
+// searchCache is an LRU cache of the IDs of the products matching a search
+// query. Entries are only valid for the catalog version they were computed
+// from, so any change to the catalog invalidates the whole cache. A nil
+// *searchCache never caches.
+type searchCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	version uint64
+	order   *list.List // of *searchCacheEntry, most recently used first
+	entries map[string]*list.Element
+	hits    int
+}
+
+type searchCacheEntry struct {
+	key     string
+	ids     []string
+	expires time.Time
+}
+
+func newSearchCache(size int, ttl time.Duration) *searchCache {
+	return &searchCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// searchCacheFromEnv reads CATALOG_SEARCH_CACHE_SIZE and
+// CATALOG_SEARCH_CACHE_TTL_MS.
+func searchCacheFromEnv() *searchCache {
+	return newSearchCache(
+		envPositiveInt("CATALOG_SEARCH_CACHE_SIZE", defaultSearchCacheSize),
+		time.Duration(envPositiveInt("CATALOG_SEARCH_CACHE_TTL_MS", defaultSearchCacheTTLMs))*time.Millisecond,
+	)
+}
+
+// get returns the IDs cached for key at catalog version. A caller holding an
+// older snapshot than the cached entries misses.
+func (c *searchCache) get(key string, version uint64) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetIfStale(version)
+	if c.version != version {
+		return nil, false
+	}
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*searchCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return entry.ids, true
+}
+
+// put caches ids for key at catalog version, evicting the least recently used
+// entry when the cache is full.
+func (c *searchCache) put(key string, version uint64, ids []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetIfStale(version)
+	if c.version != version {
+		// computed from an older catalog than the cached entries
+		return
+	}
+	entry := &searchCacheEntry{key: key, ids: ids, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*searchCacheEntry).key)
+	}
+}
+
+// resetIfStale drops every entry when the catalog has moved past the version
+// they were computed from.
+func (c *searchCache) resetIfStale(version uint64) {
+	if version > c.version {
+		c.version = version
+		c.order.Init()
+		clear(c.entries)
+	}
+}
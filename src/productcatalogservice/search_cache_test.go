@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestSearchProductsCache(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "1", Name: "Red Telescope"},
+		{Id: "2", Name: "Binoculars", Description: "Not a telescope"},
+		{Id: "3", Name: "Tripod"},
+	})
+	cache := newSearchCache(10, time.Minute)
+	svc := &productCatalog{catalog: catalog, searchCache: cache}
+
+	search := func(query string) []string {
+		t.Helper()
+		resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: query})
+		if err != nil {
+			t.Fatalf("SearchProducts(%q): %v", query, err)
+		}
+		var ids []string
+		for _, p := range resp.Results {
+			ids = append(ids, p.Id)
+		}
+		return ids
+	}
+
+	if got := search("Telescope"); len(got) != 2 || cache.hits != 0 {
+		t.Fatalf("first search = %v with %d cache hits, want 2 results computed", got, cache.hits)
+	}
+	if got := search("  telescope "); len(got) != 2 || got[0] != "1" || got[1] != "2" || cache.hits != 1 {
+		t.Errorf("repeated search = %v with %d cache hits, want [1 2] from the cache", got, cache.hits)
+	}
+
+	catalog.set([]*pb.Product{{Id: "4", Name: "Blue telescope"}})
+	if got := search("telescope"); len(got) != 1 || got[0] != "4" || cache.hits != 1 {
+		t.Errorf("search after a catalog change = %v with %d cache hits, want [4] recomputed", got, cache.hits)
+	}
+}
+
+func TestSearchCacheEviction(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newSearchCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", 1, []string{"A"})
+	cache.put("b", 1, []string{"B"})
+	cache.get("a", 1)
+	cache.put("c", 1, []string{"C"})
+
+	if _, ok := cache.get("b", 1); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	if _, ok := cache.get("a", 1); !ok {
+		t.Error("recently used entry a was evicted")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("c", 1); ok {
+		t.Error("expired entry c was served")
+	}
+
+	cache.put("d", 2, []string{"D"})
+	cache.put("e", 1, []string{"E"})
+	if _, ok := cache.get("e", 1); ok {
+		t.Error("entry computed from an older catalog was cached")
+	}
+	if _, ok := cache.get("d", 1); ok {
+		t.Error("entry computed from a newer catalog was served for an older snapshot")
+	}
+	if _, ok := cache.get("d", 2); !ok {
+		t.Error("entry d was dropped by a lookup from an older snapshot")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// searchLimiter bounds the number of concurrent SearchProducts calls, as each
+// one scans the catalog, and counts the searches in flight. Searches beyond
+// the limit are rejected rather than queued. A limit of 0 admits every search,
+// and a nil *searchLimiter admits every search without counting it.
+type searchLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+}
+
+// searchLimiterFromEnv returns a limiter allowing CATALOG_SEARCH_CONCURRENCY
+// concurrent searches, unlimited by default.
+func searchLimiterFromEnv() *searchLimiter {
+	return newSearchLimiter(envPositiveInt("CATALOG_SEARCH_CONCURRENCY", 0))
+}
+
+func newSearchLimiter(limit int) *searchLimiter {
+	l := &searchLimiter{}
+	if limit > 0 {
+		l.slots = make(chan struct{}, limit)
+	}
+	return l
+}
+
+// acquire admits a search, returning a function that must be called when it
+// completes, or a ResourceExhausted status when the limit is reached.
+func (l *searchLimiter) acquire() (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			return nil, status.Errorf(codes.ResourceExhausted, "too many concurrent searches, limit is %d", cap(l.slots))
+		}
+	}
+	l.inFlight.Add(1)
+	return func() {
+		l.inFlight.Add(-1)
+		if l.slots != nil {
+			<-l.slots
+		}
+	}, nil
+}
+
+// registerGauge reports the searches in flight as
+// app.products_search.in_flight.
+func (l *searchLimiter) registerGauge(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge("app.products_search.in_flight",
+		metric.WithDescription("The number of SearchProducts calls in progress"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(l.inFlight.Load())
+			return nil
+		}))
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSearchLimiter(t *testing.T) {
+	l := newSearchLimiter(2)
+	first, err := l.acquire()
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	second, err := l.acquire()
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if got := l.inFlight.Load(); got != 2 {
+		t.Errorf("in flight = %d, want 2", got)
+	}
+	if _, err := l.acquire(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("acquire() beyond the limit error = %v, want ResourceExhausted", err)
+	}
+
+	first()
+	if got := l.inFlight.Load(); got != 1 {
+		t.Errorf("in flight = %d after a release, want 1", got)
+	}
+	third, err := l.acquire()
+	if err != nil {
+		t.Fatalf("acquire() after a release error = %v", err)
+	}
+	second()
+	third()
+	if got := l.inFlight.Load(); got != 0 {
+		t.Errorf("in flight = %d after all releases, want 0", got)
+	}
+}
+
+func TestSearchProductsRejectedWhenSaturated(t *testing.T) {
+	p := &productCatalog{
+		catalog:  newCatalogStore([]*pb.Product{{Id: "OLJCESPC7Z", Name: "Explorascope"}}),
+		searches: newSearchLimiter(1),
+	}
+	release, err := p.searches.acquire()
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	if _, err := p.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "explorascope"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("SearchProducts() while saturated error = %v, want ResourceExhausted", err)
+	}
+	if _, err := p.GetCartRecommendations(context.Background(), &pb.GetCartRecommendationsRequest{}); err != nil {
+		t.Errorf("GetCartRecommendations() while searches are saturated error = %v", err)
+	}
+
+	release()
+	resp, err := p.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "explorascope"})
+	if err != nil || len(resp.GetResults()) != 1 {
+		t.Errorf("SearchProducts() after release = %v, %v, want one result", resp, err)
+	}
+}
+
+func TestUnlimitedSearchLimiter(t *testing.T) {
+	l := newSearchLimiter(0)
+	for range 100 {
+		if _, err := l.acquire(); err != nil {
+			t.Fatalf("acquire() without a limit error = %v", err)
+		}
+	}
+	if got := l.inFlight.Load(); got != 100 {
+		t.Errorf("in flight = %d, want 100", got)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSearchProductsQueryLength(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{{Id: "1", Name: strings.Repeat("a", 10)}})
+	svc := &productCatalog{catalog: catalog, maxQueryLen: 10}
+
+	tests := []struct {
+		name  string
+		query string
+		want  codes.Code
+	}{
+		{"at limit", strings.Repeat("a", 10), codes.OK},
+		{"at limit after trimming whitespace", "  " + strings.Repeat("a", 10) + "\t\n", codes.OK},
+		{"at limit in characters", strings.Repeat("é", 10), codes.OK},
+		{"over limit", strings.Repeat("a", 11), codes.InvalidArgument},
+		{"over limit after collapsing whitespace", "aaaaa     aaaaa", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: tt.query})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("SearchProducts(%q) = %v, want %v", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchProductsFields(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "1", Name: "Solar Filter", Description: "Protects your telescope"},
+		{Id: "2", Name: "Telescope", Description: "Great for stargazing"},
+		{Id: "3", Name: "Lens Cleaner", Description: "Cleans any lens"},
+	})
+	svc := &productCatalog{catalog: catalog, searchCache: newSearchCache(10, time.Minute)}
+
+	tests := []struct {
+		fields pb.SearchFields
+		want   []string
+	}{
+		{pb.SearchFields_SEARCH_FIELDS_UNSPECIFIED, []string{"1", "2"}},
+		{pb.SearchFields_SEARCH_FIELDS_NAME_AND_DESCRIPTION, []string{"1", "2"}},
+		{pb.SearchFields_SEARCH_FIELDS_NAME, []string{"2"}},
+		{pb.SearchFields_SEARCH_FIELDS_DESCRIPTION, []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fields.String(), func(t *testing.T) {
+			resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope", Fields: tt.fields})
+			if err != nil {
+				t.Fatalf("SearchProducts: %v", err)
+			}
+			var got []string
+			for _, p := range resp.Results {
+				got = append(got, p.Id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchProductsTags(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "1", Name: "Explorascope", Description: "A vintage-looking refractor", Tags: []string{"Telescope"}},
+		{Id: "2", Name: "Comet Book", Description: "Stories of comets", Tags: []string{"vintage", "books"}},
+		{Id: "3", Name: "Vintage Telescope", Description: "Brass tube"},
+		{Id: "4", Name: "Red Flashlight", Tags: []string{"vintage lamps"}},
+	})
+	svc := &productCatalog{catalog: catalog, searchCache: newSearchCache(10, time.Minute)}
+
+	tests := []struct {
+		name   string
+		query  string
+		fields pb.SearchFields
+		want   []string
+	}{
+		{"default matches tags", "vintage", pb.SearchFields_SEARCH_FIELDS_UNSPECIFIED, []string{"1", "2", "3"}},
+		{"default matches tags ignoring case", "TELESCOPE", pb.SearchFields_SEARCH_FIELDS_UNSPECIFIED, []string{"1", "3"}},
+		{"name and description ignore tags", "books", pb.SearchFields_SEARCH_FIELDS_NAME_AND_DESCRIPTION, nil},
+		{"tag only", "vintage", pb.SearchFields_SEARCH_FIELDS_TAGS, []string{"2"}},
+		{"tag only ignoring case", "telescope", pb.SearchFields_SEARCH_FIELDS_TAGS, []string{"1"}},
+		{"tag only with several words", "vintage  lamps", pb.SearchFields_SEARCH_FIELDS_TAGS, []string{"4"}},
+		{"tag only without a match", "vint", pb.SearchFields_SEARCH_FIELDS_TAGS, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the second search is served from the cache
+			for range 2 {
+				resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: tt.query, Fields: tt.fields})
+				if err != nil {
+					t.Fatalf("SearchProducts: %v", err)
+				}
+				var got []string
+				for _, p := range resp.Results {
+					got = append(got, p.Id)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("SearchProducts(%q, %v) = %v, want %v", tt.query, tt.fields, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchProductsMinScore(t *testing.T) {
+	catalog := newCatalogStore([]*pb.Product{
+		{Id: "A", Name: "Telescope"},
+		{Id: "B", Name: "Solar Telescope Kit"},
+		{Id: "C", Name: "Telescopes"},
+		{Id: "D", Name: "Tripod", Description: "Works with any telescope."},
+		{Id: "E", Name: "Eyepiece", Description: "Fits most telescopes"},
+		{Id: "F", Name: "Lens Cap", Tags: []string{"Telescope"}},
+	})
+	svc := &productCatalog{catalog: catalog, searchCache: newSearchCache(10, time.Minute)}
+
+	search := func(minScore float32) []string {
+		t.Helper()
+		resp, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope", MinScore: minScore})
+		if err != nil {
+			t.Fatalf("SearchProducts(min_score %v) error = %v", minScore, err)
+		}
+		var ids []string
+		for _, p := range resp.Results {
+			ids = append(ids, p.Id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		minScore float32
+		want     []string
+	}{
+		{0, []string{"A", "B", "C", "D", "E", "F"}},
+		{0.3, []string{"A", "B", "C", "D", "F"}},
+		{0.5, []string{"A", "B", "C", "F"}},
+		{0.7, []string{"A", "B", "F"}},
+		{1, []string{"A", "F"}},
+	}
+	for _, tt := range tests {
+		if got := search(tt.minScore); !slices.Equal(got, tt.want) {
+			t.Errorf("results with min_score %v = %v, want %v", tt.minScore, got, tt.want)
+		}
+	}
+
+	// the server default applies to searches without a threshold
+	svc.searchMinScore = 0.5
+	if got, want := search(0), []string{"A", "B", "C", "F"}; !slices.Equal(got, want) {
+		t.Errorf("results with the default min_score = %v, want %v", got, want)
+	}
+
+	_, err := svc.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: "telescope", MinScore: 1.5})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SearchProducts(min_score 1.5) error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestSearchMinScoreFromEnv(t *testing.T) {
+	for value, want := range map[string]float32{"": 0, "0.4": 0.4, "2": 0, "weak": 0} {
+		t.Setenv("CATALOG_SEARCH_MIN_SCORE", value)
+		if got := searchMinScoreFromEnv(); got != want {
+			t.Errorf("searchMinScoreFromEnv() with %q = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultTenant is the tenant of requests without a tenant header. Its
+	// catalog is the one in the database.
+	defaultTenant = "default"
+	// tenantHeader is the metadata key naming the caller's tenant.
+	tenantHeader = "x-tenant-id"
+)
+
+// tenantCatalogs holds the catalogs of the tenants other than the default
+// one, read from the subdirectories of the product directory. A nil
+// *tenantCatalogs means the catalog is not namespaced and every request is
+// served from the default catalog.
+type tenantCatalogs struct {
+	mu       sync.RWMutex
+	catalogs map[string]*catalogStore
+}
+
+// tenantCatalogsFromEnv enables namespacing when CATALOG_TENANTS is set.
+func tenantCatalogsFromEnv() *tenantCatalogs {
+	if enabled, _ := strconv.ParseBool(os.Getenv("CATALOG_TENANTS")); !enabled {
+		return nil
+	}
+	return &tenantCatalogs{}
+}
+
+func (t *tenantCatalogs) set(catalogs map[string]*catalogStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.catalogs = catalogs
+}
+
+func (t *tenantCatalogs) get(tenant string) (*catalogStore, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	catalog, ok := t.catalogs[tenant]
+	return catalog, ok
+}
+
+// readTenantDirs reads the catalog of each tenant from the subdirectory of
+// dir named after it. The product files in dir itself are the catalog of the
+// default tenant, which has no subdirectory.
+func readTenantDirs(dir string) (map[string]*catalogStore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := make(map[string]*catalogStore)
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == defaultTenant {
+			continue
+		}
+		products, err := readProductDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		catalogs[entry.Name()] = newCatalogStore(products)
+		logger.Info("Loaded tenant catalog", "tenant", entry.Name(), "amount", len(products))
+	}
+	return catalogs, nil
+}
+
+// tenantFromContext returns the tenant named in the incoming metadata, or the
+// default tenant.
+func tenantFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(tenantHeader); len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		return strings.TrimSpace(values[0])
+	}
+	return defaultTenant
+}
+
+// tenantCatalog returns the catalog of the caller's tenant, or nil when the
+// request is served from the default catalog in the database.
+func (p *productCatalog) tenantCatalog(ctx context.Context) (*catalogStore, error) {
+	if p.tenants == nil {
+		return nil, nil
+	}
+	tenant := tenantFromContext(ctx)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("app.tenant.id", tenant))
+	if tenant == defaultTenant {
+		return nil, nil
+	}
+	if p.loading.Load() {
+		return nil, status.Error(codes.Unavailable, "catalog is loading")
+	}
+	catalog, ok := p.tenants.get(tenant)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown tenant %q", tenant)
+	}
+	return catalog, nil
+}
+
+// tenantProduct returns a copy of the product requested from a tenant
+// catalog, or nil if the tenant has no such product.
+func (p *productCatalog) tenantProduct(catalog *catalogStore, req *pb.GetProductRequest) *pb.Product {
+	products, index, _ := catalog.snapshot()
+	i, ok := index[req.Id]
+	if !ok {
+		return nil
+	}
+	product := proto.Clone(products[i]).(*pb.Product)
+	product.Description = p.sanitizeDescription(product.Description)
+	p.applyImageSize(product, req.GetImageSize())
+	return product
+}
+
+// listTenantProducts returns a page of a tenant catalog, ordered like the
+// default catalog listing.
+func (p *productCatalog) listTenantProducts(catalog *catalogStore, req *pb.ListProductsRequest, pg page) ([]*pb.Product, bool) {
+	products := slices.Clone(catalog.current())
+	if req.GetSortBy() == pb.ProductSort_PRODUCT_SORT_POPULARITY {
+		sortByPopularity(products, (*pb.Product).GetId, p.popularity.snapshot())
+	} else if !p.sortByFile {
+		slices.SortStableFunc(products, func(a, b *pb.Product) int { return strings.Compare(a.Id, b.Id) })
+	}
+
+	start, end := pg.bounds(len(products))
+	page := make([]*pb.Product, 0, end-start)
+	for _, product := range products[start:end] {
+		product = proto.Clone(product).(*pb.Product)
+		product.Description = p.listedDescription(product.Description)
+		p.applyImageSize(product, req.GetImageSize())
+		page = append(page, product)
+	}
+	return page, end < len(products)
+}
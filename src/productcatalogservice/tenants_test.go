@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func tenantContext(tenant string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, tenant))
+}
+
+func TestTenantCatalogs(t *testing.T) {
+	dir := t.TempDir()
+	writeProductFile(t, dir, "products.json", `[{"id": "DEFAULT"}]`)
+	for tenant, content := range map[string]string{
+		"acme":   `[{"id": "ACME-2", "name": "Anvil"}, {"id": "ACME-1", "name": "Rocket"}]`,
+		"globex": `[{"id": "GLOBEX-1", "name": "Hammock"}]`,
+	} {
+		if err := os.Mkdir(filepath.Join(dir, tenant), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeProductFile(t, filepath.Join(dir, tenant), "products.json", content)
+	}
+
+	catalogs, err := readTenantDirs(dir)
+	if err != nil {
+		t.Fatalf("readTenantDirs: %v", err)
+	}
+	svc := &productCatalog{catalog: newCatalogStore(nil), tenants: &tenantCatalogs{}}
+	svc.tenants.set(catalogs)
+
+	product, err := svc.GetProduct(tenantContext("acme"), &pb.GetProductRequest{Id: "ACME-1"})
+	if err != nil || product.Name != "Rocket" {
+		t.Errorf("GetProduct(ACME-1) for acme = %v, %v, want the Rocket", product, err)
+	}
+	if _, err := svc.GetProduct(tenantContext("globex"), &pb.GetProductRequest{Id: "ACME-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProduct(ACME-1) for globex error = %v, want NotFound", err)
+	}
+	if _, err := svc.GetProduct(tenantContext("initech"), &pb.GetProductRequest{Id: "ACME-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProduct() for an unknown tenant error = %v, want NotFound", err)
+	}
+
+	resp, err := svc.ListProducts(tenantContext("acme"), &pb.ListProductsRequest{PageSize: 1})
+	if err != nil || len(resp.Products) != 1 || resp.Products[0].Id != "ACME-1" || resp.NextPageToken == "" {
+		t.Fatalf("first page for acme = %v, %v, want ACME-1 and a next page", resp, err)
+	}
+	resp, err = svc.ListProducts(tenantContext("acme"), &pb.ListProductsRequest{PageSize: 1, PageToken: resp.NextPageToken})
+	if err != nil || len(resp.Products) != 1 || resp.Products[0].Id != "ACME-2" || resp.NextPageToken != "" {
+		t.Errorf("second page for acme = %v, %v, want only ACME-2", resp, err)
+	}
+	resp, err = svc.ListProducts(tenantContext("globex"), &pb.ListProductsRequest{})
+	if err != nil || len(resp.Products) != 1 || resp.Products[0].Id != "GLOBEX-1" {
+		t.Errorf("products of globex = %v, %v, want only GLOBEX-1", resp, err)
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	if got := tenantFromContext(context.Background()); got != defaultTenant {
+		t.Errorf("tenantFromContext() without metadata = %q, want %q", got, defaultTenant)
+	}
+	if got := tenantFromContext(tenantContext(" acme ")); got != "acme" {
+		t.Errorf("tenantFromContext() = %q, want acme", got)
+	}
+	if catalog, err := (&productCatalog{}).tenantCatalog(tenantContext("acme")); catalog != nil || err != nil {
+		t.Errorf("tenantCatalog() with namespacing disabled = %v, %v, want the default catalog", catalog, err)
+	}
+}
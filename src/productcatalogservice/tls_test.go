@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTransportSecurityFromEnv(t *testing.T) {
+	t.Run("insecure outside production", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "staging")
+		ts, err := transportSecurityFromEnv()
+		if err != nil {
+			t.Fatalf("transportSecurityFromEnv: %v", err)
+		}
+		if ts.serverOptions() != nil {
+			t.Error("server without TLS settings is secured")
+		}
+	})
+
+	t.Run("insecure refused in production", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "production")
+		if _, err := transportSecurityFromEnv(); err == nil {
+			t.Error("transportSecurityFromEnv allowed insecure transport in production")
+		}
+	})
+
+	t.Run("unreadable certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("TLS_CERT_FILE", filepath.Join(dir, "tls.crt"))
+		t.Setenv("TLS_KEY_FILE", filepath.Join(dir, "tls.key"))
+		if _, err := transportSecurityFromEnv(); err == nil {
+			t.Error("transportSecurityFromEnv accepted a missing certificate")
+		}
+	})
+}
+
+func TestTLSPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		minVersion string
+		ciphers    string
+		ok         bool
+	}{
+		{"defaults", "", "", true},
+		{"TLS 1.3", "1.3", "", true},
+		{"TLS 1.2 with restricted ciphers", "1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", true},
+		{"weak version", "1.0", "", false},
+		{"insecure cipher", "", "TLS_RSA_WITH_3DES_EDE_CBC_SHA", false},
+		{"ciphers with TLS 1.3", "1.3", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TLS_MIN_VERSION", tt.minVersion)
+			t.Setenv("TLS_CIPHER_SUITES", tt.ciphers)
+			if _, _, err := tlsPolicyFromEnv(); (err == nil) != tt.ok {
+				t.Errorf("tlsPolicyFromEnv() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+
+	t.Setenv("TLS_MIN_VERSION", "1.1")
+	if _, err := transportSecurityFromEnv(); err == nil {
+		t.Error("transportSecurityFromEnv accepted a weak minimum TLS version")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyCatalog checks the loaded catalog of the caller's tenant against the
+// rules imports are held to, and reports every product breaking them, as well
+// as products sharing an ID. For the default tenant, the products in the
+// database, which ListProducts and GetProduct serve, are checked as well, and
+// their violations are reported with a "database: " reason. Violations are
+// reported, not fixed: the catalog keeps serving as is.
+func (p *productCatalog) VerifyCatalog(ctx context.Context, req *pb.VerifyCatalogRequest) (*pb.VerifyCatalogResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	catalog, err := p.tenantCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*pb.Product
+	if catalog == nil {
+		if err := p.checkLoaded(); err != nil {
+			span.AddEvent("catalog not loaded")
+			return nil, err
+		}
+		catalog = p.catalog
+		if rows, err = readDatabaseProducts(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	products := catalog.current()
+	resp := &pb.VerifyCatalogResponse{
+		Checked:    int32(len(products) + len(rows)),
+		Violations: verifyProducts(products),
+	}
+	for _, v := range verifyProducts(rows) {
+		v.Reason = "database: " + v.Reason
+		resp.Violations = append(resp.Violations, v)
+	}
+	span.SetAttributes(
+		attribute.Int("app.catalog_verify.checked", int(resp.Checked)),
+		attribute.Int("app.catalog_verify.database_rows", len(rows)),
+		attribute.Int("app.catalog_verify.violations", len(resp.Violations)),
+	)
+	return resp, nil
+}
+
+// readDatabaseProducts returns the fields of the products in the database
+// that verifyProducts checks, ordered by id. It returns nothing when there is
+// no database.
+func readDatabaseProducts(ctx context.Context) ([]*pb.Product, error) {
+	if db == nil {
+		return nil, nil
+	}
+	var rows []Product
+	if err := db.WithContext(ctx).Order("id").Find(&rows).Error; err != nil {
+		logger.ErrorContext(ctx, err.Error(), "event", "VerifyCatalog failed")
+		return nil, status.Errorf(codes.Internal, "Database Error: %v", err)
+	}
+	products := make([]*pb.Product, 0, len(rows))
+	for _, row := range rows {
+		products = append(products, &pb.Product{
+			Id:   row.ID,
+			Name: row.Name,
+			PriceUsd: &pb.Money{
+				CurrencyCode: row.PriceCurrencyCode,
+				Units:        int64(row.PriceUnits),
+				Nanos:        int32(row.PriceNanos),
+			},
+		})
+	}
+	return products, nil
+}
+
+// verifyProducts returns the violations found in products, in catalog order.
+func verifyProducts(products []*pb.Product) []*pb.CatalogViolation {
+	var violations []*pb.CatalogViolation
+	seen := make(map[string]bool, len(products))
+	for _, product := range products {
+		if reason := validateImportedProduct(product); reason != "" {
+			violations = append(violations, &pb.CatalogViolation{ProductId: product.GetId(), Reason: reason})
+		}
+		if id := product.GetId(); id != "" {
+			if seen[id] {
+				violations = append(violations, &pb.CatalogViolation{ProductId: id, Reason: "duplicate id"})
+			}
+			seen[id] = true
+		}
+	}
+	return violations
+}
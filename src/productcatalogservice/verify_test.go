@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/opentelemetry/opentelemetry-demo/src/productcatalogservice/genproto/oteldemo"
+)
+
+func TestVerifyCatalog(t *testing.T) {
+	t.Run("clean catalog", func(t *testing.T) {
+		svc := &productCatalog{catalog: newCatalogStore([]*pb.Product{
+			{Id: "A", Name: "Telescope", PriceUsd: usd(100, 0)},
+			{Id: "B", Name: "Lens", PriceUsd: usd(0, 990000000)},
+		})}
+		resp, err := svc.VerifyCatalog(context.Background(), &pb.VerifyCatalogRequest{})
+		if err != nil {
+			t.Fatalf("VerifyCatalog: %v", err)
+		}
+		if resp.Checked != 2 || len(resp.Violations) != 0 {
+			t.Errorf("VerifyCatalog() checked %d with violations %v, want 2 checked and none", resp.Checked, resp.Violations)
+		}
+	})
+
+	t.Run("seeded violations", func(t *testing.T) {
+		svc := &productCatalog{catalog: newCatalogStore([]*pb.Product{
+			{Id: "A", Name: "Telescope", PriceUsd: usd(100, 0)},
+			{Id: "A", Name: "Telescope again", PriceUsd: usd(100, 0)},
+			{Id: "B", PriceUsd: usd(10, 0)},
+			{Id: "C", Name: "Negative", PriceUsd: usd(-1, 0)},
+			{Id: "D", Name: "Euros", PriceUsd: &pb.Money{CurrencyCode: "EUR", Units: 1}},
+			{Id: "E", Name: "Free"},
+		})}
+		resp, err := svc.VerifyCatalog(context.Background(), &pb.VerifyCatalogRequest{})
+		if err != nil {
+			t.Fatalf("VerifyCatalog: %v", err)
+		}
+
+		want := []*pb.CatalogViolation{
+			{ProductId: "A", Reason: "duplicate id"},
+			{ProductId: "B", Reason: "missing name"},
+			{ProductId: "C", Reason: "invalid price"},
+			{ProductId: "D", Reason: "price must be in USD"},
+			{ProductId: "E", Reason: "missing price"},
+		}
+		if len(resp.Violations) != len(want) {
+			t.Fatalf("VerifyCatalog() violations = %v, want %v", resp.Violations, want)
+		}
+		for i, v := range resp.Violations {
+			if v.ProductId != want[i].ProductId || v.Reason != want[i].Reason {
+				t.Errorf("violation %d = %s: %s, want %s: %s", i, v.ProductId, v.Reason, want[i].ProductId, want[i].Reason)
+			}
+		}
+	})
+}